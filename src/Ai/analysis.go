@@ -16,10 +16,10 @@ func NewSecurityManager() *SecurityManager {
 // DetectFraudulentActivities scans the blockchain for fraudulent activities.
 func (s *SecurityManager) DetectFraudulentActivities(bc *blockchain.Blockchain) {
 	for _, block := range bc.Chain {
-		for _, tx := range block.Transactions {
-			// Example: Detect specific fraudulent patterns
-			if tx == "fraudulent_transaction" {
-				fmt.Printf("Detected fraudulent transaction in Block %d: %s\n", block.Index, tx)
+		for _, tx := range block.Transactions() {
+			// Example: Detect transactions whose contents no longer match their hash
+			if !tx.Validate() {
+				fmt.Printf("Detected fraudulent transaction in Block %d: %s\n", block.Index, tx.Hash)
 			}
 		}
 	}
@@ -3,17 +3,21 @@ package api
 import (
 	"BMT-Blockchain/src/blockchain"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 )
 
 type API struct {
+	Chain  *blockchain.Blockchain
 	Bridge *blockchain.CrossChainBridge
 	Oracle *blockchain.OracleSystem
 }
 
-func NewAPI(bridge *blockchain.CrossChainBridge, oracle *blockchain.OracleSystem) *API {
+func NewAPI(chain *blockchain.Blockchain, bridge *blockchain.CrossChainBridge, oracle *blockchain.OracleSystem) *API {
 	return &API{
+		Chain:  chain,
 		Bridge: bridge,
 		Oracle: oracle,
 	}
@@ -25,6 +29,17 @@ func (api *API) StartAPI(port string) {
 	http.HandleFunc("/mint-tokens", api.MintTokensHandler)
 	http.HandleFunc("/unlock-tokens", api.UnlockTokensHandler)
 	http.HandleFunc("/verify-transaction", api.VerifyTransactionHandler)
+	http.HandleFunc("/merkle-proof", api.MerkleProofHandler)
+	http.HandleFunc("/account-proof", api.AccountProofHandler)
+	http.HandleFunc("/block", api.BlockHandler)
+	http.HandleFunc("/transaction", api.TransactionHandler)
+	http.HandleFunc("/address-history", api.AddressHistoryHandler)
+	http.HandleFunc("/validators", api.ValidatorsHandler)
+	http.HandleFunc("/stake", api.StakeHandler)
+	http.HandleFunc("/vesting", api.VestingHandler)
+	http.HandleFunc("/evidence", api.EvidenceHandler)
+	http.HandleFunc("/supply", api.SupplyHandler)
+	http.HandleFunc("/tokens", api.TokensHandler)
 
 	fmt.Printf("API Server running on port %s\n", port)
 	http.ListenAndServe(":"+port, nil)
@@ -38,8 +53,8 @@ func (api *API) LockTokensHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var request struct {
-		Address string  `json:"address"`
-		Amount  float64 `json:"amount"`
+		Address string            `json:"address"`
+		Amount  blockchain.Amount `json:"amount"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -64,8 +79,8 @@ func (api *API) MintTokensHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var request struct {
-		Address string  `json:"address"`
-		Amount  float64 `json:"amount"`
+		Address string            `json:"address"`
+		Amount  blockchain.Amount `json:"amount"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -90,8 +105,8 @@ func (api *API) UnlockTokensHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var request struct {
-		Address string  `json:"address"`
-		Amount  float64 `json:"amount"`
+		Address string            `json:"address"`
+		Amount  blockchain.Amount `json:"amount"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -131,3 +146,280 @@ func (api *API) VerifyTransactionHandler(w http.ResponseWriter, r *http.Request)
 
 	json.NewEncoder(w).Encode(map[string]bool{"valid": valid})
 }
+
+// MerkleProofHandler serves the Merkle inclusion proof of a transaction
+func (api *API) MerkleProofHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	txHash := r.URL.Query().Get("txHash")
+	if txHash == "" {
+		http.Error(w, "Missing txHash parameter", http.StatusBadRequest)
+		return
+	}
+
+	proof, err := api.Chain.GetTransactionProof(txHash)
+	if errors.Is(err, blockchain.ErrNotFound) {
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, blockchain.ErrPruned) {
+		http.Error(w, fmt.Sprintf("Pruned: %v", err), http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(proof)
+}
+
+// AccountProofHandler serves an account's state with a proof against the latest state root
+func (api *API) AccountProofHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Missing address parameter", http.StatusBadRequest)
+		return
+	}
+
+	proof, err := api.Chain.GetAccountProof(address)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(proof)
+}
+
+// BlockHandler serves the canonical block at a height
+func (api *API) BlockHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	height, err := strconv.Atoi(r.URL.Query().Get("height"))
+	if err != nil {
+		http.Error(w, "Invalid height parameter", http.StatusBadRequest)
+		return
+	}
+
+	block, err := api.Chain.GetBlock(height)
+	if errors.Is(err, blockchain.ErrNotFound) {
+		http.Error(w, "Block not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, blockchain.ErrPruned) {
+		http.Error(w, fmt.Sprintf("Pruned: %v", err), http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(block)
+}
+
+// TransactionHandler serves a transaction and the block location it was included at
+func (api *API) TransactionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	txHash := r.URL.Query().Get("txHash")
+	if txHash == "" {
+		http.Error(w, "Missing txHash parameter", http.StatusBadRequest)
+		return
+	}
+
+	record, err := api.Chain.GetTransaction(txHash)
+	if errors.Is(err, blockchain.ErrNotFound) {
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, blockchain.ErrPruned) {
+		http.Error(w, fmt.Sprintf("Pruned: %v", err), http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(record)
+}
+
+// AddressHistoryHandler serves a page of the transactions involving an address, newest first
+func (api *API) AddressHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	address := query.Get("address")
+	if address == "" {
+		http.Error(w, "Missing address parameter", http.StatusBadRequest)
+		return
+	}
+	offset, limit := 0, blockchain.MaxHistoryPageSize
+	var err error
+	if value := query.Get("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := api.Chain.GetAddressHistory(address, offset, limit)
+	if errors.Is(err, blockchain.ErrPruned) {
+		http.Error(w, fmt.Sprintf("Pruned: %v", err), http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(page)
+}
+
+// ValidatorsHandler serves the validator set deciding the next block and the stake of every address holding any
+func (api *API) ValidatorsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	height := api.Chain.GetLatestBlock().Index + 1
+	response := struct {
+		Height     int                    `json:"height"`
+		Epoch      int                    `json:"epoch"`
+		Validators []blockchain.Validator `json:"validators"`
+		Stakes     []blockchain.StakeInfo `json:"stakes"`
+	}{
+		Height: height,
+		Epoch:  api.Chain.Epoch(height),
+		Stakes: api.Chain.Stakes(),
+	}
+	if validators := api.Chain.ValidatorsAt(height); validators != nil {
+		response.Validators = validators.Validators
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// StakeHandler serves the staking state of an address
+func (api *API) StakeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Missing address parameter", http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(api.Chain.GetStake(address))
+}
+
+// VestingHandler serves the vested and locked amounts of an address's vesting schedule
+func (api *API) VestingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Missing address parameter", http.StatusBadRequest)
+		return
+	}
+
+	info, ok := api.Chain.GetVesting(address)
+	if !ok {
+		http.Error(w, "Address has no vesting schedule", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(info)
+}
+
+// SupplyHandler serves the circulating, total and max supply with the minted,
+// burned and locked amounts behind them
+func (api *API) SupplyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(api.Chain.SupplyReport(api.Bridge))
+}
+
+// TokensHandler serves the registered tokens, or one token with the balance of
+// an address if a symbol and an address are given
+func (api *API) TokensHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		json.NewEncoder(w).Encode(api.Chain.Tokens())
+		return
+	}
+	token, ok := api.Chain.GetToken(symbol)
+	if !ok {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+	response := struct {
+		Token   blockchain.TokenInfo `json:"token"`
+		Balance *blockchain.Amount   `json:"balance,omitempty"`
+	}{Token: token}
+	if address := r.URL.Query().Get("address"); address != "" {
+		balance := api.Chain.GetTokenBalance(symbol, address)
+		response.Balance = &balance
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// EvidenceHandler accepts evidence of validator misbehaviour and serves the evidence waiting for a block
+func (api *API) EvidenceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(api.Chain.PendingEvidence())
+	case http.MethodPost:
+		var evidence blockchain.Evidence
+		if err := json.NewDecoder(r.Body).Decode(&evidence); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := api.Chain.SubmitEvidence(&evidence); err != nil {
+			http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Evidence accepted"})
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
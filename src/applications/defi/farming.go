@@ -1,6 +1,7 @@
 package defi
 
 import (
+	"BMT-Blockchain/src/blockchain"
 	"errors"
 	"sync"
 	"time"
@@ -8,24 +9,24 @@ import (
 
 // FarmingPool represents a pool for yield farming.
 type FarmingPool struct {
-	TotalStaked   float64           // Total amount staked in the pool
-	RewardRate    float64           // Reward rate per second (e.g., 0.01 BMT/s)
-	Stakes        map[string]float64 // User stakes
-	StakeTimes    map[string]int64  // Timestamps when users staked
-	mutex         sync.Mutex        // Mutex for thread safety
+	TotalStaked blockchain.Amount            // Total amount staked in the pool
+	RewardRate  float64                      // Reward rate per second (e.g., 0.01 BMT/s)
+	Stakes      map[string]blockchain.Amount // User stakes
+	StakeTimes  map[string]int64             // Timestamps when users staked
+	mutex       sync.Mutex                   // Mutex for thread safety
 }
 
 // NewFarmingPool creates a new farming pool with a specified reward rate.
 func NewFarmingPool(rewardRate float64) *FarmingPool {
 	return &FarmingPool{
 		RewardRate: rewardRate,
-		Stakes:     make(map[string]float64),
+		Stakes:     make(map[string]blockchain.Amount),
 		StakeTimes: make(map[string]int64),
 	}
 }
 
 // Stake allows a user to stake tokens into the farming pool.
-func (fp *FarmingPool) Stake(user string, amount float64) error {
+func (fp *FarmingPool) Stake(user string, amount blockchain.Amount) error {
 	fp.mutex.Lock()
 	defer fp.mutex.Unlock()
 
@@ -34,19 +35,29 @@ func (fp *FarmingPool) Stake(user string, amount float64) error {
 	}
 
 	// Calculate pending rewards before updating stake
+	totalStaked := fp.TotalStaked
 	if _, exists := fp.StakeTimes[user]; exists {
-		rewards := fp.calculateRewards(user)
-		fp.TotalStaked += rewards
+		rewards, err := fp.calculateRewards(user)
+		if err != nil {
+			return err
+		}
+		if totalStaked, err = totalStaked.Add(rewards); err != nil {
+			return err
+		}
 	}
 
-	fp.TotalStaked += amount
+	totalStaked, err := totalStaked.Add(amount)
+	if err != nil {
+		return err
+	}
+	fp.TotalStaked = totalStaked
 	fp.Stakes[user] += amount
 	fp.StakeTimes[user] = time.Now().Unix()
 	return nil
 }
 
 // Unstake allows a user to withdraw staked tokens from the pool.
-func (fp *FarmingPool) Unstake(user string, amount float64) (float64, error) {
+func (fp *FarmingPool) Unstake(user string, amount blockchain.Amount) (blockchain.Amount, error) {
 	fp.mutex.Lock()
 	defer fp.mutex.Unlock()
 
@@ -58,7 +69,10 @@ func (fp *FarmingPool) Unstake(user string, amount float64) (float64, error) {
 	}
 
 	// Calculate pending rewards
-	rewards := fp.calculateRewards(user)
+	rewards, err := fp.calculateRewards(user)
+	if err != nil {
+		return 0, err
+	}
 
 	// Update staking data
 	fp.TotalStaked -= amount
@@ -69,7 +83,7 @@ func (fp *FarmingPool) Unstake(user string, amount float64) (float64, error) {
 }
 
 // ClaimRewards allows a user to claim their pending rewards.
-func (fp *FarmingPool) ClaimRewards(user string) (float64, error) {
+func (fp *FarmingPool) ClaimRewards(user string) (blockchain.Amount, error) {
 	fp.mutex.Lock()
 	defer fp.mutex.Unlock()
 
@@ -77,20 +91,23 @@ func (fp *FarmingPool) ClaimRewards(user string) (float64, error) {
 		return 0, errors.New("no active stake found for user")
 	}
 
-	rewards := fp.calculateRewards(user)
+	rewards, err := fp.calculateRewards(user)
+	if err != nil {
+		return 0, err
+	}
 	fp.StakeTimes[user] = time.Now().Unix() // Reset stake time
 	return rewards, nil
 }
 
 // calculateRewards calculates the rewards for a user based on staking duration.
-func (fp *FarmingPool) calculateRewards(user string) float64 {
+func (fp *FarmingPool) calculateRewards(user string) (blockchain.Amount, error) {
 	stakeTime := fp.StakeTimes[user]
 	duration := time.Now().Unix() - stakeTime
-	return float64(fp.Stakes[user]) * float64(duration) * fp.RewardRate
+	return fp.Stakes[user].MulRate(float64(duration) * fp.RewardRate)
 }
 
 // GetUserStake retrieves the user's staked amount.
-func (fp *FarmingPool) GetUserStake(user string) float64 {
+func (fp *FarmingPool) GetUserStake(user string) blockchain.Amount {
 	fp.mutex.Lock()
 	defer fp.mutex.Unlock()
 	return fp.Stakes[user]
@@ -1,46 +1,81 @@
 package defi
 
 import (
+	"BMT-Blockchain/src/blockchain"
 	"errors"
 	"sync"
+	"time"
 )
 
 // LendingPool represents a pool for lending and borrowing assets.
 type LendingPool struct {
-	TotalSupply  float64            // Total supply of assets in the pool
-	TotalBorrowed float64           // Total amount borrowed
-	InterestRate  float64           // Annual interest rate
-	Balances      map[string]float64 // User balances (deposited assets)
-	Borrowed      map[string]float64 // User borrow amounts
-	mutex         sync.Mutex        // Mutex for thread safety
+	TotalSupply   blockchain.Amount            // Total supply of assets in the pool
+	TotalBorrowed blockchain.Amount            // Total amount borrowed
+	InterestRate  float64                      // Annual interest rate
+	Balances      map[string]blockchain.Amount // User balances (deposited assets)
+	Borrowed      map[string]blockchain.Amount // User borrow amounts
+	mutex         sync.Mutex                   // Mutex for thread safety
 }
 
 // NewLendingPool creates a new lending pool with initial supply and interest rate.
-func NewLendingPool(initialSupply float64, interestRate float64) *LendingPool {
+func NewLendingPool(initialSupply blockchain.Amount, interestRate float64) *LendingPool {
 	return &LendingPool{
 		TotalSupply:  initialSupply,
 		InterestRate: interestRate,
-		Balances:     make(map[string]float64),
-		Borrowed:     make(map[string]float64),
+		Balances:     make(map[string]blockchain.Amount),
+		Borrowed:     make(map[string]blockchain.Amount),
 	}
 }
 
 // Deposit adds assets to the pool and updates the user's balance.
-func (lp *LendingPool) Deposit(user string, amount float64) error {
+func (lp *LendingPool) Deposit(user string, amount blockchain.Amount) error {
 	lp.mutex.Lock()
 	defer lp.mutex.Unlock()
 
 	if amount <= 0 {
 		return errors.New("amount must be greater than zero")
 	}
+	return lp.credit(user, amount)
+}
 
-	lp.TotalSupply += amount
+// DepositFrom pulls a user's BMT into the pool's address on chain, out of
+// the allowance the user approved for it, and credits the user's balance.
+// The pool's wallet signs the transfer and pays the fee.
+func (lp *LendingPool) DepositFrom(chain *blockchain.Blockchain, pool *blockchain.Wallet, user string, amount, fee blockchain.Amount) error {
+	lp.mutex.Lock()
+	defer lp.mutex.Unlock()
+
+	if amount <= 0 {
+		return errors.New("amount must be greater than zero")
+	}
+	if _, err := lp.TotalSupply.Add(amount); err != nil {
+		return err
+	}
+
+	tx, err := blockchain.NewTransferFromTransaction(pool, user, pool.Address, amount, fee,
+		chain.GetNonce(pool.Address), chain.ChainID, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if err := chain.AddTransactionBlock([]*blockchain.Transaction{tx}); err != nil {
+		return err
+	}
+	return lp.credit(user, amount)
+}
+
+// credit adds a deposit to the pool's supply and the user's balance.
+func (lp *LendingPool) credit(user string, amount blockchain.Amount) error {
+	totalSupply, err := lp.TotalSupply.Add(amount)
+	if err != nil {
+		return err
+	}
+	lp.TotalSupply = totalSupply
 	lp.Balances[user] += amount
 	return nil
 }
 
 // Borrow allows a user to borrow assets from the pool if sufficient collateral exists.
-func (lp *LendingPool) Borrow(user string, amount float64) error {
+func (lp *LendingPool) Borrow(user string, amount blockchain.Amount) error {
 	lp.mutex.Lock()
 	defer lp.mutex.Unlock()
 
@@ -64,7 +99,7 @@ func (lp *LendingPool) Borrow(user string, amount float64) error {
 }
 
 // Repay allows a user to repay borrowed assets.
-func (lp *LendingPool) Repay(user string, amount float64) error {
+func (lp *LendingPool) Repay(user string, amount blockchain.Amount) error {
 	lp.mutex.Lock()
 	defer lp.mutex.Unlock()
 
@@ -82,14 +117,14 @@ func (lp *LendingPool) Repay(user string, amount float64) error {
 }
 
 // GetUserBalance retrieves the user's deposited balance.
-func (lp *LendingPool) GetUserBalance(user string) float64 {
+func (lp *LendingPool) GetUserBalance(user string) blockchain.Amount {
 	lp.mutex.Lock()
 	defer lp.mutex.Unlock()
 	return lp.Balances[user]
 }
 
 // GetUserBorrowed retrieves the user's borrowed amount.
-func (lp *LendingPool) GetUserBorrowed(user string) float64 {
+func (lp *LendingPool) GetUserBorrowed(user string) blockchain.Amount {
 	lp.mutex.Lock()
 	defer lp.mutex.Unlock()
 	return lp.Borrowed[user]
@@ -1,30 +1,31 @@
 package defi
 
 import (
+	"BMT-Blockchain/src/blockchain"
 	"errors"
 	"sync"
 )
 
 // Stablecoin represents a stablecoin management system.
 type Stablecoin struct {
-	TotalSupply float64          // Total supply of the stablecoin
-	Reserves    float64          // Total reserves backing the stablecoin
-	PegRate     float64          // Peg rate (e.g., 1 stablecoin = 1 USD)
-	Balances    map[string]float64 // User balances
-	mutex       sync.Mutex       // Mutex for thread safety
+	TotalSupply blockchain.Amount            // Total supply of the stablecoin
+	Reserves    blockchain.Amount            // Total reserves backing the stablecoin
+	PegRate     float64                      // Peg rate (e.g., 1 stablecoin = 1 USD)
+	Balances    map[string]blockchain.Amount // User balances
+	mutex       sync.Mutex                   // Mutex for thread safety
 }
 
 // NewStablecoin initializes a new stablecoin system.
-func NewStablecoin(initialReserves float64, pegRate float64) *Stablecoin {
+func NewStablecoin(initialReserves blockchain.Amount, pegRate float64) *Stablecoin {
 	return &Stablecoin{
 		Reserves: initialReserves,
 		PegRate:  pegRate,
-		Balances: make(map[string]float64),
+		Balances: make(map[string]blockchain.Amount),
 	}
 }
 
 // Mint allows users to mint new stablecoins by depositing reserves.
-func (sc *Stablecoin) Mint(user string, amount float64) error {
+func (sc *Stablecoin) Mint(user string, amount blockchain.Amount) error {
 	sc.mutex.Lock()
 	defer sc.mutex.Unlock()
 
@@ -35,15 +36,23 @@ func (sc *Stablecoin) Mint(user string, amount float64) error {
 		return errors.New("insufficient reserves to mint stablecoins")
 	}
 
+	mintedStablecoins, err := amount.DivRate(sc.PegRate)
+	if err != nil {
+		return err
+	}
+	totalSupply, err := sc.TotalSupply.Add(mintedStablecoins)
+	if err != nil {
+		return err
+	}
+
 	sc.Reserves -= amount
-	mintedStablecoins := amount / sc.PegRate
-	sc.TotalSupply += mintedStablecoins
+	sc.TotalSupply = totalSupply
 	sc.Balances[user] += mintedStablecoins
 	return nil
 }
 
 // Burn allows users to burn stablecoins and reclaim reserves.
-func (sc *Stablecoin) Burn(user string, amount float64) (float64, error) {
+func (sc *Stablecoin) Burn(user string, amount blockchain.Amount) (blockchain.Amount, error) {
 	sc.mutex.Lock()
 	defer sc.mutex.Unlock()
 
@@ -54,29 +63,37 @@ func (sc *Stablecoin) Burn(user string, amount float64) (float64, error) {
 		return 0, errors.New("burn amount exceeds user balance")
 	}
 
-	reclaimedReserves := amount * sc.PegRate
-	sc.Reserves += reclaimedReserves
+	reclaimedReserves, err := amount.MulRate(sc.PegRate)
+	if err != nil {
+		return 0, err
+	}
+	reserves, err := sc.Reserves.Add(reclaimedReserves)
+	if err != nil {
+		return 0, err
+	}
+
+	sc.Reserves = reserves
 	sc.TotalSupply -= amount
 	sc.Balances[user] -= amount
 	return reclaimedReserves, nil
 }
 
 // GetBalance retrieves the balance of a user.
-func (sc *Stablecoin) GetBalance(user string) float64 {
+func (sc *Stablecoin) GetBalance(user string) blockchain.Amount {
 	sc.mutex.Lock()
 	defer sc.mutex.Unlock()
 	return sc.Balances[user]
 }
 
 // GetTotalSupply retrieves the total supply of the stablecoin.
-func (sc *Stablecoin) GetTotalSupply() float64 {
+func (sc *Stablecoin) GetTotalSupply() blockchain.Amount {
 	sc.mutex.Lock()
 	defer sc.mutex.Unlock()
 	return sc.TotalSupply
 }
 
 // GetReserves retrieves the total reserves backing the stablecoin.
-func (sc *Stablecoin) GetReserves() float64 {
+func (sc *Stablecoin) GetReserves() blockchain.Amount {
 	sc.mutex.Lock()
 	defer sc.mutex.Unlock()
 	return sc.Reserves
@@ -1,15 +1,17 @@
 package gamefi
 
 import (
+	"BMT-Blockchain/src/blockchain"
 	"errors"
 	"sync"
+	"time"
 )
 
 // Listing represents an NFT listed for sale in the marketplace.
 type Listing struct {
-	NFTID  string  // NFT ID being sold
-	Seller string  // Owner of the NFT
-	Price  float64 // Price in BMT
+	NFTID  string            // NFT ID being sold
+	Seller string            // Owner of the NFT
+	Price  blockchain.Amount // Price in BMT
 }
 
 // NFTMarketplace manages listings and transactions for NFTs.
@@ -35,7 +37,7 @@ func (m *NFTMarketplace) AddNFT(nft *NFT) {
 }
 
 // ListNFT lists an NFT for sale in the marketplace.
-func (m *NFTMarketplace) ListNFT(seller, nftID string, price float64) error {
+func (m *NFTMarketplace) ListNFT(seller, nftID string, price blockchain.Amount) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
@@ -49,6 +51,9 @@ func (m *NFTMarketplace) ListNFT(seller, nftID string, price float64) error {
 	if _, listed := m.Listings[nftID]; listed {
 		return errors.New("NFT is already listed for sale")
 	}
+	if price <= 0 {
+		return errors.New("listing price must be greater than zero")
+	}
 
 	m.Listings[nftID] = &Listing{
 		NFTID:  nftID,
@@ -59,7 +64,7 @@ func (m *NFTMarketplace) ListNFT(seller, nftID string, price float64) error {
 }
 
 // BuyNFT allows a buyer to purchase an NFT from the marketplace.
-func (m *NFTMarketplace) BuyNFT(buyer, nftID string, payment float64) error {
+func (m *NFTMarketplace) BuyNFT(buyer, nftID string, payment blockchain.Amount) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
@@ -84,6 +89,37 @@ func (m *NFTMarketplace) BuyNFT(buyer, nftID string, payment float64) error {
 	return nil
 }
 
+// SettleSale sells a listed NFT to a buyer who approved the operator's
+// address as a spender of at least the listing price. The price is
+// transferred on chain from the buyer to the seller out of that allowance, in
+// a block committed before the NFT changes hands; the operator pays the fee.
+func (m *NFTMarketplace) SettleSale(chain *blockchain.Blockchain, operator *blockchain.Wallet, buyer, nftID string, fee blockchain.Amount) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	listing, exists := m.Listings[nftID]
+	if !exists {
+		return errors.New("NFT is not listed for sale")
+	}
+	nft, exists := m.NFTs[nftID]
+	if !exists {
+		return errors.New("NFT not found")
+	}
+
+	tx, err := blockchain.NewTransferFromTransaction(operator, buyer, listing.Seller, listing.Price, fee,
+		chain.GetNonce(operator.Address), chain.ChainID, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if err := chain.AddTransactionBlock([]*blockchain.Transaction{tx}); err != nil {
+		return err
+	}
+
+	nft.Owner = buyer
+	delete(m.Listings, nftID)
+	return nil
+}
+
 // RemoveListing removes an NFT listing from the marketplace.
 func (m *NFTMarketplace) RemoveListing(seller, nftID string) error {
 	m.mutex.Lock()
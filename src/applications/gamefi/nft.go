@@ -2,7 +2,6 @@ package gamefi
 
 import (
 	"errors"
-	"sync"
 )
 
 // NFT represents a single NFT with metadata.
@@ -13,19 +12,6 @@ type NFT struct {
 	Description string // Description of the NFT
 }
 
-// NFTMarketplace manages NFTs and their transactions.
-type NFTMarketplace struct {
-	NFTs   map[string]*NFT // Mapping from NFT ID to NFT object
-	mutex  sync.Mutex      // Mutex for thread safety
-}
-
-// NewNFTMarketplace initializes a new NFT marketplace.
-func NewNFTMarketplace() *NFTMarketplace {
-	return &NFTMarketplace{
-		NFTs: make(map[string]*NFT),
-	}
-}
-
 // MintNFT creates a new NFT and assigns it to the specified owner.
 func (m *NFTMarketplace) MintNFT(owner, id, name, description string) (*NFT, error) {
 	m.mutex.Lock()
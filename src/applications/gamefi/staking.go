@@ -1,6 +1,7 @@
 package gamefi
 
 import (
+	"BMT-Blockchain/src/blockchain"
 	"errors"
 	"sync"
 	"time"
@@ -8,10 +9,10 @@ import (
 
 // StakedNFT represents an NFT that has been staked.
 type StakedNFT struct {
-	NFTID      string  // ID of the staked NFT
-	Owner      string  // Owner of the NFT
-	StakeTime  int64   // Timestamp when the NFT was staked
-	RewardRate float64 // Reward rate for staking (e.g., 10 BMT/day)
+	NFTID      string            // ID of the staked NFT
+	Owner      string            // Owner of the NFT
+	StakeTime  int64             // Timestamp when the NFT was staked
+	RewardRate blockchain.Amount // Reward rate for staking (e.g., 10 BMT/day)
 }
 
 // NFTStakingPool manages staking and rewards for NFTs.
@@ -28,7 +29,7 @@ func NewNFTStakingPool() *NFTStakingPool {
 }
 
 // StakeNFT stakes an NFT into the pool and starts earning rewards.
-func (pool *NFTStakingPool) StakeNFT(owner, nftID string, rewardRate float64) error {
+func (pool *NFTStakingPool) StakeNFT(owner, nftID string, rewardRate blockchain.Amount) error {
 	pool.mutex.Lock()
 	defer pool.mutex.Unlock()
 
@@ -46,7 +47,7 @@ func (pool *NFTStakingPool) StakeNFT(owner, nftID string, rewardRate float64) er
 }
 
 // UnstakeNFT allows a user to withdraw their staked NFT.
-func (pool *NFTStakingPool) UnstakeNFT(owner, nftID string) (float64, error) {
+func (pool *NFTStakingPool) UnstakeNFT(owner, nftID string) (blockchain.Amount, error) {
 	pool.mutex.Lock()
 	defer pool.mutex.Unlock()
 
@@ -59,7 +60,10 @@ func (pool *NFTStakingPool) UnstakeNFT(owner, nftID string) (float64, error) {
 	}
 
 	// Calculate rewards
-	rewards := pool.calculateRewards(stakedNFT)
+	rewards, err := pool.calculateRewards(stakedNFT)
+	if err != nil {
+		return 0, err
+	}
 
 	// Remove NFT from the pool
 	delete(pool.StakedNFTs, nftID)
@@ -68,7 +72,7 @@ func (pool *NFTStakingPool) UnstakeNFT(owner, nftID string) (float64, error) {
 }
 
 // ClaimRewards allows a user to claim their staking rewards without unstaking.
-func (pool *NFTStakingPool) ClaimRewards(owner, nftID string) (float64, error) {
+func (pool *NFTStakingPool) ClaimRewards(owner, nftID string) (blockchain.Amount, error) {
 	pool.mutex.Lock()
 	defer pool.mutex.Unlock()
 
@@ -81,7 +85,10 @@ func (pool *NFTStakingPool) ClaimRewards(owner, nftID string) (float64, error) {
 	}
 
 	// Calculate rewards
-	rewards := pool.calculateRewards(stakedNFT)
+	rewards, err := pool.calculateRewards(stakedNFT)
+	if err != nil {
+		return 0, err
+	}
 
 	// Reset stake time
 	stakedNFT.StakeTime = time.Now().Unix()
@@ -90,7 +97,7 @@ func (pool *NFTStakingPool) ClaimRewards(owner, nftID string) (float64, error) {
 }
 
 // calculateRewards calculates staking rewards based on time staked.
-func (pool *NFTStakingPool) calculateRewards(stakedNFT *StakedNFT) float64 {
+func (pool *NFTStakingPool) calculateRewards(stakedNFT *StakedNFT) (blockchain.Amount, error) {
 	duration := time.Now().Unix() - stakedNFT.StakeTime
-	return float64(duration) / 86400 * stakedNFT.RewardRate // Rewards per day
+	return stakedNFT.RewardRate.MulDiv(duration, 86400) // Rewards per day
 }
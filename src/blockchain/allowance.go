@@ -0,0 +1,320 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Addresses that signed transactions are sent to in order to manage the
+// allowances over the sender's coins. The Target of such a transaction is
+// the spender; a transaction sent to an ordinary address with a Target
+// transfers the Target's coins out of the sender's allowance instead.
+const (
+	ApproveAddress           = "allowance/approve"  // Sets the spender's allowance to the amount, revoking it for zero
+	IncreaseAllowanceAddress = "allowance/increase" // Adds the amount to the spender's allowance
+	DecreaseAllowanceAddress = "allowance/decrease" // Subtracts the amount from the spender's allowance
+	PermitAddress            = "allowance/permit"   // Applies the permit the transaction carries, with the fee paid by the sender
+)
+
+// Errors returned when a spender moves coins on an owner's behalf.
+var (
+	ErrInsufficientAllowance = errors.New("transfer exceeds the spender's allowance")
+	ErrInvalidPermit         = errors.New("invalid permit")
+)
+
+// allowancePrefix starts every allowance address.
+const allowancePrefix = "allowance/"
+
+// Allowances and permit nonces are kept in the account state under approval
+// addresses: "approval/<owner>" holds the owner's next permit nonce as its
+// Nonce, and "approval/<owner>/<spender>" holds the allowance as its Balance.
+const approvalPrefix = "approval/"
+
+// isAllowanceAddress reports whether an address is an allowance address,
+// which no BMT can be sent to.
+func isAllowanceAddress(address string) bool {
+	return strings.HasPrefix(address, allowancePrefix)
+}
+
+// isRecordAddress reports whether an address holds a token, approval or
+// governance record in the account state rather than BMT.
+func isRecordAddress(address string) bool {
+	return isTokenAddress(address) || strings.HasPrefix(address, approvalPrefix) || address == governanceRecord
+}
+
+// permitRecord returns the address of the record holding an owner's next
+// permit nonce.
+func permitRecord(owner string) string {
+	return approvalPrefix + owner
+}
+
+// allowanceRecord returns the address of the record holding the allowance of
+// a spender over an owner's coins.
+func allowanceRecord(owner, spender string) string {
+	return approvalPrefix + owner + "/" + spender
+}
+
+// Permit is an approval signed by the owner of the coins, which anyone can
+// submit in a transaction to PermitAddress so that a spender is approved
+// without the owner sending a transaction. Each permit is valid once, for
+// the owner's next permit nonce, and only on its chain until its deadline.
+type Permit struct {
+	Owner     string // Address approving the spender
+	Spender   string // Address allowed to transfer the owner's coins
+	Amount    Amount // Allowance granted, replacing the previous one
+	Nonce     uint64 // Permit nonce of the owner
+	Deadline  int64  // Unix time after which the permit can no longer be used
+	ChainID   string // Identifier of the chain the permit is valid on
+	PublicKey string // Hex-encoded public key of the owner
+	Signature string // Hex-encoded ECDSA signature over the hash
+}
+
+// NewPermit creates a permit approving a spender for an amount of the
+// wallet's coins and signs it.
+func NewPermit(wallet *Wallet, spender string, amount Amount, nonce uint64, deadline int64, chainID string) (*Permit, error) {
+	permit := &Permit{
+		Owner:     wallet.Address,
+		Spender:   spender,
+		Amount:    amount,
+		Nonce:     nonce,
+		Deadline:  deadline,
+		ChainID:   chainID,
+		PublicKey: wallet.PublicKey,
+	}
+	signature, err := wallet.SignTransaction(permit.Hash())
+	if err != nil {
+		return nil, err
+	}
+	permit.Signature = signature
+	return permit, nil
+}
+
+// Hash returns the hash the owner signs, over a canonical encoding of the
+// permit's fields. The leading "permit" field keeps a permit signature from
+// ever being valid for a transaction.
+func (p *Permit) Hash() string {
+	return hashFields("permit", p.Owner, p.Spender, formatInt(int64(p.Amount)), strconv.FormatUint(p.Nonce, 10),
+		formatInt(p.Deadline), p.ChainID)
+}
+
+// verify checks that the permit was signed by its owner's key.
+func (p *Permit) verify() error {
+	pubKeyBytes, err := hex.DecodeString(p.PublicKey)
+	if err != nil || GenerateAddress(pubKeyBytes) != p.Owner {
+		return fmt.Errorf("%w: public key does not belong to %s", ErrInvalidPermit, p.Owner)
+	}
+	valid, err := VerifySignature(p.PublicKey, p.Signature, p.Hash())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPermit, err)
+	}
+	if !valid {
+		return fmt.Errorf("%w: bad signature", ErrInvalidPermit)
+	}
+	return nil
+}
+
+// NewApproveTransaction creates a transaction setting the allowance of a
+// spender over the wallet's coins and signs it. An amount of zero revokes
+// the allowance.
+func NewApproveTransaction(wallet *Wallet, spender string, amount, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	return newAllowanceTransaction(wallet, ApproveAddress, spender, amount, fee, nonce, chainID, timestamp)
+}
+
+// NewIncreaseAllowanceTransaction creates a transaction adding an amount to
+// the allowance of a spender over the wallet's coins and signs it.
+func NewIncreaseAllowanceTransaction(wallet *Wallet, spender string, amount, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	return newAllowanceTransaction(wallet, IncreaseAllowanceAddress, spender, amount, fee, nonce, chainID, timestamp)
+}
+
+// NewDecreaseAllowanceTransaction creates a transaction subtracting an amount
+// from the allowance of a spender over the wallet's coins and signs it.
+func NewDecreaseAllowanceTransaction(wallet *Wallet, spender string, amount, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	return newAllowanceTransaction(wallet, DecreaseAllowanceAddress, spender, amount, fee, nonce, chainID, timestamp)
+}
+
+// newAllowanceTransaction creates a transaction to an allowance address for
+// a spender and signs it.
+func newAllowanceTransaction(wallet *Wallet, receiver, spender string, amount, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	if spender == "" {
+		return nil, errors.New("spender address cannot be empty")
+	}
+	if amount < 0 || fee < 0 {
+		return nil, errors.New("allowance and fee cannot be negative")
+	}
+	tx := &Transaction{
+		Sender:    wallet.Address,
+		Receiver:  receiver,
+		Amount:    amount,
+		Fee:       fee,
+		Timestamp: timestamp,
+		Nonce:     nonce,
+		ChainID:   chainID,
+		Target:    spender,
+	}
+	if err := tx.Sign(wallet); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// NewTransferFromTransaction creates a transaction from a spender's wallet
+// that transfers an amount of the owner's coins to a receiver out of the
+// spender's allowance, and signs it. The spender pays the fee.
+func NewTransferFromTransaction(wallet *Wallet, owner, receiver string, amount, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	if owner == "" {
+		return nil, errors.New("owner address cannot be empty")
+	}
+	tx, err := NewTransaction(wallet.Address, receiver, amount, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if fee < 0 {
+		return nil, errors.New("transaction fee cannot be negative")
+	}
+	tx.Fee, tx.Nonce, tx.ChainID, tx.Target = fee, nonce, chainID, owner
+	if err := tx.Sign(wallet); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// NewPermitTransaction creates a transaction submitting a permit from the
+// wallet's address, which pays the fee, and signs it.
+func NewPermitTransaction(wallet *Wallet, permit *Permit, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	if permit == nil {
+		return nil, errors.New("permit cannot be empty")
+	}
+	if fee < 0 {
+		return nil, errors.New("transaction fee cannot be negative")
+	}
+	tx := &Transaction{
+		Sender:    wallet.Address,
+		Receiver:  PermitAddress,
+		Fee:       fee,
+		Timestamp: timestamp,
+		Nonce:     nonce,
+		ChainID:   chainID,
+		Permit:    permit,
+	}
+	if err := tx.Sign(wallet); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// executeAllowance records the effect of a transaction sent to an allowance
+// address on the allowance of its target over the sender's coins, or of the
+// permit it carries.
+func (bc *Blockchain) executeAllowance(tx *Transaction, execution *blockExecution) error {
+	if tx.Receiver == PermitAddress {
+		return bc.executePermit(tx, execution)
+	}
+	if tx.Target == "" {
+		return errors.New("allowance transaction needs the spender as its target")
+	}
+
+	address := allowanceRecord(tx.Sender, tx.Target)
+	allowance := bc.loadRecord(execution, address)
+	switch tx.Receiver {
+	case ApproveAddress:
+		allowance.Balance = tx.Amount
+	case IncreaseAllowanceAddress:
+		increased, err := allowance.Balance.Add(tx.Amount)
+		if err != nil {
+			return err
+		}
+		allowance.Balance = increased
+	case DecreaseAllowanceAddress:
+		decreased, err := allowance.Balance.Sub(tx.Amount)
+		if err != nil {
+			return fmt.Errorf("%w: allowance of %s is only %s", ErrInsufficientAllowance, tx.Target, allowance.Balance)
+		}
+		allowance.Balance = decreased
+	default:
+		return errors.New("unknown allowance address")
+	}
+	execution.records[address] = allowance
+	return nil
+}
+
+// executePermit applies the permit carried by a transaction at the block's
+// timestamp, replacing the allowance of its spender.
+func (bc *Blockchain) executePermit(tx *Transaction, execution *blockExecution) error {
+	permit := tx.Permit
+	if permit == nil || tx.Target != "" {
+		return fmt.Errorf("%w: permit transactions carry a permit and no target", ErrInvalidPermit)
+	}
+	if err := permit.verify(); err != nil {
+		return err
+	}
+	if permit.ChainID != bc.ChainID {
+		return fmt.Errorf("%w: %v", ErrInvalidPermit, ErrWrongChainID)
+	}
+	if execution.timestamp > permit.Deadline {
+		return fmt.Errorf("%w: expired at %d", ErrInvalidPermit, permit.Deadline)
+	}
+	if permit.Spender == "" || permit.Amount < 0 {
+		return fmt.Errorf("%w: needs a spender and an allowance that is not negative", ErrInvalidPermit)
+	}
+
+	nonces := bc.loadRecord(execution, permitRecord(permit.Owner))
+	if permit.Nonce != nonces.Nonce {
+		return fmt.Errorf("%w: nonce %d, expected %d", ErrInvalidPermit, permit.Nonce, nonces.Nonce)
+	}
+	nonces.Nonce++
+	execution.records[permitRecord(permit.Owner)] = nonces
+
+	address := allowanceRecord(permit.Owner, permit.Spender)
+	allowance := bc.loadRecord(execution, address)
+	allowance.Balance = permit.Amount
+	execution.records[address] = allowance
+	return nil
+}
+
+// executeTransferFrom records a transfer of the owner named by a
+// transaction's target to its receiver, spending the sender's allowance. The
+// owner's coins locked by vesting cannot be transferred.
+func (bc *Blockchain) executeTransferFrom(tx *Transaction, execution *blockExecution) error {
+	owner := tx.Target
+	if isProtocolAddress(tx.Receiver) {
+		return errors.New("coins transferred from an allowance must go to an account")
+	}
+
+	address := allowanceRecord(owner, tx.Sender)
+	allowance := bc.loadRecord(execution, address)
+	left, err := allowance.Balance.Sub(tx.Amount)
+	if err != nil {
+		return fmt.Errorf("%w: %s may transfer %s of %s", ErrInsufficientAllowance, tx.Sender, allowance.Balance, owner)
+	}
+	bc.loadBalances(execution, owner)
+	remaining, err := execution.balances[owner].Sub(tx.Amount)
+	if err != nil {
+		return fmt.Errorf("insufficient balance of %s", owner)
+	}
+	if err := bc.checkLocked(owner, remaining, execution); err != nil {
+		return err
+	}
+
+	execution.balances[owner] = remaining
+	allowance.Balance = left
+	execution.records[address] = allowance
+	return nil
+}
+
+// GetAllowance returns the coins a spender may still transfer on an owner's
+// behalf.
+func (bc *Blockchain) GetAllowance(owner, spender string) Amount {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.records[allowanceRecord(owner, spender)].Balance
+}
+
+// GetPermitNonce returns the nonce the next permit of an owner must carry.
+func (bc *Blockchain) GetPermitNonce(owner string) uint64 {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.records[permitRecord(owner)].Nonce
+}
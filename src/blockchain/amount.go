@@ -0,0 +1,163 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// AmountDecimals is the number of decimal places of a BMT amount.
+const AmountDecimals = 7
+
+// Amount is a quantity of BMT held as an integer number of base units,
+// where one base unit is 0.0000001 BMT.
+type Amount int64
+
+// Common amounts.
+const (
+	BaseUnit Amount = 1          // Smallest representable amount (0.0000001 BMT)
+	OneBMT   Amount = 10_000_000 // One whole BMT
+)
+
+// Errors returned by checked Amount arithmetic.
+var (
+	ErrAmountOverflow  = errors.New("amount overflow")
+	ErrNegativeAmount  = errors.New("amount cannot be negative")
+	ErrDivisionByZero  = errors.New("amount division by zero")
+	ErrInvalidAmount   = errors.New("invalid amount")
+	errAmountPrecision = fmt.Errorf("%w: more than %d decimal places", ErrInvalidAmount, AmountDecimals)
+)
+
+// ParseAmount parses a decimal BMT string such as "12.5" or "0.0000001".
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return 0, ErrInvalidAmount
+	}
+	if len(fraction) > AmountDecimals {
+		return 0, errAmountPrecision
+	}
+	digits := whole + fraction + strings.Repeat("0", AmountDecimals-len(fraction))
+	if strings.ContainsAny(digits, "+-") {
+		return 0, ErrInvalidAmount
+	}
+
+	units, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, ErrAmountOverflow
+		}
+		return 0, ErrInvalidAmount
+	}
+	if negative {
+		units = -units
+	}
+	return Amount(units), nil
+}
+
+// String formats the amount in BMT with 7 decimal places.
+func (a Amount) String() string {
+	sign := ""
+	units := uint64(a)
+	if a < 0 {
+		sign = "-"
+		units = uint64(-a)
+	}
+	return fmt.Sprintf("%s%d.%07d", sign, units/uint64(OneBMT), units%uint64(OneBMT))
+}
+
+// MarshalJSON encodes the amount as a decimal string to avoid float rounding.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(a.String())), nil
+}
+
+// UnmarshalJSON accepts either a decimal string or a bare JSON number.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	parsed, err := ParseAmount(text)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Add returns a + b, failing on overflow.
+func (a Amount) Add(b Amount) (Amount, error) {
+	if b > 0 && a > math.MaxInt64-b || b < 0 && a < math.MinInt64-b {
+		return 0, ErrAmountOverflow
+	}
+	return a + b, nil
+}
+
+// Sub returns a - b, failing if the result would be negative or overflow.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b > a {
+		return 0, ErrNegativeAmount
+	}
+	if b < 0 && a > math.MaxInt64+b {
+		return 0, ErrAmountOverflow
+	}
+	return a - b, nil
+}
+
+// Mul returns a * n, failing on overflow.
+func (a Amount) Mul(n int64) (Amount, error) {
+	return a.MulDiv(n, 1)
+}
+
+// Div returns a / n rounded toward zero.
+func (a Amount) Div(n int64) (Amount, error) {
+	return a.MulDiv(1, n)
+}
+
+// MulDiv returns a * num / den rounded toward zero, computed without
+// intermediate overflow.
+func (a Amount) MulDiv(num, den int64) (Amount, error) {
+	if den == 0 {
+		return 0, ErrDivisionByZero
+	}
+	result := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(num))
+	result.Quo(result, big.NewInt(den))
+	return amountFromBigInt(result)
+}
+
+// MulRate returns a * rate rounded toward zero, treating the float rate as
+// an exact binary fraction so no rounding happens before truncation.
+func (a Amount) MulRate(rate float64) (Amount, error) {
+	if math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return 0, ErrInvalidAmount
+	}
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(a)), new(big.Rat).SetFloat64(rate))
+	return amountFromBigInt(new(big.Int).Quo(product.Num(), product.Denom()))
+}
+
+// DivRate returns a / rate rounded toward zero.
+func (a Amount) DivRate(rate float64) (Amount, error) {
+	if rate == 0 {
+		return 0, ErrDivisionByZero
+	}
+	if math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return 0, ErrInvalidAmount
+	}
+	quotient := new(big.Rat).Quo(new(big.Rat).SetInt64(int64(a)), new(big.Rat).SetFloat64(rate))
+	return amountFromBigInt(new(big.Int).Quo(quotient.Num(), quotient.Denom()))
+}
+
+// amountFromBigInt converts a big integer of base units to an Amount.
+func amountFromBigInt(units *big.Int) (Amount, error) {
+	if !units.IsInt64() {
+		return 0, ErrAmountOverflow
+	}
+	return Amount(units.Int64()), nil
+}
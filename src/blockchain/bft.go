@@ -0,0 +1,772 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Errors returned when consensus messages or commit certificates fail
+// verification.
+var (
+	ErrInvalidVote     = errors.New("invalid consensus vote")
+	ErrInvalidProposal = errors.New("invalid block proposal")
+	ErrNoQuorum        = errors.New("precommits do not reach a two-thirds stake quorum")
+	ErrMissingCommit   = errors.New("block lacks a valid commit certificate")
+)
+
+// maxFutureMessages bounds the messages held for heights not reached yet.
+const maxFutureMessages = 10000
+
+// VoteType is the kind of a consensus vote.
+type VoteType int
+
+// Vote types of a consensus round.
+const (
+	Prevote   VoteType = iota + 1 // First vote of a round, on the proposed block
+	Precommit                     // Second vote of a round, committing to a block
+)
+
+// String returns the name of the vote type.
+func (t VoteType) String() string {
+	switch t {
+	case Prevote:
+		return "prevote"
+	case Precommit:
+		return "precommit"
+	}
+	return "unknown"
+}
+
+// ConsensusVote is a validator's signed prevote or precommit in a round.
+type ConsensusVote struct {
+	Type      VoteType // Prevote or Precommit
+	Height    int      // Height of the block being decided
+	Round     int      // Round within the height
+	BlockHash string   // Hash of the block voted for, or empty for a nil vote
+	Validator string   // Address of the voting validator
+	Signature string   // Signature by the validator over the vote
+}
+
+// NewConsensusVote creates a vote and signs it with the validator's wallet.
+func NewConsensusVote(wallet *Wallet, chainID string, voteType VoteType, height, round int, blockHash string) (*ConsensusVote, error) {
+	vote := &ConsensusVote{Type: voteType, Height: height, Round: round, BlockHash: blockHash, Validator: wallet.Address}
+	signature, err := wallet.SignTransaction(vote.signingHash(chainID))
+	if err != nil {
+		return nil, err
+	}
+	vote.Signature = signature
+	return vote, nil
+}
+
+// signingHash returns the hash a vote's signature covers. The chain ID keeps
+// votes from being replayed on another chain.
+func (v *ConsensusVote) signingHash(chainID string) string {
+	record := chainID + v.Type.String() + strconv.Itoa(v.Height) + "/" + strconv.Itoa(v.Round) + "/" + v.BlockHash
+	hash := sha256.Sum256([]byte(record))
+	return hex.EncodeToString(hash[:])
+}
+
+// Verify checks that a vote was signed by a member of the validator set.
+func (v *ConsensusVote) Verify(chainID string, validators *ValidatorSet) error {
+	validator, ok := validators.Get(v.Validator)
+	if !ok {
+		return ErrUnknownValidator
+	}
+	if v.Type != Prevote && v.Type != Precommit {
+		return fmt.Errorf("%w: unknown vote type %d", ErrInvalidVote, v.Type)
+	}
+	valid, err := VerifySignature(validator.PublicKey, v.Signature, v.signingHash(chainID))
+	if err != nil || !valid {
+		return fmt.Errorf("%w: bad signature from %s", ErrInvalidVote, v.Validator)
+	}
+	return nil
+}
+
+// Proposal is a block proposed by the round's proposer.
+type Proposal struct {
+	Height     int    // Height of the proposed block
+	Round      int    // Round the proposal is made in
+	Block      *Block // The proposed block
+	ValidRound int    // Earlier round in which the block gathered a prevote quorum, or -1
+	Proposer   string // Address of the proposing validator
+	Signature  string // Signature by the proposer over the proposal
+}
+
+// NewProposal creates a proposal and signs it with the proposer's wallet.
+func NewProposal(wallet *Wallet, chainID string, height, round int, block *Block, validRound int) (*Proposal, error) {
+	proposal := &Proposal{Height: height, Round: round, Block: block, ValidRound: validRound, Proposer: wallet.Address}
+	signature, err := wallet.SignTransaction(proposal.signingHash(chainID))
+	if err != nil {
+		return nil, err
+	}
+	proposal.Signature = signature
+	return proposal, nil
+}
+
+// signingHash returns the hash a proposal's signature covers.
+func (p *Proposal) signingHash(chainID string) string {
+	record := chainID + "proposal" + strconv.Itoa(p.Height) + "/" + strconv.Itoa(p.Round) + "/" +
+		strconv.Itoa(p.ValidRound) + "/" + p.Block.Hash
+	hash := sha256.Sum256([]byte(record))
+	return hex.EncodeToString(hash[:])
+}
+
+// Verify checks that a proposal was made and signed by the proposer of its
+// round, for a block at its height crediting that proposer.
+func (p *Proposal) Verify(chainID string, validators *ValidatorSet) error {
+	if p.Block == nil || p.Block.Index != p.Height {
+		return fmt.Errorf("%w: block is not at height %d", ErrInvalidProposal, p.Height)
+	}
+	if p.ValidRound < -1 || p.ValidRound >= p.Round {
+		return fmt.Errorf("%w: valid round %d is not before round %d", ErrInvalidProposal, p.ValidRound, p.Round)
+	}
+	proposer := validators.Proposer(p.Height, p.Round)
+	if p.Proposer != proposer.Address || p.Block.Proposer != proposer.Address {
+		return fmt.Errorf("%w: %s is not the proposer of round %d", ErrInvalidProposal, p.Proposer, p.Round)
+	}
+	valid, err := VerifySignature(proposer.PublicKey, p.Signature, p.signingHash(chainID))
+	if err != nil || !valid {
+		return fmt.Errorf("%w: bad signature from %s", ErrInvalidProposal, p.Proposer)
+	}
+	return nil
+}
+
+// CommitCertificate proves that a block was committed by the validator set.
+// It is stored with the block, outside the block hash, and recorded again
+// by the next block as its LastCommit, inside that block's hash.
+type CommitCertificate struct {
+	Height     int             // Height of the committed block
+	Round      int             // Round in which the block was committed
+	BlockHash  string          // Hash of the committed block
+	Precommits []ConsensusVote // Precommits for the block from more than two thirds of the stake
+}
+
+// Verify checks that the precommits are signed by distinct validators, all
+// for the certified block, and together hold more than two thirds of the stake.
+func (cc *CommitCertificate) Verify(chainID string, validators *ValidatorSet) error {
+	signers := make(map[string]bool)
+	for i := range cc.Precommits {
+		vote := &cc.Precommits[i]
+		if vote.Type != Precommit || vote.Height != cc.Height || vote.Round != cc.Round || vote.BlockHash != cc.BlockHash {
+			return fmt.Errorf("%w: vote from %s is not a precommit for the certified block", ErrInvalidVote, vote.Validator)
+		}
+		if signers[vote.Validator] {
+			return fmt.Errorf("%w: %s precommitted twice", ErrInvalidVote, vote.Validator)
+		}
+		if err := vote.Verify(chainID, validators); err != nil {
+			return err
+		}
+		signers[vote.Validator] = true
+	}
+	if !validators.hasQuorum(validators.stakeOf(signers)) {
+		return ErrNoQuorum
+	}
+	return nil
+}
+
+// Hash returns the hash committing to the certificate, which is empty for a
+// nil certificate.
+func (cc *CommitCertificate) Hash() string {
+	if cc == nil {
+		return ""
+	}
+	record := fmt.Sprintf("%d:%d:%s", cc.Height, cc.Round, cc.BlockHash)
+	for _, vote := range cc.Precommits {
+		record += ":" + vote.Validator + "/" + vote.Signature
+	}
+	hash := sha256.Sum256([]byte(record))
+	return hex.EncodeToString(hash[:])
+}
+
+// checkCommit verifies the commit certificate of a block received from a
+// peer against the validators of its height. Blocks need one only once the
+// chain has a validator set.
+func (bc *Blockchain) checkCommit(block *Block) error {
+	validators := bc.ValidatorsAt(block.Index)
+	if validators == nil {
+		return nil
+	}
+	commit := block.Commit
+	if commit == nil || commit.Height != block.Index || commit.BlockHash != block.Hash {
+		return ErrMissingCommit
+	}
+	if err := commit.Verify(bc.ChainID, validators); err != nil {
+		return fmt.Errorf("%w: %v", ErrMissingCommit, err)
+	}
+	return nil
+}
+
+// checkLastCommit verifies the certificate of the parent block recorded by a
+// block. Blocks need not record one, but one they record must certify the
+// parent for the validators of its height.
+func (bc *Blockchain) checkLastCommit(block *Block) error {
+	commit := block.LastCommit
+	if commit == nil {
+		return nil
+	}
+	if commit.Height != block.Index-1 || commit.BlockHash != block.PreviousHash {
+		return invalidBlock(block, ErrMissingCommit, errors.New("last commit does not certify the parent block"))
+	}
+	validators := bc.ValidatorsAt(commit.Height)
+	if validators == nil {
+		return invalidBlock(block, ErrMissingCommit, fmt.Errorf("no validator set at height %d for the last commit", commit.Height))
+	}
+	if err := commit.Verify(bc.ChainID, validators); err != nil {
+		return invalidBlock(block, ErrMissingCommit, fmt.Errorf("last commit: %w", err))
+	}
+	return nil
+}
+
+// BuildProposal builds a block of the given transactions on top of the
+// canonical tip for a proposer, with the state root it will produce. The
+// block is not added to the chain.
+func (bc *Blockchain) BuildProposal(proposer string, transactions []*Transaction) (*Block, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	var blockTransactions []Transaction
+	for _, tx := range transactions {
+		blockTransactions = append(blockTransactions, *tx)
+	}
+	block := bc.newChildBlock(blockTransactions)
+	block.Proposer = proposer
+	root, err := bc.stateRootAfter(block)
+	if err != nil {
+		return nil, err
+	}
+	block.StateRoot = root
+	block.Hash = block.CalculateHash()
+	return block, nil
+}
+
+// ValidateProposal checks that a proposed block extends the canonical tip
+// and executes to the state root in its header, without adding it.
+func (bc *Blockchain) ValidateProposal(block *Block) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if err := bc.validateBlock(block); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProposal, err)
+	}
+	return nil
+}
+
+// RoundStep is the step a validator has reached in a consensus round.
+type RoundStep int
+
+// Steps of a consensus round.
+const (
+	StepPropose   RoundStep = iota // Waiting for the round's proposal
+	StepPrevote                    // Prevoted, waiting for a prevote quorum
+	StepPrecommit                  // Precommitted, waiting for a precommit quorum
+)
+
+// BFTConfig holds the timeouts and limits of the BFT consensus.
+type BFTConfig struct {
+	ProposeTimeout   time.Duration // Wait for the round's proposal before prevoting nil
+	PrevoteTimeout   time.Duration // Wait after two thirds have prevoted before precommitting nil
+	PrecommitTimeout time.Duration // Wait after two thirds have precommitted before the next round
+	TimeoutDelta     time.Duration // Added to every timeout per round, so rounds lengthen until the network catches up
+	MaxTransactions  int           // Most transactions a proposed block takes from the transaction source
+}
+
+// DefaultBFTConfig returns the default consensus timeouts.
+func DefaultBFTConfig() BFTConfig {
+	return BFTConfig{
+		ProposeTimeout:   3 * time.Second,
+		PrevoteTimeout:   time.Second,
+		PrecommitTimeout: time.Second,
+		TimeoutDelta:     500 * time.Millisecond,
+		MaxTransactions:  MaxTransactionsPerBlock,
+	}
+}
+
+// ConsensusMessage is a proposal or a vote exchanged between validators.
+type ConsensusMessage struct {
+	Proposal *Proposal      // A block proposal, if set
+	Vote     *ConsensusVote // A prevote or precommit, if set
+}
+
+// height returns the height a message is for.
+func (m ConsensusMessage) height() int {
+	if m.Proposal != nil {
+		return m.Proposal.Height
+	}
+	return m.Vote.Height
+}
+
+// voteKey identifies the votes of one type in one round.
+type voteKey struct {
+	round    int
+	voteType VoteType
+}
+
+// ruleKey identifies a consensus rule that fires at most once per round.
+type ruleKey struct {
+	round int
+	rule  string
+}
+
+// BFTConsensus runs propose, prevote and precommit rounds among the chain's
+// validator set until more than two thirds of the stake precommits a block,
+// which is then committed to the chain with a certificate of those
+// precommits. A validator locks on a block it precommits and only prevotes
+// another block once a later round shows a prevote quorum for it, so two
+// blocks can never be committed at the same height while more than two
+// thirds of the stake is honest.
+type BFTConsensus struct {
+	Chain        *Blockchain                           // Chain decided blocks are committed to, whose validator set votes
+	Config       BFTConfig                             // Timeouts and block limits
+	Broadcast    func(ConsensusMessage)                // Sends a message to every other validator
+	Transactions func(max int) []*Transaction          // Source of transactions to propose, such as Mempool.Pending
+	OnError      func(error)                           // Receives the errors the consensus recovers from, with the engine locked
+	wallet       *Wallet                               // Keys of the local validator, nil for an observer
+	validators   *ValidatorSet                         // Validators of the height being decided
+	height       int                                   // Height being decided
+	round        int                                   // Current round at that height
+	step         RoundStep                             // Current step in the round
+	lockedRound  int                                   // Round the locked block was precommitted in, or -1
+	lockedBlock  *Block                                // Block the validator is locked on
+	validRound   int                                   // Latest round with a prevote quorum for its proposal, or -1
+	validBlock   *Block                                // Block of that round, proposed again by this validator
+	proposals    map[int]*Proposal                     // Proposals at the current height by round
+	votes        map[voteKey]map[string]*ConsensusVote // Votes at the current height by round and type, then validator
+	fired        map[ruleKey]bool                      // Once-per-round rules that have fired
+	validated    map[string]error                      // Validation result by block hash
+	equivocation []*Evidence                           // Conflicting votes seen at the current height, submitted once it is decided
+	future       []ConsensusMessage                    // Messages for later heights
+	outbox       []ConsensusMessage                    // Messages to broadcast once the mutex is released
+	timers       []*time.Timer                         // Pending timeouts of the current height
+	running      bool
+	mutex        sync.Mutex
+}
+
+// NewBFTConsensus creates the consensus engine of a validator. A nil wallet
+// follows the consensus without proposing or voting.
+func NewBFTConsensus(chain *Blockchain, wallet *Wallet, config BFTConfig) (*BFTConsensus, error) {
+	if chain.ValidatorsAt(chain.GetLatestBlock().Index+1) == nil {
+		return nil, errors.New("chain has no validator set")
+	}
+	return &BFTConsensus{Chain: chain, Config: config, wallet: wallet}, nil
+}
+
+// Start begins deciding the block after the chain's tip.
+func (bft *BFTConsensus) Start() {
+	bft.mutex.Lock()
+	bft.running = true
+	bft.startHeight(bft.Chain.GetLatestBlock().Index + 1)
+	bft.evaluate()
+	outbox := bft.takeOutbox()
+	bft.mutex.Unlock()
+	bft.send(outbox)
+}
+
+// Stop halts the consensus and cancels its timeouts.
+func (bft *BFTConsensus) Stop() {
+	bft.mutex.Lock()
+	defer bft.mutex.Unlock()
+
+	bft.running = false
+	bft.stopTimers()
+}
+
+// State returns the height, round and step the consensus has reached.
+func (bft *BFTConsensus) State() (height, round int, step RoundStep) {
+	bft.mutex.Lock()
+	defer bft.mutex.Unlock()
+	return bft.height, bft.round, bft.step
+}
+
+// HandleMessage processes a proposal or vote received from another
+// validator. Messages for earlier heights are ignored and messages for later
+// heights are held until the consensus reaches them.
+func (bft *BFTConsensus) HandleMessage(message ConsensusMessage) error {
+	bft.mutex.Lock()
+	err := bft.handle(message)
+	outbox := bft.takeOutbox()
+	bft.mutex.Unlock()
+	bft.send(outbox)
+	return err
+}
+
+// handle records a message and applies the consensus rules to it.
+func (bft *BFTConsensus) handle(message ConsensusMessage) error {
+	if !bft.running {
+		return errors.New("consensus is not running")
+	}
+	if (message.Proposal == nil) == (message.Vote == nil) {
+		return errors.New("consensus message must hold either a proposal or a vote")
+	}
+	height := message.height()
+	if height < bft.height {
+		return nil
+	}
+	if height > bft.height {
+		if len(bft.future) >= maxFutureMessages {
+			return fmt.Errorf("too many messages held for later heights, dropping one for height %d", height)
+		}
+		bft.future = append(bft.future, message)
+		return nil
+	}
+	if err := bft.record(message); err != nil {
+		return err
+	}
+	bft.evaluate()
+	return nil
+}
+
+// record verifies a message for the current height and stores it. Only the
+// first proposal of a round and the first vote of each validator count; a
+// validator's second vote for another block is kept as evidence.
+func (bft *BFTConsensus) record(message ConsensusMessage) error {
+	validators := bft.validators
+	if proposal := message.Proposal; proposal != nil {
+		if err := proposal.Verify(bft.Chain.ChainID, validators); err != nil {
+			return err
+		}
+		if _, seen := bft.proposals[proposal.Round]; !seen {
+			bft.proposals[proposal.Round] = proposal
+		}
+		return nil
+	}
+
+	vote := message.Vote
+	if err := vote.Verify(bft.Chain.ChainID, validators); err != nil {
+		return err
+	}
+	key := voteKey{round: vote.Round, voteType: vote.Type}
+	if bft.votes[key] == nil {
+		bft.votes[key] = make(map[string]*ConsensusVote)
+	}
+	seen, ok := bft.votes[key][vote.Validator]
+	if !ok {
+		bft.votes[key][vote.Validator] = vote
+	} else if seen.BlockHash != vote.BlockHash {
+		for _, evidence := range bft.equivocation {
+			if evidence.Validator == vote.Validator {
+				return nil
+			}
+		}
+		bft.equivocation = append(bft.equivocation, NewDuplicateVoteEvidence(seen, vote))
+	}
+	return nil
+}
+
+// startHeight resets the round state for a new height and replays the
+// messages held for it.
+func (bft *BFTConsensus) startHeight(height int) {
+	bft.stopTimers()
+	bft.height = height
+	bft.validators = bft.Chain.ValidatorsAt(height)
+	bft.lockedRound, bft.lockedBlock = -1, nil
+	bft.validRound, bft.validBlock = -1, nil
+	bft.proposals = make(map[int]*Proposal)
+	bft.votes = make(map[voteKey]map[string]*ConsensusVote)
+	bft.fired = make(map[ruleKey]bool)
+	bft.validated = make(map[string]error)
+	bft.equivocation = nil
+	bft.startRound(0)
+
+	held := bft.future
+	bft.future = nil
+	for _, message := range held {
+		switch {
+		case message.height() > height:
+			bft.future = append(bft.future, message)
+		case message.height() == height:
+			if err := bft.record(message); err != nil {
+				bft.report(fmt.Errorf("dropping held consensus message: %v", err))
+			}
+		}
+	}
+}
+
+// startRound moves to a new round at the current height, proposing a block
+// if this validator is the round's proposer. The block that last gathered a
+// prevote quorum is proposed again rather than a new one.
+func (bft *BFTConsensus) startRound(round int) {
+	bft.round = round
+	bft.step = StepPropose
+
+	proposer := bft.validators.Proposer(bft.height, round)
+	if bft.wallet != nil && bft.wallet.Address == proposer.Address {
+		block := bft.validBlock
+		if block == nil {
+			block = bft.buildBlock()
+		}
+		if block != nil {
+			proposal, err := NewProposal(bft.wallet, bft.Chain.ChainID, bft.height, round, block, bft.validRound)
+			if err != nil {
+				bft.report(fmt.Errorf("error signing proposal: %v", err))
+			} else {
+				bft.proposals[round] = proposal
+				bft.outbox = append(bft.outbox, ConsensusMessage{Proposal: proposal})
+			}
+		}
+	}
+	bft.schedule(StepPropose, round, bft.Config.ProposeTimeout)
+}
+
+// buildBlock builds a block to propose from the transaction source, falling
+// back to an empty block if the transactions do not execute.
+func (bft *BFTConsensus) buildBlock() *Block {
+	var transactions []*Transaction
+	if bft.Transactions != nil {
+		transactions = bft.Transactions(bft.Config.MaxTransactions)
+	}
+	block, err := bft.Chain.BuildProposal(bft.wallet.Address, transactions)
+	if err != nil && len(transactions) > 0 {
+		bft.report(fmt.Errorf("error building proposal with %d transactions, proposing an empty block: %v", len(transactions), err))
+		block, err = bft.Chain.BuildProposal(bft.wallet.Address, nil)
+	}
+	if err != nil {
+		bft.report(fmt.Errorf("error building proposal: %v", err))
+		return nil
+	}
+	if block.Index != bft.height {
+		// The chain moved on without this consensus instance
+		return nil
+	}
+	return block
+}
+
+// evaluate applies the consensus rules until none of them changes the state.
+func (bft *BFTConsensus) evaluate() {
+	for bft.running && bft.applyRule() {
+	}
+}
+
+// applyRule applies the first consensus rule whose conditions are met and
+// reports whether one was applied.
+func (bft *BFTConsensus) applyRule() bool {
+	validators := bft.validators
+
+	// A precommit quorum for a proposal decides the height in any round
+	for round, proposal := range bft.proposals {
+		precommits, _ := bft.tally(round, Precommit)
+		if validators.hasQuorum(precommits[proposal.Block.Hash]) && bft.validate(proposal.Block) == nil {
+			bft.decide(proposal.Block, round)
+			return true
+		}
+	}
+
+	// Votes from more than a third of the stake in a later round mean this
+	// validator has fallen behind
+	for key := range bft.votes {
+		if key.round > bft.round && validators.exceedsOneThird(bft.roundStake(key.round)) {
+			bft.startRound(key.round)
+			return true
+		}
+	}
+
+	proposal := bft.proposals[bft.round]
+	prevotes, prevoteStake := bft.tally(bft.round, Prevote)
+	if bft.step == StepPropose {
+		return proposal != nil && bft.prevoteProposal(proposal)
+	}
+
+	if bft.step == StepPrevote && validators.hasQuorum(prevoteStake) && bft.once("prevote timeout") {
+		bft.schedule(StepPrevote, bft.round, bft.Config.PrevoteTimeout)
+	}
+	if proposal != nil && validators.hasQuorum(prevotes[proposal.Block.Hash]) &&
+		bft.validate(proposal.Block) == nil && bft.once("prevote quorum") {
+		if bft.step == StepPrevote {
+			bft.lockedRound, bft.lockedBlock = bft.round, proposal.Block
+			bft.vote(Precommit, proposal.Block.Hash)
+			bft.step = StepPrecommit
+		}
+		bft.validRound, bft.validBlock = bft.round, proposal.Block
+		return true
+	}
+	if bft.step == StepPrevote && validators.hasQuorum(prevotes[""]) {
+		bft.vote(Precommit, "")
+		bft.step = StepPrecommit
+		return true
+	}
+
+	_, precommitStake := bft.tally(bft.round, Precommit)
+	if validators.hasQuorum(precommitStake) && bft.once("precommit timeout") {
+		bft.schedule(StepPrecommit, bft.round, bft.Config.PrecommitTimeout)
+	}
+	return false
+}
+
+// prevoteProposal prevotes on the round's proposal and reports whether it
+// did. A validator locked on another block prevotes nil, unless the proposal
+// carries a prevote quorum from a round after the lock. A re-proposed block
+// waits until that quorum has been seen.
+func (bft *BFTConsensus) prevoteProposal(proposal *Proposal) bool {
+	block := proposal.Block
+	acceptable := bft.lockedRound < 0 || bft.lockedBlock.Hash == block.Hash
+	if proposal.ValidRound >= 0 {
+		prevotes, _ := bft.tally(proposal.ValidRound, Prevote)
+		if !bft.validators.hasQuorum(prevotes[block.Hash]) {
+			return false
+		}
+		acceptable = acceptable || bft.lockedRound <= proposal.ValidRound
+	}
+
+	hash := ""
+	if acceptable && bft.validate(block) == nil {
+		hash = block.Hash
+	}
+	bft.vote(Prevote, hash)
+	bft.step = StepPrevote
+	return true
+}
+
+// decide commits a block with a certificate of the precommits for it and
+// moves on to the next height.
+func (bft *BFTConsensus) decide(block *Block, round int) {
+	commit := &CommitCertificate{Height: block.Index, Round: round, BlockHash: block.Hash}
+	for _, vote := range bft.votes[voteKey{round: round, voteType: Precommit}] {
+		if vote.BlockHash == block.Hash {
+			commit.Precommits = append(commit.Precommits, *vote)
+		}
+	}
+	sort.Slice(commit.Precommits, func(i, j int) bool { return commit.Precommits[i].Validator < commit.Precommits[j].Validator })
+
+	committed := *block
+	committed.Commit = commit
+	if _, err := bft.Chain.ProcessBlock(&committed); err != nil && !errors.Is(err, ErrKnownBlock) {
+		bft.report(fmt.Errorf("error committing block %d: %v", block.Index, err))
+	}
+	for _, evidence := range bft.equivocation {
+		if err := bft.Chain.SubmitEvidence(evidence); err != nil {
+			bft.report(fmt.Errorf("error submitting evidence against %s: %v", evidence.Validator, err))
+		}
+	}
+	bft.startHeight(bft.Chain.GetLatestBlock().Index + 1)
+}
+
+// onTimeout handles a timeout, ignoring it if the consensus has moved on
+// since it was scheduled.
+func (bft *BFTConsensus) onTimeout(height, round int, step RoundStep) {
+	bft.mutex.Lock()
+	if bft.running && height == bft.height && round == bft.round {
+		switch {
+		case step == StepPropose && bft.step == StepPropose:
+			bft.vote(Prevote, "")
+			bft.step = StepPrevote
+		case step == StepPrevote && bft.step == StepPrevote:
+			bft.vote(Precommit, "")
+			bft.step = StepPrecommit
+		case step == StepPrecommit:
+			bft.startRound(round + 1)
+		}
+		bft.evaluate()
+	}
+	outbox := bft.takeOutbox()
+	bft.mutex.Unlock()
+	bft.send(outbox)
+}
+
+// schedule starts a timeout for a step of a round, lengthened by the round.
+func (bft *BFTConsensus) schedule(step RoundStep, round int, timeout time.Duration) {
+	height := bft.height
+	timeout += time.Duration(round) * bft.Config.TimeoutDelta
+	bft.timers = append(bft.timers, time.AfterFunc(timeout, func() { bft.onTimeout(height, round, step) }))
+}
+
+// stopTimers cancels the pending timeouts.
+func (bft *BFTConsensus) stopTimers() {
+	for _, timer := range bft.timers {
+		timer.Stop()
+	}
+	bft.timers = nil
+}
+
+// once reports whether a rule fires for the first time in the current round.
+func (bft *BFTConsensus) once(rule string) bool {
+	key := ruleKey{round: bft.round, rule: rule}
+	if bft.fired[key] {
+		return false
+	}
+	bft.fired[key] = true
+	return true
+}
+
+// vote signs a vote for the current round, records it and queues it for
+// broadcast. Observers and validators outside the set do not vote.
+func (bft *BFTConsensus) vote(voteType VoteType, blockHash string) {
+	if bft.wallet == nil {
+		return
+	}
+	if _, ok := bft.validators.Get(bft.wallet.Address); !ok {
+		return
+	}
+	vote, err := NewConsensusVote(bft.wallet, bft.Chain.ChainID, voteType, bft.height, bft.round, blockHash)
+	if err != nil {
+		bft.report(fmt.Errorf("error signing %s: %v", voteType, err))
+		return
+	}
+	bft.record(ConsensusMessage{Vote: vote})
+	bft.outbox = append(bft.outbox, ConsensusMessage{Vote: vote})
+}
+
+// tally returns the stake of the votes of one type in a round by block hash,
+// and the stake of all of them.
+func (bft *BFTConsensus) tally(round int, voteType VoteType) (map[string]Amount, Amount) {
+	byHash := make(map[string]Amount)
+	var total Amount
+	for address, vote := range bft.votes[voteKey{round: round, voteType: voteType}] {
+		validator, _ := bft.validators.Get(address)
+		byHash[vote.BlockHash] += validator.Stake
+		total += validator.Stake
+	}
+	return byHash, total
+}
+
+// roundStake returns the stake of the validators that have voted in a round.
+func (bft *BFTConsensus) roundStake(round int) Amount {
+	voters := make(map[string]bool)
+	for _, voteType := range []VoteType{Prevote, Precommit} {
+		for address := range bft.votes[voteKey{round: round, voteType: voteType}] {
+			voters[address] = true
+		}
+	}
+	return bft.validators.stakeOf(voters)
+}
+
+// validate checks a proposed block against the chain, once per block.
+func (bft *BFTConsensus) validate(block *Block) error {
+	if err, done := bft.validated[block.Hash]; done {
+		return err
+	}
+	err := bft.Chain.ValidateProposal(block)
+	if err != nil {
+		bft.report(fmt.Errorf("rejecting proposed block %s: %v", block.Hash, err))
+	}
+	bft.validated[block.Hash] = err
+	return err
+}
+
+// report passes an error the consensus recovered from to OnError, if set.
+func (bft *BFTConsensus) report(err error) {
+	if bft.OnError != nil {
+		bft.OnError(err)
+	}
+}
+
+// takeOutbox returns the queued messages and empties the queue.
+func (bft *BFTConsensus) takeOutbox() []ConsensusMessage {
+	outbox := bft.outbox
+	bft.outbox = nil
+	return outbox
+}
+
+// send broadcasts messages to the other validators.
+func (bft *BFTConsensus) send(messages []ConsensusMessage) {
+	if bft.Broadcast == nil {
+		return
+	}
+	for _, message := range messages {
+		bft.Broadcast(message)
+	}
+}
@@ -2,11 +2,8 @@
 package blockchain
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"fmt"
-	"math/rand"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,136 +16,124 @@ const (
 	MaxSubBlockSize    = 1 * 1024 * 1024  // 1 MB
 	MaxMiniBlockSize   = 100 * 1024       // 0.1 MB
 	MaxTransactionsPerBlock = 10000       // Example max transactions per block
-	TargetMiningTime   = 0.01             // Target time (in seconds) for mining
+	TargetMiningTime   = 0.01             // Target time (in seconds) for mining a mini-block
 	MaxMiniBlocks      = 10               // Maximum number of mini-blocks per sub-block
 )
 
-// TrustedValidators represents a list of staking nodes.
-var TrustedValidators = map[string]bool{
-	"staking-node-1": true,
-	"staking-node-2": true,
-	"staking-node-3": true,
-}
-
-// Miners represents a list of mining nodes.
-var Miners = []string{
-	"miner-node-1",
-	"miner-node-2",
-	"miner-node-3",
-}
-
-// Transaction represents a single transaction in the blockchain.
-type Transaction struct {
-	Sender    string  // Address of the sender
-	Receiver  string  // Address of the receiver
-	Amount    float64 // Amount being transferred
-	Timestamp time.Time // Time of transaction creation
-	Signature string  // Digital signature for transaction integrity
-}
-
 // MiniBlock represents a mini block within the blockchain.
 type MiniBlock struct {
-	Index        int            // Position of the mini-block in the blockchain
-	Transactions []Transaction  // Transactions included in the mini-block
-	Hash         string         // Hash of the mini-block
-	CurrentSize  int            // Current size of the mini-block in bytes
-	IsFull       bool           // Indicates whether the mini-block is full
-	Key          string         // Unique key for the mini-block
-	MerkleRoot   string         // Merkle root for transactions integrity
+	Index        int           // Position of the mini-block within its sub-block
+	Transactions []Transaction // Transactions included in the mini-block
+	Hash         string        // Hash of the mini-block
+	CurrentSize  int           // Current size of the mini-block in bytes
+	IsFull       bool          // Indicates whether the mini-block is full
+	Key          string        // Unique key for the mini-block
+	MerkleRoot   string        // Merkle root for transactions integrity
+	Nonce        uint64        // Proof-of-work nonce included in the hash
+	SolveTime    int64         // Nanoseconds the miner spent finding the nonce
 }
 
 // SubBlock represents a medium block containing multiple mini-blocks.
 type SubBlock struct {
-	Index      int        // Position of the sub-block
+	Index      int         // Position of the sub-block within its block
 	MiniBlocks []MiniBlock // Mini-blocks within this sub-block
-	Hash       string     // Unique hash of the sub-block
-	Key        string     // Unique key for the sub-block
+	Hash       string      // Unique hash of the sub-block
+	Key        string      // Unique key for the sub-block
+	MerkleRoot string      // Merkle root over the mini-block Merkle roots
 }
 
-// MainBlock represents a large block containing multiple sub-blocks.
-type MainBlock struct {
-	Index     int        // Position of the main block
-	SubBlocks []SubBlock // Sub-blocks within this main block
-	Hash      string     // Unique hash of the main block
-	Key       string     // Unique key for the main block
+// BlockHeader holds the fields committed to by a block hash.
+type BlockHeader struct {
+	Index          int    // Height of the block in the chain
+	Timestamp      int64  // Unix timestamp of block creation
+	PreviousHash   string // Hash of the previous block
+	MerkleRoot     string // Merkle root over the sub-block Merkle roots
+	StateRoot      string // Root of the account state trie after the block
+	BaseFee        Amount // Base fee per gas paid by transactions in the block
+	Proposer       string // Address credited with the transaction tips
+	Difficulty     int    // Leading zero bits required of every mini-block hash
+	EvidenceRoot   string // Merkle root over the hashes of the block's evidence, if any
+	LastCommitHash string // Hash of the parent's commit certificate recorded by the block, if any
+	SolveTime      int64  // Total nanoseconds spent mining the block's mini-blocks, which retargeting averages
 }
 
-// Mutex to synchronize mining and consensus operations
-var mutex sync.Mutex
-var previousMiningTime = TargetMiningTime // Initialize with target time
-var processedTransactions sync.Map        // Map to track processed transactions using sync.Map for concurrency safety
-var availableMiniBlocks []MiniBlock       // List of available mini-blocks (not full)
-var fullMiniBlocks []MiniBlock            // List of full mini-blocks
+// BlockBody holds the tiered contents of a block.
+type BlockBody struct {
+	SubBlocks []SubBlock // Sub-blocks within this block
+	Evidence  []Evidence // Evidence of validator misbehaviour punished by this block
+}
 
-// updateMiniBlockLists updates the lists of available and full mini-blocks.
-func updateMiniBlockLists(miniBlock *MiniBlock, selectedIndex int) {
-	if miniBlock.IsFull {
-		fullMiniBlocks = append(fullMiniBlocks, *miniBlock)
-		availableMiniBlocks = append(availableMiniBlocks[:selectedIndex], availableMiniBlocks[selectedIndex+1:]...)
-	} else {
-		availableMiniBlocks[selectedIndex] = *miniBlock
-	}
+// Block is a main block on the chain. Its header commits to its sub-blocks,
+// and each sub-block commits to its mini-blocks, through nested Merkle roots.
+type Block struct {
+	BlockHeader
+	BlockBody
+	Hash       string             // Hash of the block header
+	Key        string             // Unique key for the block
+	Commit     *CommitCertificate // Precommits of the validators that committed the block, if any
+	LastCommit *CommitCertificate // Precommits that committed the parent block, recorded by the proposer
 }
 
-// ValidateTransactionWithFastConsensus performs fast consensus using a small group of validators.
+// Mutex to synchronize mining and consensus operations
+var mutex sync.Mutex
+
+// ValidateTransactionWithFastConsensus checks a batch of transactions before
+// it is mined into a mini-block. Blocks are agreed on by BFTConsensus, so only
+// the integrity of each transaction is checked here.
 func ValidateTransactionWithFastConsensus(transactions []Transaction) bool {
 	if len(transactions) == 0 {
 		return false
 	}
-	requiredVotes := 1
-	votes := 0
-	for validator := range TrustedValidators {
-		votes++
-		if votes >= requiredVotes {
-			break
+	for i := range transactions {
+		if !transactions[i].Validate() {
+			return false
 		}
 	}
-	time.Sleep(10 * time.Millisecond) // Simulate 0.01 second delay for consensus
 	return true
 }
 
-// MineTransaction processes transactions and assigns them to a mini-block in a sub-block.
-func MineTransaction(transactions []Transaction, mainBlock *MainBlock) (*MiniBlock, error) {
-	txID := generateTransactionID(transactions)
-	if _, loaded := processedTransactions.LoadOrStore(txID, true); loaded {
-		return nil, errors.New("transaction already processed")
+// MineTransaction mines the transactions into a new mini-block at the main
+// block's difficulty and appends it to the block's last sub-block, opening a
+// new sub-block when the last one is full. The block's Merkle roots, keys and
+// hash are updated to cover the new mini-block.
+func MineTransaction(transactions []Transaction, mainBlock *Block) (*MiniBlock, error) {
+	totalTransactionSize := calculateTransactionsSize(transactions)
+	if totalTransactionSize > MaxMiniBlockSize {
+		return nil, errors.New("mini-block size exceeded")
 	}
 
-	if len(availableMiniBlocks) == 0 {
-		return nil, errors.New("no available mini-block to record transaction")
-	}
+	mutex.Lock()
+	defer mutex.Unlock()
 
-	// Randomly select an available mini-block without locking the entire function
-	selectedIndex := rand.Intn(len(availableMiniBlocks))
-	mutex.Lock() // Lock only for critical section to update mini-block
-	miniBlock := &availableMiniBlocks[selectedIndex]
-	totalTransactionSize := calculateTransactionsSize(transactions)
-	if miniBlock.CurrentSize+totalTransactionSize > MaxMiniBlockSize {
-		mutex.Unlock()
-		return nil, errors.New("mini-block size exceeded")
+	if mainBlock.containsAny(transactions) {
+		return nil, errors.New("transaction already processed")
+	}
+	if mainBlock.Size()+totalTransactionSize > MaxBlockSize ||
+		len(mainBlock.Transactions())+len(transactions) > MaxTransactionsPerBlock {
+		return nil, errors.New("block size exceeded")
 	}
 
-	miniBlock.Transactions = append(miniBlock.Transactions, transactions...)
-	miniBlock.CurrentSize += totalTransactionSize
-	if miniBlock.CurrentSize == MaxMiniBlockSize {
-		miniBlock.IsFull = true
+	subBlocks := mainBlock.SubBlocks
+	if len(subBlocks) == 0 || len(subBlocks[len(subBlocks)-1].MiniBlocks) >= MaxMiniBlocks ||
+		subBlocks[len(subBlocks)-1].Size()+totalTransactionSize > MaxSubBlockSize {
+		mainBlock.SubBlocks = append(mainBlock.SubBlocks, SubBlock{})
 	}
-	updateMiniBlockLists(miniBlock, selectedIndex)
-	mutex.Unlock()
+	subBlock := &mainBlock.SubBlocks[len(mainBlock.SubBlocks)-1]
 
-	miniBlock.MerkleRoot = calculateMerkleRoot(miniBlock.Transactions)
-	suffix := calculateDynamicDifficulty()
-	for {
-		miniBlock.Hash = calculateMiniBlockHash(miniBlock.Index, miniBlock.Transactions)
-		if isValidHash(miniBlock.Hash, suffix) {
-			break
-		}
+	miniBlock := MiniBlock{
+		Index:        len(subBlock.MiniBlocks),
+		Transactions: transactions,
+		CurrentSize:  totalTransactionSize,
+		MerkleRoot:   calculateMerkleRoot(transactions),
 	}
-	return miniBlock, nil
+	mineMiniBlock(&miniBlock, mainBlock.PreviousHash, mainBlock.Difficulty)
+	subBlock.MiniBlocks = append(subBlock.MiniBlocks, miniBlock)
+	mainBlock.finalize()
+	return &subBlock.MiniBlocks[miniBlock.Index], nil
 }
 
-// NewTransaction handles the full lifecycle of a transaction.
-func NewTransaction(transactions []Transaction, mainBlock *MainBlock) error {
+// SubmitTransactions handles the full lifecycle of a batch of transactions.
+func SubmitTransactions(transactions []Transaction, mainBlock *Block) error {
 	if !ValidateTransactionWithFastConsensus(transactions) {
 		return errors.New("transaction validation failed")
 	}
@@ -161,7 +146,7 @@ func NewTransaction(transactions []Transaction, mainBlock *MainBlock) error {
 }
 
 // ProcessTransactionsConcurrently processes transactions concurrently using unlimited Goroutines.
-func ProcessTransactionsConcurrently(transactions []Transaction, mainBlock *MainBlock) {
+func ProcessTransactionsConcurrently(transactions []Transaction, mainBlock *Block) {
 	var wg sync.WaitGroup
 	transactionChannel := make(chan Transaction, len(transactions))
 
@@ -174,79 +159,226 @@ func ProcessTransactionsConcurrently(transactions []Transaction, mainBlock *Main
 		wg.Add(1)
 		go func(tx Transaction) {
 			defer wg.Done()
-			_ = NewTransaction([]Transaction{tx}, mainBlock)
+			_ = SubmitTransactions([]Transaction{tx}, mainBlock)
 		}(tx)
 	}
 	wg.Wait()
 }
 
-// calculateMiniBlockHash generates the hash for a mini-block.
-func calculateMiniBlockHash(index int, transactions []Transaction) string {
-	record := strconv.Itoa(index) + concatTransactions(transactions)
-	hash := sha256.Sum256([]byte(record))
-	return hex.EncodeToString(hash[:])
+// calculateMiniBlockHash generates the hash for a mini-block mined on a parent
+// block with a given nonce.
+func calculateMiniBlockHash(previousHash string, index int, nonce uint64, transactions []Transaction) string {
+	return hashFields(append(miniBlockRecord(previousHash, index, transactions), strconv.FormatUint(nonce, 10))...)
+}
+
+// miniBlockRecord is the part of a mini-block's hash input that does not
+// change while searching for a nonce: the parent block, so that the work
+// cannot be reused on another branch, the mini-block's position and the
+// Merkle root of its transactions.
+func miniBlockRecord(previousHash string, index int, transactions []Transaction) []string {
+	return []string{previousHash, strconv.Itoa(index), calculateMerkleRoot(transactions)}
 }
 
 // calculateTransactionsSize calculates the total size of a list of transactions in bytes.
 func calculateTransactionsSize(transactions []Transaction) int {
 	size := 0
 	for _, tx := range transactions {
-		size += len(tx.Sender) + len(tx.Receiver) + 8 + len(tx.Timestamp.String()) + len(tx.Signature)
+		size += len(tx.Sender) + len(tx.Receiver) + 8 + 8 + 8 + 8 + len(tx.ChainID) + len(tx.PublicKey) + len(tx.Signature) + len(tx.Hash)
 	}
 	return size
 }
 
-// calculateDynamicDifficulty adjusts the difficulty based on the target mining time.
-func calculateDynamicDifficulty() string {
-	return "00" // Simulate fast mining with fixed difficulty
+// containsAny reports whether any of the transactions is already mined into
+// the block, so the same transaction is never included twice.
+func (b *Block) containsAny(transactions []Transaction) bool {
+	mined := make(map[string]bool)
+	for _, tx := range b.Transactions() {
+		mined[tx.CalculateHash()] = true
+	}
+	for i := range transactions {
+		if mined[transactions[i].CalculateHash()] {
+			return true
+		}
+	}
+	return false
 }
 
-// concatTransactions concatenates all transactions into a single string.
-func concatTransactions(transactions []Transaction) string {
-	var builder strings.Builder
-	for _, tx := range transactions {
-		builder.WriteString(tx.Sender)
-		builder.WriteString(tx.Receiver)
-		builder.WriteString(strconv.FormatFloat(tx.Amount, 'f', 2, 64))
-		builder.WriteString(tx.Timestamp.String())
-		builder.WriteString(tx.Signature)
+// GenesisTimestamp is the fixed creation time of the genesis block, so that
+// every node derives the same genesis hash.
+const GenesisTimestamp int64 = 1735689600 // 2025-01-01T00:00:00Z
+
+// NewBlock creates a block at the given height, packing the transactions into
+// mini-blocks mined at InitialDifficulty and sub-blocks, and committing to
+// them in the header.
+func NewBlock(index int, transactions []Transaction, previousHash string) *Block {
+	return newBlockAt(index, time.Now().Unix(), transactions, previousHash, InitialDifficulty)
+}
+
+// NewGenesisBlock creates the deterministic first block of the chain, whose
+// state holds the initial supply as allocated by DefaultGenesis.
+func NewGenesisBlock() *Block {
+	accounts, err := DefaultGenesis().Accounts()
+	if err != nil {
+		// The default allocation is valid.
+		panic(err)
 	}
-	return builder.String()
+	return newGenesisBlock(accounts)
 }
 
-// generateTransactionID generates a unique ID for a transaction.
-func generateTransactionID(transactions []Transaction) string {
-	var builder strings.Builder
-	for _, tx := range transactions {
-		builder.WriteString(tx.Sender)
-		builder.WriteString(tx.Receiver)
-		builder.WriteString(strconv.FormatFloat(tx.Amount, 'f', 2, 64))
-		builder.WriteString(tx.Timestamp.String())
+// newGenesisBlock creates the first block of the chain with the given genesis
+// accounts.
+func newGenesisBlock(accounts map[string]Account) *Block {
+	state := NewStateTrie()
+	for address, account := range accounts {
+		state.Update(address, account)
 	}
-	hash := sha256.Sum256([]byte(builder.String()))
-	return hex.EncodeToString(hash[:])
+
+	block := newBlockAt(0, GenesisTimestamp, nil, strings.Repeat("0", 64), InitialDifficulty)
+	block.StateRoot = state.Root()
+	block.BaseFee = InitialBaseFee
+	block.Hash = block.CalculateHash()
+	return block
 }
 
-// calculateMerkleRoot calculates the Merkle root for a list of transactions.
-func calculateMerkleRoot(transactions []Transaction) string {
-	if len(transactions) == 0 {
-		return ""
+// newBlockAt assembles a block with an explicit timestamp, mining its
+// mini-blocks at the given difficulty.
+func newBlockAt(index int, timestamp int64, transactions []Transaction, previousHash string, difficulty int) *Block {
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Index:        index,
+			Timestamp:    timestamp,
+			PreviousHash: previousHash,
+			Difficulty:   difficulty,
+		},
+		BlockBody: BlockBody{SubBlocks: packSubBlocks(transactions, previousHash, difficulty)},
 	}
-	var hashes []string
+	block.finalize()
+	return block
+}
+
+// packSubBlocks distributes transactions into mini-blocks mined on a parent
+// block and sub-blocks within the size limits. Transactions beyond the block
+// limits are still packed, leaving the block to be rejected by
+// checkBlockLimits.
+func packSubBlocks(transactions []Transaction, previousHash string, difficulty int) []SubBlock {
+	assembler := newBlockAssembler(previousHash, difficulty)
 	for _, tx := range transactions {
-		hashes = append(hashes, generateTransactionID([]Transaction{tx}))
-	}
-	for len(hashes) > 1 {
-		var newLevel []string
-		for i := 0; i < len(hashes); i += 2 {
-			if i+1 < len(hashes) {
-				hash := sha256.Sum256([]byte(hashes[i] + hashes[i+1]))
-				newLevel = append(newLevel, hex.EncodeToString(hash[:]))
-			} else {
-				newLevel = append(newLevel, hashes[i])
+		assembler.add(tx)
+	}
+	return assembler.finish()
+}
+
+// finalize assigns keys to the sub-blocks and mini-blocks, then recomputes the
+// Merkle roots and hashes from the mini-blocks up to the header.
+func (b *Block) finalize() {
+	b.Key = fmt.Sprintf("B%d", b.Index)
+	for s := range b.SubBlocks {
+		sub := &b.SubBlocks[s]
+		sub.Index = s
+		sub.Key = fmt.Sprintf("%s/S%d", b.Key, s)
+		for m := range sub.MiniBlocks {
+			sub.MiniBlocks[m].Key = fmt.Sprintf("%s/M%d", sub.Key, m)
+		}
+		sub.MerkleRoot = sub.CalculateMerkleRoot()
+		sub.Hash = sub.CalculateHash()
+	}
+	b.MerkleRoot = b.CalculateMerkleRoot()
+	b.EvidenceRoot = b.CalculateEvidenceRoot()
+	b.LastCommitHash = b.LastCommit.Hash()
+	b.SolveTime = b.totalSolveTime()
+	b.Hash = b.CalculateHash()
+}
+
+// checkBlockLimits verifies a block against the size limits, recomputing the
+// size of every mini-block from its transactions.
+func checkBlockLimits(block *Block) error {
+	count, blockSize := 0, 0
+	for _, sub := range block.SubBlocks {
+		if len(sub.MiniBlocks) > MaxMiniBlocks {
+			return fmt.Errorf("sub-block %s holds %d mini-blocks, limit is %d", sub.Key, len(sub.MiniBlocks), MaxMiniBlocks)
+		}
+		subSize := 0
+		for _, mini := range sub.MiniBlocks {
+			size := calculateTransactionsSize(mini.Transactions)
+			if size != mini.CurrentSize || size > MaxMiniBlockSize {
+				return fmt.Errorf("mini-block %s size %d is invalid, limit is %d", mini.Key, size, MaxMiniBlockSize)
 			}
+			subSize += size
+			count += len(mini.Transactions)
+		}
+		if subSize > MaxSubBlockSize {
+			return fmt.Errorf("sub-block %s size %d exceeds limit %d", sub.Key, subSize, MaxSubBlockSize)
+		}
+		blockSize += subSize
+	}
+	if blockSize > MaxBlockSize {
+		return fmt.Errorf("block size %d exceeds limit %d", blockSize, MaxBlockSize)
+	}
+	if count > MaxTransactionsPerBlock {
+		return fmt.Errorf("block holds %d transactions, limit is %d", count, MaxTransactionsPerBlock)
+	}
+	if len(block.Evidence) > MaxEvidencePerBlock {
+		return fmt.Errorf("block holds %d pieces of evidence, limit is %d", len(block.Evidence), MaxEvidencePerBlock)
+	}
+	return nil
+}
+
+// Size returns the total size of the transactions in the sub-block in bytes.
+func (sb *SubBlock) Size() int {
+	size := 0
+	for _, mini := range sb.MiniBlocks {
+		size += mini.CurrentSize
+	}
+	return size
+}
+
+// Size returns the total size of the transactions in the block in bytes.
+func (b *Block) Size() int {
+	size := 0
+	for i := range b.SubBlocks {
+		size += b.SubBlocks[i].Size()
+	}
+	return size
+}
+
+// CalculateMerkleRoot computes the root over the Merkle roots of the mini-blocks,
+// recomputing each mini-block root from its transactions.
+func (sb *SubBlock) CalculateMerkleRoot() string {
+	var roots []string
+	for _, mini := range sb.MiniBlocks {
+		roots = append(roots, calculateMerkleRoot(mini.Transactions))
+	}
+	return merkleRootOfHashes(roots)
+}
+
+// CalculateHash generates the hash for a sub-block.
+func (sb *SubBlock) CalculateHash() string {
+	return hashFields(strconv.Itoa(sb.Index), sb.Key, sb.MerkleRoot)
+}
+
+// CalculateMerkleRoot computes the root over the Merkle roots of the sub-blocks.
+func (b *Block) CalculateMerkleRoot() string {
+	var roots []string
+	for i := range b.SubBlocks {
+		roots = append(roots, b.SubBlocks[i].CalculateMerkleRoot())
+	}
+	return merkleRootOfHashes(roots)
+}
+
+// CalculateHash generates the hash of the block header over a canonical
+// encoding of its fields.
+func (b *Block) CalculateHash() string {
+	return hashFields(strconv.Itoa(b.Index), formatInt(b.Timestamp), b.PreviousHash, b.MerkleRoot, b.StateRoot,
+		formatInt(int64(b.BaseFee)), b.Proposer, strconv.Itoa(b.Difficulty), b.EvidenceRoot, b.LastCommitHash, formatInt(b.SolveTime))
+}
+
+// Transactions returns every transaction in the block in mini-block order.
+func (b *Block) Transactions() []Transaction {
+	var transactions []Transaction
+	for _, sub := range b.SubBlocks {
+		for _, mini := range sub.MiniBlocks {
+			transactions = append(transactions, mini.Transactions...)
 		}
-		hashes = newLevel
 	}
-	return hashes[0]
+	return transactions
 }
@@ -1,106 +1,725 @@
 package blockchain
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"sync"
 	"time"
 )
 
+// ErrStateRootMismatch is returned when the account state does not match the
+// state root committed in a block header.
+var ErrStateRootMismatch = errors.New("block state root does not match the account state")
+
+// Supply parameters of the BMT Coin.
+const (
+	InitialSupply  = 8_000_000_000 * OneBMT  // Supply created at genesis
+	MaxTotalSupply = 10_000_000_000 * OneBMT // Hard cap on the supply, reached through emission
+	SystemAddress  = "system"                // Address holding the initial supply
+	DefaultChainID = "bmt-mainnet"           // Chain ID signed into every transaction
+)
+
 // Blockchain represents the chain of blocks and tokenomics system.
 type Blockchain struct {
-	Chain      []*Block    // Slice of blocks
+	Chain      []*Block    // Slice of blocks, cached from the store
 	Tokenomics *Tokenomics // Tokenomics for managing BMT Coin
-	mutex      sync.Mutex  // Mutex for synchronizing block addition
+	Store      Store       // Persistent storage for blocks and account state
+	ChainID    string      // Chain ID that transactions must be signed for
+	Coinbase   string      // Address credited with tips on locally produced blocks
+	State      *StateTrie  // Authenticated account state committed to by block headers
+	Events     *EventBus   // Bus publishing changes to the chain
+	// Validators is the genesis validator set, which decides blocks until
+	// validators are elected from bonded stake. Blocks from peers must carry
+	// commit certificates once there is a validator set; with none, blocks
+	// are accepted on proof of work alone.
+	Validators *ValidatorSet
+	// SnapshotInterval is the number of blocks between checkpoint heights at
+	// which the account state is kept for fast sync.
+	SnapshotInterval int
+	Checkpoints      map[int]string // Hashes of checkpoint blocks trusted out of band, by height, whose snapshots need no commit certificate
+	OnError          func(error)    // Receives the errors the chain recovers from, if set, possibly with the chain locked
+	Staking          StakingConfig  // Epoch and bonding parameters of the validator set
+	Emission         EmissionConfig // Schedule of the coins minted by each block
+	Governance       string         // Address allowed to send governance transactions, from the genesis configuration
+	Treasurer        *Wallet        // Governance wallet signing the system payouts of AddTransactionWithTokenomics, if this node holds it
+	nonces           map[string]uint64
+	stakes           map[string]Account    // Accounts holding stake or a slashing record
+	records          map[string]Account    // Token and allowance records, by record address
+	evidence         map[string]*Evidence  // Verified evidence waiting for a block, by validator
+	blocks           map[string]*blockNode // Block tree of canonical and side branch blocks
+	snapshots        []*Snapshot           // Account state at recent checkpoints, newest last
+	reorganizing     bool                  // Holds back block events until a reorganisation completes
+	mutex            sync.Mutex            // Mutex for synchronizing block addition
 }
 
-// NewBlockchain initializes a new blockchain with tokenomics.
+// NewBlockchain initializes a new in-memory development blockchain with
+// tokenomics, as NewDevelopmentBlockchain does.
 func NewBlockchain() *Blockchain {
-	genesisBlock := NewBlock(0, []string{"Genesis Block"}, "0")
-	tokenomics := NewTokenomics(8_000_000_000.0, 8_000_000_000.0)
+	bc, err := NewDevelopmentBlockchain(NewMemoryStore())
+	if err != nil {
+		// Committing genesis to an empty memory store cannot fail.
+		panic(err)
+	}
+	return bc
+}
+
+// NewBlockchainWithStore loads the blockchain held in a store, writing the
+// genesis block and the default allocation first if the store is empty.
+func NewBlockchainWithStore(store Store) (*Blockchain, error) {
+	return NewBlockchainWithGenesis(store, DefaultGenesis())
+}
+
+// NewDevelopmentBlockchain loads the development blockchain held in a store,
+// whose genesis is DevelopmentGenesis, and signs system payouts with the
+// development governance wallet. It is meant for tests only.
+func NewDevelopmentBlockchain(store Store) (*Blockchain, error) {
+	bc, err := NewBlockchainWithGenesis(store, DevelopmentGenesis())
+	if err != nil {
+		return nil, err
+	}
+	bc.Treasurer = DevelopmentGovernance()
+	return bc, nil
+}
+
+// NewBlockchainWithGenesis loads the blockchain held in a store, writing the
+// genesis block and the allocation of a genesis configuration first if the
+// store is empty. The configuration's vesting schedules are enforced on the
+// loaded chain, whose genesis block must match the configuration.
+func NewBlockchainWithGenesis(store Store, genesis GenesisConfig) (*Blockchain, error) {
+	allocation, err := genesis.Accounts()
+	if err != nil {
+		return nil, fmt.Errorf("invalid genesis allocation: %v", err)
+	}
+	genesisBlock := newGenesisBlock(allocation)
+
+	tokenomics := NewTokenomics(InitialSupply, MaxTotalSupply)
+	tokenomics.Events = NewEventBus()
+	bc := &Blockchain{
+		Tokenomics:       tokenomics,
+		Store:            store,
+		ChainID:          DefaultChainID,
+		Coinbase:         SystemAddress,
+		State:            NewStateTrie(),
+		Events:           tokenomics.Events,
+		SnapshotInterval: DefaultSnapshotInterval,
+		Staking:          DefaultStakingConfig(),
+		Emission:         DefaultEmissionConfig(),
+		Governance:       genesis.Governance,
+		nonces:           make(map[string]uint64),
+		stakes:           make(map[string]Account),
+		records:          make(map[string]Account),
+		evidence:         make(map[string]*Evidence),
+	}
+
+	for _, grant := range genesis.Grants() {
+		tokenomics.Vesting[grant.Beneficiary] = grant
+	}
+
+	if store.Height() < 0 {
+		update := StateUpdate{
+			Accounts:    allocation,
+			TotalSupply: InitialSupply,
+		}
+		if err := store.CommitBlock(genesisBlock, update); err != nil {
+			return nil, fmt.Errorf("error committing genesis block: %v", err)
+		}
+	}
+
+	if err := bc.loadBlocks(); err != nil {
+		return nil, err
+	}
+	if bc.Chain[0].Hash != genesisBlock.Hash {
+		return nil, errors.New("store holds the chain of a different genesis allocation")
+	}
+
+	accounts, err := store.Accounts()
+	if err != nil {
+		return nil, fmt.Errorf("error loading accounts: %v", err)
+	}
+	for address, account := range accounts {
+		bc.State.Update(address, account)
+		if isRecordAddress(address) {
+			bc.records[address] = account
+			continue
+		}
+		tokenomics.Balances[address] = account.Balance
+		if account.Released != 0 {
+			tokenomics.Released[address] = account.Released
+		}
+		if account.CapExempt {
+			tokenomics.CapExempt[address] = true
+		}
+		bc.nonces[address] = account.Nonce
+		if account.hasStakingRecord() {
+			bc.stakes[address] = account
+		}
+	}
+	tokenomics.TotalSupply = store.TotalSupply()
+
+	return bc, nil
+}
+
+// loadBlocks reloads the canonical chain and the block tree from the store.
+func (bc *Blockchain) loadBlocks() error {
+	bc.Chain = nil
+	bc.blocks = make(map[string]*blockNode)
+	for height := 0; height <= bc.Store.Height(); height++ {
+		block, err := bc.Store.GetBlockByHeight(height)
+		if err != nil {
+			return fmt.Errorf("error loading block %d: %v", height, err)
+		}
+		bc.Chain = append(bc.Chain, block)
+		bc.addNode(block, bc.blocks[block.PreviousHash])
+	}
+	return nil
+}
+
+// applyBlock executes a block received from a peer on top of the canonical
+// tip and commits it if the resulting state matches its state root.
+func (bc *Blockchain) applyBlock(block *Block) error {
+	return bc.executeAndCommit(block, false)
+}
+
+// applyNewBlock executes a locally produced block on top of the canonical tip,
+// writes the resulting state root into its header and commits it.
+func (bc *Blockchain) applyNewBlock(block *Block) error {
+	return bc.executeAndCommit(block, true)
+}
+
+// executeAndCommit executes a block, commits it and then applies its state
+// changes, so that the live state never holds a block that is rejected or
+// fails to commit. With sealStateRoot set, the state root is written into the
+// header instead of being checked against it.
+func (bc *Blockchain) executeAndCommit(block *Block, sealStateRoot bool) error {
+	update, err := bc.stateUpdate(block)
+	if err != nil {
+		return err
+	}
+
+	root := bc.State.rootAfter(update.Accounts)
+	if sealStateRoot {
+		block.StateRoot = root
+		block.Hash = block.CalculateHash()
+	} else if block.StateRoot != root {
+		return ErrStateRootMismatch
+	}
+	if err := bc.Store.CommitBlock(block, update); err != nil {
+		return err
+	}
+	bc.setState(update.Accounts, update.TotalSupply)
+	bc.Chain = append(bc.Chain, block)
+	if _, known := bc.blocks[block.Hash]; !known {
+		bc.addNode(block, bc.blocks[block.PreviousHash])
+	}
+	for _, evidence := range block.Evidence {
+		delete(bc.evidence, evidence.Validator)
+	}
+	bc.publishBlock(block)
+	if bc.SnapshotInterval > 0 && block.Index%bc.SnapshotInterval == 0 {
+		if err := bc.takeSnapshot(block); err != nil {
+			bc.report(fmt.Errorf("error taking snapshot at block %d: %w", block.Index, err))
+		}
+	}
+	return nil
+}
+
+// report passes an error the chain recovered from to OnError, if set.
+func (bc *Blockchain) report(err error) {
+	if bc.OnError != nil {
+		bc.OnError(err)
+	}
+}
+
+// stateRootAfter returns the state root a block produces on top of the
+// canonical tip. The root is computed over the changed accounts without
+// writing them to the live state, which readers of balances outside the
+// chain lock must never see holding an uncommitted block.
+func (bc *Blockchain) stateRootAfter(block *Block) (string, error) {
+	update, err := bc.stateUpdate(block)
+	if err != nil {
+		return "", err
+	}
+	return bc.State.rootAfter(update.Accounts), nil
+}
+
+// stateUpdate executes a block on top of the canonical tip and returns the
+// account changes it makes, along with the state they replace.
+func (bc *Blockchain) stateUpdate(block *Block) (StateUpdate, error) {
+	execution, err := bc.executeBlock(block)
+	if err != nil {
+		return StateUpdate{}, err
+	}
+
+	update := StateUpdate{
+		Accounts:       make(map[string]Account),
+		Previous:       make(map[string]Account),
+		PreviousSupply: bc.Tokenomics.TotalSupply,
+	}
+	update.TotalSupply, err = update.PreviousSupply.Sub(execution.burned)
+	if err != nil {
+		return StateUpdate{}, err
+	}
+	if update.TotalSupply, err = update.TotalSupply.Add(execution.minted); err != nil {
+		return StateUpdate{}, err
+	}
+	update.Rewards = execution.rewards
+	update.Minted, update.Burned = execution.minted, execution.burned
+	touched := make(map[string]bool)
+	for address := range execution.balances {
+		touched[address] = true
+	}
+	for address := range execution.nonces {
+		touched[address] = true
+	}
+	for address := range execution.stakes {
+		touched[address] = true
+	}
+	for address := range execution.released {
+		touched[address] = true
+	}
+	for address := range execution.records {
+		touched[address] = true
+	}
+	for address := range execution.exemptions {
+		touched[address] = true
+	}
+	for address := range touched {
+		previous := bc.account(address)
+		account := previous
+		if record, ok := execution.records[address]; ok {
+			account = record
+		}
+		if balance, ok := execution.balances[address]; ok {
+			account.Balance = balance
+		}
+		if nonce, sent := execution.nonces[address]; sent {
+			account.Nonce = nonce
+		}
+		if released, ok := execution.released[address]; ok {
+			account.Released = released
+		}
+		if exempt, ok := execution.exemptions[address]; ok {
+			account.CapExempt = exempt
+		}
+		if stake, staked := execution.stakes[address]; staked {
+			account.Bonded, account.Unbonding = stake.Bonded, stake.Unbonding
+			account.UnbondingUntil, account.PublicKey = stake.UnbondingUntil, stake.PublicKey
+			account.JailedUntil, account.SlashedAt = stake.JailedUntil, stake.SlashedAt
+		}
+		update.Accounts[address] = account
+		update.Previous[address] = previous
+	}
+	if bc.Staking.EpochLength > 0 && block.Index%bc.Staking.EpochLength == 0 {
+		update.Validators, update.Elected = bc.electValidators(update.Accounts, block.Index), true
+	} else if len(execution.jailed) > 0 {
+		// Jailed validators leave the set without waiting for the epoch to end
+		update.Validators = bc.withoutJailed(block.Index, execution.jailed)
+		update.Elected = update.Validators != nil
+	}
+	return update, nil
+}
+
+// account returns the current state of an address.
+func (bc *Blockchain) account(address string) Account {
+	if isRecordAddress(address) {
+		return bc.records[address]
+	}
+	account := bc.stakes[address]
+	account.Balance = bc.Tokenomics.GetBalance(address)
+	account.Nonce = bc.nonces[address]
+	account.Released = bc.Tokenomics.getReleased(address)
+	account.CapExempt = bc.Tokenomics.isCapExempt(address)
+	return account
+}
+
+// setState overwrites the balances, nonces, records and state trie entries
+// of the given accounts and the total supply.
+func (bc *Blockchain) setState(accounts map[string]Account, totalSupply Amount) {
+	for address, account := range accounts {
+		bc.State.Update(address, account)
+		if isRecordAddress(address) {
+			if account != (Account{}) {
+				bc.records[address] = account
+			} else {
+				delete(bc.records, address)
+			}
+			continue
+		}
+		bc.nonces[address] = account.Nonce
+		if account.hasStakingRecord() {
+			bc.stakes[address] = account
+		} else {
+			delete(bc.stakes, address)
+		}
+	}
+	bc.Tokenomics.setBalances(accounts, totalSupply)
+}
+
+// newChildBlock builds the next block on the current tip, mining it at the
+// retargeted difficulty.
+func (bc *Blockchain) newChildBlock(transactions []Transaction) *Block {
+	previousHash, difficulty := bc.Chain[len(bc.Chain)-1].Hash, nextDifficulty(bc.tipNode())
+	return bc.childBlockWithBody(packSubBlocks(transactions, previousHash, difficulty), previousHash, difficulty)
+}
 
-	// Assign initial supply to the system wallet
-	tokenomics.Balances["system"] = 8_000_000_000.0
+// childBlockWithBody wraps mined sub-blocks in a header on top of the current
+// tip, charging the base fee derived from the tip and crediting tips to the
+// coinbase. The block carries the evidence waiting to be punished and records
+// the tip's commit certificate. Mini-blocks mined on another parent or at a
+// stale difficulty are mined again.
+func (bc *Blockchain) childBlockWithBody(subBlocks []SubBlock, previousHash string, difficulty int) *Block {
+	lastBlock := bc.Chain[len(bc.Chain)-1]
+	expected := nextDifficulty(bc.tipNode())
+	if previousHash != lastBlock.Hash || difficulty != expected {
+		for s := range subBlocks {
+			for m := range subBlocks[s].MiniBlocks {
+				mineMiniBlock(&subBlocks[s].MiniBlocks[m], lastBlock.Hash, expected)
+			}
+		}
+	}
 
-	return &Blockchain{
-		Chain:      []*Block{genesisBlock},
-		Tokenomics: tokenomics,
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Index:        lastBlock.Index + 1,
+			Timestamp:    time.Now().Unix(),
+			PreviousHash: lastBlock.Hash,
+			BaseFee:      NextBaseFee(lastBlock),
+			Proposer:     bc.Coinbase,
+			Difficulty:   expected,
+		},
+		BlockBody:  BlockBody{SubBlocks: subBlocks, Evidence: bc.collectEvidence()},
+		LastCommit: lastBlock.Commit,
 	}
+	block.finalize()
+	return block
 }
 
-// AddBlock adds a new block to the chain with raw transaction data.
-func (bc *Blockchain) AddBlock(transactions []string) {
+// AddBlock adds a new block containing the given transactions to the chain.
+func (bc *Blockchain) AddBlock(transactions []Transaction) error {
 	bc.mutex.Lock()
 	defer bc.mutex.Unlock()
 
-	lastBlock := bc.Chain[len(bc.Chain)-1]
-	newBlock := NewBlock(lastBlock.Index+1, transactions, lastBlock.Hash)
-	bc.Chain = append(bc.Chain, newBlock)
+	return bc.applyNewBlock(bc.newChildBlock(transactions))
+}
+
+// blockExecution accumulates the effect of a block's transactions on top of
+// the chain state before anything is applied.
+type blockExecution struct {
+	height     int                // Height of the block
+	timestamp  int64              // Timestamp of the block, at which vesting schedules are evaluated
+	baseFee    Amount             // Base fee per gas charged by the block
+	proposer   string             // Address credited with the tips
+	balances   map[string]Amount  // Working balances of touched addresses
+	nonces     map[string]uint64  // Next nonce of each sender in the block
+	stakes     map[string]Account // Working staking state of addresses that staked or were slashed
+	released   map[string]Amount  // Working released vesting amounts of addresses that released coins
+	records    map[string]Account // Working token and allowance records touched by the block
+	exemptions map[string]bool    // Ownership cap exemptions changed by the block's governance transactions
+	seen       map[string]bool    // Transaction hashes already in the block
+	burned     Amount             // Total base fee and slashed stake burned by the block
+	minted     Amount             // Block reward minted by the block
+	rewards    map[string]Amount  // Block reward credited to each address
+	jailed     []string           // Validators jailed by the block's evidence
 }
 
-// AddTransactionBlock adds a block containing validated transactions to the blockchain.
+// newBlockExecution starts executing a block at the given height and base fee.
+func newBlockExecution(height int, baseFee Amount, proposer string) *blockExecution {
+	return &blockExecution{
+		height:     height,
+		baseFee:    baseFee,
+		proposer:   proposer,
+		balances:   make(map[string]Amount),
+		nonces:     make(map[string]uint64),
+		stakes:     make(map[string]Account),
+		seen:       make(map[string]bool),
+		rewards:    make(map[string]Amount),
+		released:   make(map[string]Amount),
+		records:    make(map[string]Account),
+		exemptions: make(map[string]bool),
+	}
+}
+
+// AddTransactionBlock verifies signed transactions, applies them to the
+// balances and adds them to the blockchain in a new block. Transactions from
+// the same sender must carry consecutive nonces starting at the sender's
+// current nonce. Each transaction's base fee is burned and the remainder of
+// its fee is paid to the block proposer.
 func (bc *Blockchain) AddTransactionBlock(transactions []*Transaction) error {
 	bc.mutex.Lock()
 	defer bc.mutex.Unlock()
 
+	var blockTransactions []Transaction
 	for _, tx := range transactions {
-		if !tx.Validate() {
-			return errors.New("invalid transaction detected")
-		}
+		blockTransactions = append(blockTransactions, *tx)
 	}
+	return bc.applyNewBlock(bc.newChildBlock(blockTransactions))
+}
 
-	var transactionData []string
-	for _, tx := range transactions {
-		transactionData = append(transactionData, tx.Hash)
+// executeBlock runs a block's evidence and then every transaction of the
+// block against the chain state, and mints the block reward. Every
+// transaction must be signed; coins leave the system address only through
+// governance payouts.
+func (bc *Blockchain) executeBlock(block *Block) (*blockExecution, error) {
+	if err := checkBlockLimits(block); err != nil {
+		return nil, invalidBlock(block, ErrBlockLimits, err)
 	}
+	if err := bc.checkLastCommit(block); err != nil {
+		return nil, err
+	}
+	transactions := block.Transactions()
 
-	lastBlock := bc.Chain[len(bc.Chain)-1]
-	newBlock := NewBlock(lastBlock.Index+1, transactionData, lastBlock.Hash)
-	newBlock.MerkleRoot = lastBlock.CalculateMerkleRoot(transactions)
-	bc.Chain = append(bc.Chain, newBlock)
+	execution := newBlockExecution(block.Index, block.BaseFee, block.Proposer)
+	execution.timestamp = block.Timestamp
+	for i := range block.Evidence {
+		if err := bc.executeEvidence(&block.Evidence[i], execution); err != nil {
+			return nil, invalidBlock(block, ErrInvalidEvidence, fmt.Errorf("evidence against %s: %w", block.Evidence[i].Validator, err))
+		}
+	}
+	for i := range transactions {
+		tx := &transactions[i]
+		if err := bc.executeTransaction(tx, execution); err != nil {
+			return nil, invalidBlock(block, ErrBlockTransaction, fmt.Errorf("transaction %s: %w", tx.Hash, err))
+		}
+	}
+	if err := bc.executeEmission(execution); err != nil {
+		return nil, fmt.Errorf("error minting the block reward: %v", err)
+	}
+	return execution, nil
+}
+
+// loadBalances copies the chain balances of addresses not yet touched by the
+// execution into its working balances.
+func (bc *Blockchain) loadBalances(execution *blockExecution, addresses ...string) {
+	for _, address := range addresses {
+		if _, ok := execution.balances[address]; !ok {
+			execution.balances[address] = bc.Tokenomics.GetBalance(address)
+		}
+	}
+}
 
+// checkShape checks that a transaction carries the amount, target and permit
+// its kind calls for. Governance transactions other than payouts and permit
+// transactions move no coins, an approval may set an allowance of zero, and
+// only governance transactions, allowance changes and transfers out of an
+// allowance name a target. Only token registrations carry decimals.
+func checkShape(tx *Transaction) error {
+	if (tx.Permit != nil) != (tx.Receiver == PermitAddress) {
+		return errors.New("only permit transactions carry a permit")
+	}
+	switch {
+	case tx.Receiver == PayoutAddress:
+		if tx.Amount <= 0 || tx.Token != "" {
+			return errors.New("payout amount must be positive")
+		}
+	case isGovernanceAddress(tx.Receiver), tx.Receiver == PermitAddress:
+		if tx.Amount != 0 || tx.Token != "" {
+			return errors.New("governance and permit transactions carry no amount")
+		}
+	case tx.Receiver == ApproveAddress:
+		if tx.Amount < 0 {
+			return errors.New("allowance cannot be negative")
+		}
+	case tx.Amount <= 0:
+		return errors.New("transaction amount must be positive")
+	}
+	if tx.Target != "" && tx.Token != "" {
+		return errors.New("token transactions carry no target")
+	}
+	if tx.Decimals != 0 && (tx.Receiver != IssueTokenAddress || tx.Token == "") {
+		return errors.New("only token registrations carry decimals")
+	}
 	return nil
 }
 
-// AddTransactionWithTokenomics adds a transaction to the blockchain and updates balances.
-func (bc *Blockchain) AddTransactionWithTokenomics(from, to string, amount float64) error {
-	bc.mutex.Lock()
-	defer bc.mutex.Unlock()
+// executeTransaction checks a transaction against the chain state plus the
+// transactions before it in the block and records its effect.
+func (bc *Blockchain) executeTransaction(tx *Transaction, execution *blockExecution) error {
+	if tx.ChainID != bc.ChainID {
+		return ErrWrongChainID
+	}
+	if err := tx.VerifySignature(); err != nil {
+		return err
+	}
+	if execution.seen[tx.Hash] {
+		return ErrReplayedTransaction
+	}
+	if _, err := bc.Store.GetTxLocation(tx.Hash); err == nil {
+		return ErrReplayedTransaction
+	}
+
+	expected, pending := execution.nonces[tx.Sender]
+	if !pending {
+		expected = bc.nonces[tx.Sender]
+	}
+	if tx.Nonce != expected {
+		return fmt.Errorf("%w: expected %d, got %d", ErrInvalidNonce, expected, tx.Nonce)
+	}
 
-	err := bc.Tokenomics.Transfer(from, to, amount)
+	if err := checkShape(tx); err != nil {
+		return err
+	}
+	burned, tip, err := SplitFee(tx, execution.baseFee)
 	if err != nil {
 		return err
 	}
 
-	transaction := []string{from + " -> " + to + ": " + formatAmount(amount) + " BMT"}
-	lastBlock := bc.Chain[len(bc.Chain)-1]
-	newBlock := NewBlock(lastBlock.Index+1, transaction, lastBlock.Hash)
-	bc.Chain = append(bc.Chain, newBlock)
+	payer := tx.Payer()
+	bc.loadBalances(execution, payer, execution.proposer)
+	total, err := tx.Spend()
+	if err != nil {
+		return err
+	}
+	switch {
+	case tx.Token != "":
+		if err := bc.executeToken(tx, execution); err != nil {
+			return err
+		}
+	case isGovernanceAddress(tx.Receiver):
+		if err := bc.executeGovernance(tx, execution); err != nil {
+			return err
+		}
+	case isAllowanceAddress(tx.Receiver):
+		if err := bc.executeAllowance(tx, execution); err != nil {
+			return err
+		}
+	case tx.Target != "":
+		if err := bc.executeTransferFrom(tx, execution); err != nil {
+			return err
+		}
+	case isStakingAddress(tx.Receiver):
+		if err := bc.executeStaking(tx, execution); err != nil {
+			return err
+		}
+	case tx.Receiver == ReleaseAddress:
+		if err := bc.executeRelease(tx, execution); err != nil {
+			return err
+		}
+	case tx.Receiver == BurnAddress:
+		burned += tx.Amount
+	case isRecordAddress(tx.Receiver):
+		return errors.New("BMT cannot be sent to a token or approval address")
+	}
+	remaining, err := execution.balances[payer].Sub(total)
+	if err != nil {
+		return errors.New("insufficient balance")
+	}
+	if err := bc.checkLocked(payer, remaining, execution); err != nil {
+		return err
+	}
 
+	execution.balances[payer] = remaining
+	if tx.Token == "" && !isProtocolAddress(tx.Receiver) {
+		bc.loadBalances(execution, tx.Receiver)
+		if err := bc.checkOwnership(execution, tx.Receiver, tx.Amount); err != nil {
+			return err
+		}
+		execution.balances[tx.Receiver] += tx.Amount
+	}
+	// Tips beyond the proposer's ownership cap are burned
+	if room := bc.ownershipRoom(execution, execution.proposer); tip > room {
+		burned, tip = burned+tip-room, room
+	}
+	execution.balances[execution.proposer] += tip
+	execution.burned += burned
+	execution.nonces[tx.Sender] = expected + 1
+	execution.seen[tx.Hash] = true
 	return nil
 }
 
-// formatAmount formats the float amount with 7 decimal places.
-func formatAmount(amount float64) string {
-	return fmt.Sprintf("%.7f", amount)
+// CurrentDifficulty returns the difficulty the next block's mini-blocks must meet.
+func (bc *Blockchain) CurrentDifficulty() int {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return nextDifficulty(bc.tipNode())
+}
+
+// CurrentBaseFee returns the base fee per gas the next block will charge.
+func (bc *Blockchain) CurrentBaseFee() Amount {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return NextBaseFee(bc.Chain[len(bc.Chain)-1])
 }
 
-// IsValid checks if the blockchain is valid by verifying all blocks.
+// GetAccountProof returns the state of an address with a proof against the
+// state root of the latest block.
+func (bc *Blockchain) GetAccountProof(address string) (*AccountProof, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	tip := bc.Chain[len(bc.Chain)-1]
+	proof := &AccountProof{
+		Address:   address,
+		Account:   bc.account(address),
+		BlockHash: tip.Hash,
+		Height:    tip.Index,
+		StateRoot: bc.State.Root(),
+		Steps:     bc.State.Prove(address),
+	}
+	if proof.StateRoot != tip.StateRoot {
+		return nil, ErrStateRootMismatch
+	}
+	return proof, nil
+}
+
+// GetNonce returns the nonce expected on the next transaction from an address.
+func (bc *Blockchain) GetNonce(address string) uint64 {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.nonces[address]
+}
+
+// AddTransactionWithTokenomics pays an amount out of the community balance
+// at the system address in a new block and updates balances. The payout is a
+// governance transaction signed with the Treasurer wallet, so only a node
+// holding the governance key can make it. User transfers go through
+// AddTransactionBlock instead.
+func (bc *Blockchain) AddTransactionWithTokenomics(from, to string, amount Amount) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if from != SystemAddress {
+		return errors.New("only the system address pays out coins")
+	}
+	if bc.Treasurer == nil || bc.Treasurer.Address != bc.Governance {
+		return fmt.Errorf("%w: this node does not hold the governance key", ErrNotGovernance)
+	}
+	payout, err := NewPayoutTransaction(bc.Treasurer, to, amount, 0, bc.nonces[bc.Treasurer.Address], bc.ChainID, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	return bc.applyNewBlock(bc.newChildBlock([]Transaction{*payout}))
+}
+
+// IsValid checks if the blockchain is valid by verifying all blocks with
+// ValidateChain, which returns the rule a block breaks.
 func (bc *Blockchain) IsValid() bool {
-	for i := 1; i < len(bc.Chain); i++ {
-		currentBlock := bc.Chain[i]
-		previousBlock := bc.Chain[i-1]
+	return bc.ValidateChain() == nil
+}
 
-		if currentBlock.Hash != currentBlock.CalculateHash() || currentBlock.PreviousHash != previousBlock.Hash {
-			return false
-		}
+// GetBlocks returns the canonical blocks from height from to height to
+// inclusive, clipped to the current chain.
+func (bc *Blockchain) GetBlocks(from, to int) []*Block {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if from < 0 {
+		from = 0
+	}
+	if to >= len(bc.Chain) {
+		to = len(bc.Chain) - 1
 	}
-	return true
+	if from > to {
+		return nil
+	}
+	return append([]*Block(nil), bc.Chain[from:to+1]...)
 }
 
 // GetLatestBlock retrieves the last block in the blockchain.
 func (bc *Blockchain) GetLatestBlock() *Block {
 	return bc.Chain[len(bc.Chain)-1]
 }
+
+// Close releases the underlying store.
+func (bc *Blockchain) Close() error {
+	return bc.Store.Close()
+}
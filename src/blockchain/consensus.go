@@ -2,12 +2,14 @@ package blockchain
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 )
 
 // VotingConsensus represents the voting-based consensus mechanism.
 type VotingConsensus struct {
-	Threshold float64 // Percentage of votes required to accept a block
+	Threshold float64     // Percentage of votes required to accept a block
+	Chain     *Blockchain // Chain whose tip proposed blocks must extend, or nil to check blocks on their own
 }
 
 // NewVotingConsensus initializes a new voting consensus mechanism.
@@ -22,8 +24,12 @@ type Vote struct {
 }
 
 // ProposeBlock allows a node to propose a block for voting.
+//
+// Deprecated: votes are simulated and unsigned. Use BFTConsensus, which
+// collects signed votes from a staked validator set.
 func (vc *VotingConsensus) ProposeBlock(block *Block, voters []string) (bool, error) {
 	var votes []Vote
+	var reason error
 	var mutex sync.Mutex
 	var wg sync.WaitGroup
 
@@ -33,9 +39,12 @@ func (vc *VotingConsensus) ProposeBlock(block *Block, voters []string) (bool, er
 		go func(nodeID string) {
 			defer wg.Done()
 			// Simulate vote (this could involve complex logic in real cases)
-			approve := vc.validateBlock(block)
+			err := vc.validateBlock(block)
 			mutex.Lock()
-			votes = append(votes, Vote{NodeID: nodeID, Approve: approve})
+			votes = append(votes, Vote{NodeID: nodeID, Approve: err == nil})
+			if err != nil && reason == nil {
+				reason = err
+			}
 			mutex.Unlock()
 		}(voter)
 	}
@@ -55,11 +64,28 @@ func (vc *VotingConsensus) ProposeBlock(block *Block, voters []string) (bool, er
 		return true, nil
 	}
 
+	if reason != nil {
+		return false, fmt.Errorf("block rejected by consensus: %w", reason)
+	}
 	return false, errors.New("block rejected by consensus")
 }
 
-// validateBlock checks the validity of the block (basic validation for demo).
-func (vc *VotingConsensus) validateBlock(block *Block) bool {
-	// Add block validation logic here (e.g., hash, structure, transactions)
-	return true // Simulate approval for demo purposes
+// validateBlock checks the validity of the block. With a chain, the block
+// must pass every rule of ValidateBlock against its tip. Without one, only
+// the rules that need no parent or state are checked: the hash, the timestamp
+// drift, the roots and size limits, and the transaction signatures.
+func (vc *VotingConsensus) validateBlock(block *Block) error {
+	if vc.Chain != nil {
+		return vc.Chain.ValidateBlock(block)
+	}
+	if hash := block.CalculateHash(); block.Hash != hash {
+		return invalidBlock(block, ErrBlockHash, fmt.Errorf("header hashes to %s", hash))
+	}
+	if err := checkTimestamp(block); err != nil {
+		return err
+	}
+	if err := checkBlockBody(block); err != nil {
+		return err
+	}
+	return checkTransactions(block)
 }
@@ -8,19 +8,20 @@ import (
 
 // CrossChainBridge manages cross-chain transactions.
 type CrossChainBridge struct {
-	LockedTokens map[string]float64 // Mapping of addresses to locked token amounts
-	mutex        sync.Mutex         // Mutex for thread safety
+	LockedTokens map[string]Amount // Mapping of addresses to locked token amounts
+	Events       *EventBus         // Bus publishing locks and unlocks, if set
+	mutex        sync.Mutex        // Mutex for thread safety
 }
 
 // NewCrossChainBridge initializes a new cross-chain bridge.
 func NewCrossChainBridge() *CrossChainBridge {
 	return &CrossChainBridge{
-		LockedTokens: make(map[string]float64),
+		LockedTokens: make(map[string]Amount),
 	}
 }
 
 // LockTokens locks tokens on the source chain.
-func (bridge *CrossChainBridge) LockTokens(address string, amount float64) error {
+func (bridge *CrossChainBridge) LockTokens(address string, amount Amount) error {
 	bridge.mutex.Lock()
 	defer bridge.mutex.Unlock()
 
@@ -28,13 +29,29 @@ func (bridge *CrossChainBridge) LockTokens(address string, amount float64) error
 		return errors.New("amount must be greater than zero")
 	}
 
-	bridge.LockedTokens[address] += amount
-	fmt.Printf("Locked %.7f tokens for %s\n", amount, address)
+	locked, err := bridge.LockedTokens[address].Add(amount)
+	if err != nil {
+		return err
+	}
+	bridge.LockedTokens[address] = locked
+	bridge.Events.Publish(Event{Type: EventBridgeLock, Height: -1, To: address, Amount: amount})
 	return nil
 }
 
+// TotalLocked returns the tokens locked in the bridge across all addresses.
+func (bridge *CrossChainBridge) TotalLocked() Amount {
+	bridge.mutex.Lock()
+	defer bridge.mutex.Unlock()
+
+	var total Amount
+	for _, locked := range bridge.LockedTokens {
+		total += locked
+	}
+	return total
+}
+
 // MintTokens mints tokens on the destination chain.
-func (bridge *CrossChainBridge) MintTokens(address string, amount float64) error {
+func (bridge *CrossChainBridge) MintTokens(address string, amount Amount) error {
 	bridge.mutex.Lock()
 	defer bridge.mutex.Unlock()
 
@@ -43,7 +60,7 @@ func (bridge *CrossChainBridge) MintTokens(address string, amount float64) error
 	}
 
 	// Simulate minting tokens (can be integrated with smart contracts on other chains)
-	fmt.Printf("Minted %.7f tokens for %s on the destination chain\n", amount, address)
+	bridge.Events.Publish(Event{Type: EventBridgeMint, Height: -1, To: address, Amount: amount})
 	return nil
 }
 
@@ -53,12 +70,11 @@ func (bridge *CrossChainBridge) VerifyCrossChainTransaction(txID string) (bool,
 	if txID == "" {
 		return false, errors.New("invalid transaction ID")
 	}
-	fmt.Printf("Transaction %s verified successfully\n", txID)
 	return true, nil
 }
 
 // UnlockTokens unlocks tokens on the source chain after verification.
-func (bridge *CrossChainBridge) UnlockTokens(address string, amount float64) error {
+func (bridge *CrossChainBridge) UnlockTokens(address string, amount Amount) error {
 	bridge.mutex.Lock()
 	defer bridge.mutex.Unlock()
 
@@ -67,14 +83,13 @@ func (bridge *CrossChainBridge) UnlockTokens(address string, amount float64) err
 	}
 
 	bridge.LockedTokens[address] -= amount
-	fmt.Printf("Unlocked %.7f tokens for %s\n", amount, address)
+	bridge.Events.Publish(Event{Type: EventBridgeUnlock, Height: -1, To: address, Amount: amount})
 	return nil
 }
 
 // VerifyCrossChainTransactionWithOracle integrates Oracle for cross-chain verification.
 func (bridge *CrossChainBridge) VerifyCrossChainTransactionWithOracle(txID, blockchain string, oracle *OracleSystem) (bool, error) {
-	data, err := oracle.FetchData(txID, blockchain)
-	if err != nil {
+	if _, err := oracle.FetchData(txID, blockchain); err != nil {
 		return false, fmt.Errorf("error fetching data from Oracle: %v", err)
 	}
 
@@ -84,6 +99,5 @@ func (bridge *CrossChainBridge) VerifyCrossChainTransactionWithOracle(txID, bloc
 		return false, fmt.Errorf("error validating transaction: %v", err)
 	}
 
-	fmt.Printf("Transaction %s verified with Oracle: %s\n", txID, data)
 	return valid, nil
 }
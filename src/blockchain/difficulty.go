@@ -0,0 +1,174 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"time"
+)
+
+// Difficulty retargeting parameters. Difficulty is the number of leading zero
+// bits every mini-block hash in a block must have.
+const (
+	InitialDifficulty = 8  // Difficulty of the genesis block
+	MinDifficulty     = 1  // Lowest difficulty retargeting can reach
+	MaxDifficulty     = 64 // Highest difficulty retargeting can reach
+	DifficultyWindow  = 16 // Number of recent blocks whose solve times drive retargeting
+)
+
+// ErrInsufficientWork is returned when a mini-block hash does not meet the
+// difficulty committed in its block header.
+var ErrInsufficientWork = errors.New("mini-block does not meet the block difficulty")
+
+// meetsDifficulty reports whether a hex hash has at least difficulty leading zero bits.
+func meetsDifficulty(hash string, difficulty int) bool {
+	decoded, err := hex.DecodeString(hash)
+	if err != nil {
+		return false
+	}
+	zeros := 0
+	for _, b := range decoded {
+		zeros += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return zeros >= difficulty
+}
+
+// mineMiniBlock searches for a nonce whose hash of the mini-block on a parent
+// block meets the difficulty, recording the nonce, the hash and the time the
+// search took.
+func mineMiniBlock(miniBlock *MiniBlock, previousHash string, difficulty int) {
+	start := time.Now()
+	record := encodeFields(miniBlockRecord(previousHash, miniBlock.Index, miniBlock.Transactions)...)
+	for nonce := uint64(0); ; nonce++ {
+		hash := sha256.Sum256(append(record[:len(record):len(record)], encodeFields(strconv.FormatUint(nonce, 10))...))
+		encoded := hex.EncodeToString(hash[:])
+		if meetsDifficulty(encoded, difficulty) {
+			miniBlock.Nonce = nonce
+			miniBlock.Hash = encoded
+			break
+		}
+	}
+	miniBlock.SolveTime = time.Since(start).Nanoseconds()
+}
+
+// checkProofOfWork verifies that every mini-block of a block carries a nonce
+// producing its hash on the block's parent, and that the hash meets the block
+// difficulty.
+func checkProofOfWork(block *Block) error {
+	for _, sub := range block.SubBlocks {
+		for _, mini := range sub.MiniBlocks {
+			if mini.Hash != calculateMiniBlockHash(block.PreviousHash, mini.Index, mini.Nonce, mini.Transactions) {
+				return fmt.Errorf("mini-block %s hash mismatch", mini.Key)
+			}
+			if !meetsDifficulty(mini.Hash, block.Difficulty) {
+				return fmt.Errorf("%w: %s needs %d bits", ErrInsufficientWork, mini.Key, block.Difficulty)
+			}
+			if mini.SolveTime < 0 {
+				return fmt.Errorf("mini-block %s reports a negative solve time", mini.Key)
+			}
+		}
+	}
+	return nil
+}
+
+// calculateDynamicDifficulty retargets the difficulty toward TargetMiningTime
+// per mini-block. Each extra bit doubles the expected solve time, so the
+// difficulty moves by one bit whenever that brings the average solve time
+// closer to the target.
+func calculateDynamicDifficulty(current int, solveTimes []int64) int {
+	if len(solveTimes) == 0 {
+		return current
+	}
+	var total int64
+	for _, solveTime := range solveTimes {
+		total += solveTime
+	}
+	average := total / int64(len(solveTimes))
+	target := int64(TargetMiningTime * float64(time.Second))
+
+	next := current
+	switch {
+	case 3*average < 2*target:
+		next++ // Doubling the average lands closer to the target
+	case 3*average > 4*target:
+		next-- // Halving the average lands closer to the target
+	}
+	if next < MinDifficulty {
+		return MinDifficulty
+	}
+	if next > MaxDifficulty {
+		return MaxDifficulty
+	}
+	return next
+}
+
+// nextDifficulty computes the difficulty for a child of the given block from
+// the mini-block solve times over the last DifficultyWindow blocks of its
+// branch, which checkSolveTime has matched to the SolveTime in each header.
+func nextDifficulty(parent *blockNode) int {
+	var solveTimes []int64
+	node := parent
+	for i := 0; i < DifficultyWindow && node != nil; i++ {
+		for _, sub := range node.block.SubBlocks {
+			for _, mini := range sub.MiniBlocks {
+				solveTimes = append(solveTimes, mini.SolveTime)
+			}
+		}
+		node = node.parent
+	}
+	return calculateDynamicDifficulty(parent.block.Difficulty, solveTimes)
+}
+
+// miniBlockWork returns the expected number of hashes needed to mine one
+// mini-block at the given difficulty.
+func miniBlockWork(difficulty int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(difficulty))
+}
+
+// withinRetarget reports whether retargeting can move a parent's difficulty
+// to the given one whatever the solve times: by at most one bit, within the
+// difficulty bounds.
+func withinRetarget(difficulty, parent int) bool {
+	return difficulty >= MinDifficulty && difficulty <= MaxDifficulty && difficulty-parent <= 1 && parent-difficulty <= 1
+}
+
+// checkSolveTime verifies that the solve times of a block's mini-blocks add
+// up to the SolveTime in its header, which the block hash commits to, and that
+// the miner did not spend longer on them than the time between the parent's
+// timestamp and the block's, plus the second timestamps are rounded down to.
+// Mini-blocks are mined on the parent, so inflated solve times that would
+// drive the difficulty down need timestamps moved just as far.
+func checkSolveTime(block, parent *Block) error {
+	limit := (block.Timestamp - parent.Timestamp + 1) * int64(time.Second)
+	var total int64
+	for _, sub := range block.SubBlocks {
+		for _, mini := range sub.MiniBlocks {
+			if mini.SolveTime < 0 || mini.SolveTime > limit-total {
+				return fmt.Errorf("mini-block solve times exceed the %d seconds since the parent block", block.Timestamp-parent.Timestamp)
+			}
+			total += mini.SolveTime
+		}
+	}
+	if total != block.SolveTime {
+		return fmt.Errorf("mini-block solve times add up to %d, header has %d", total, block.SolveTime)
+	}
+	return nil
+}
+
+// totalSolveTime returns the sum of the solve times of a block's mini-blocks.
+func (b *Block) totalSolveTime() int64 {
+	var total int64
+	for _, sub := range b.SubBlocks {
+		for _, mini := range sub.MiniBlocks {
+			total += mini.SolveTime
+		}
+	}
+	return total
+}
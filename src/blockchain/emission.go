@@ -0,0 +1,218 @@
+package blockchain
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PopulationRecord is the world population in a calendar year.
+type PopulationRecord struct {
+	Year       int   // Calendar year
+	Population int64 // World population in that year
+}
+
+// EmissionConfig holds the emission schedule, which issues coins each year in
+// proportion to the growth of the world population. Every node must use the
+// same schedule, since block rewards are part of the state root.
+type EmissionConfig struct {
+	Population     []PopulationRecord // World population by year, oldest first; nothing is emitted without it
+	GenesisYear    int                // Calendar year of the first emission year, which starts at block 1
+	BlocksPerYear  int                // Blocks in an emission year
+	CoinsPerPerson Amount             // Coins issued per person of population growth
+	ProposerShare  float64            // Fraction of each block reward paid to the proposer, the rest going to validators by stake
+}
+
+// DefaultEmissionConfig returns the default emission parameters, without a
+// population series.
+func DefaultEmissionConfig() EmissionConfig {
+	return EmissionConfig{
+		GenesisYear:    2025,
+		BlocksPerYear:  365 * 24 * 60 * 6,
+		CoinsPerPerson: OneBMT,
+		ProposerShare:  0.2,
+	}
+}
+
+// checkPopulation verifies that a population series is ordered by year,
+// without duplicate years, and counts people.
+func checkPopulation(records []PopulationRecord) error {
+	for i, record := range records {
+		if record.Population <= 0 {
+			return fmt.Errorf("population of %d must be positive", record.Year)
+		}
+		if i > 0 && record.Year <= records[i-1].Year {
+			return fmt.Errorf("population of %d follows %d, years must increase", record.Year, records[i-1].Year)
+		}
+	}
+	return nil
+}
+
+// LoadPopulationFile reads a population series from a CSV file of year and
+// population pairs. A header row and lines starting with # are skipped.
+func LoadPopulationFile(path string) ([]PopulationRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening population file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	var records []PopulationRecord
+	for line := 1; ; line++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading population file: %v", err)
+		}
+		year, yearErr := strconv.Atoi(strings.TrimSpace(fields[0]))
+		population, populationErr := strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64)
+		if line == 1 && yearErr != nil {
+			continue
+		}
+		if yearErr != nil || populationErr != nil {
+			return nil, fmt.Errorf("invalid population record %q on line %d", strings.Join(fields, ","), line)
+		}
+		records = append(records, PopulationRecord{Year: year, Population: population})
+	}
+	if err := checkPopulation(records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// population returns the population of a year in the series, if recorded.
+func (c *EmissionConfig) population(year int) (int64, bool) {
+	i := sort.Search(len(c.Population), func(i int) bool { return c.Population[i].Year >= year })
+	if i == len(c.Population) || c.Population[i].Year != year {
+		return 0, false
+	}
+	return c.Population[i].Population, true
+}
+
+// Year returns the calendar year of the emission year holding a height.
+func (c *EmissionConfig) Year(height int) int {
+	if c.BlocksPerYear <= 0 || height < 1 {
+		return c.GenesisYear
+	}
+	return c.GenesisYear + (height-1)/c.BlocksPerYear
+}
+
+// YearlyEmission returns the coins issued in a calendar year: the growth of
+// the population since the year before times CoinsPerPerson. Nothing is
+// issued for a year whose population, or the one before, is not recorded, or
+// in which the population shrank. The total supply cap is not applied.
+func (c *EmissionConfig) YearlyEmission(year int) (Amount, error) {
+	current, ok := c.population(year)
+	previous, hasPrevious := c.population(year - 1)
+	if !ok || !hasPrevious || current <= previous {
+		return 0, nil
+	}
+	return c.CoinsPerPerson.Mul(current - previous)
+}
+
+// BlockReward returns the coins the block at a height issues: an equal share
+// of its year's emission, with the base units left over going to the first
+// blocks of the year. The total supply cap is not applied.
+func (c *EmissionConfig) BlockReward(height int) (Amount, error) {
+	if c.BlocksPerYear <= 0 || height < 1 {
+		return 0, nil
+	}
+	budget, err := c.YearlyEmission(c.Year(height))
+	if err != nil {
+		return 0, err
+	}
+	reward, err := budget.Div(int64(c.BlocksPerYear))
+	if err != nil {
+		return 0, err
+	}
+	if int64((height-1)%c.BlocksPerYear) < int64(budget)%int64(c.BlocksPerYear) {
+		reward++
+	}
+	return reward, nil
+}
+
+// executeEmission mints the reward of a block, up to what the total supply
+// cap leaves after the coins the block burns. ProposerShare of the reward
+// goes to the proposer and the rest to the validators of the height in
+// proportion to their stake, with rounding leftovers going to the proposer.
+// Without a validator set the proposer receives the whole reward. Shares
+// that would take an address above the ownership cap are not minted.
+func (bc *Blockchain) executeEmission(execution *blockExecution) error {
+	reward, err := bc.Emission.BlockReward(execution.height)
+	if err != nil || reward == 0 {
+		return err
+	}
+	supply, err := bc.Tokenomics.TotalSupply.Sub(execution.burned)
+	if err != nil {
+		return err
+	}
+	if headroom := bc.Tokenomics.headroom(supply); reward > headroom {
+		reward = headroom
+	}
+	if reward == 0 {
+		return nil
+	}
+
+	remaining := reward
+	if validators := bc.ValidatorsAt(execution.height); validators != nil && validators.TotalStake() > 0 {
+		proposerShare, err := reward.MulRate(bc.Emission.ProposerShare)
+		if err != nil {
+			return err
+		}
+		stakersShare, err := reward.Sub(proposerShare)
+		if err != nil {
+			return errors.New("proposer share exceeds the block reward")
+		}
+		for _, validator := range validators.Validators {
+			share, err := stakersShare.MulDiv(int64(validator.Stake), int64(validators.TotalStake()))
+			if err != nil {
+				return err
+			}
+			if share > 0 {
+				execution.mint(bc, validator.Address, share)
+				remaining -= share
+			}
+		}
+	}
+	execution.mint(bc, execution.proposer, remaining)
+	return nil
+}
+
+// mint credits newly issued coins to an address, up to its ownership cap.
+func (execution *blockExecution) mint(bc *Blockchain, address string, amount Amount) {
+	bc.loadBalances(execution, address)
+	if room := bc.ownershipRoom(execution, address); amount > room {
+		amount = room
+	}
+	if amount == 0 {
+		return
+	}
+	execution.balances[address] += amount
+	execution.rewards[address] += amount
+	execution.minted += amount
+}
+
+// rewardEvents lists the minting of a block's rewards, by address.
+func rewardEvents(block *Block, rewards map[string]Amount) []Event {
+	var addresses []string
+	for address := range rewards {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	events := make([]Event, 0, len(addresses))
+	for _, address := range addresses {
+		events = append(events, Event{Type: EventMint, Height: block.Index, BlockHash: block.Hash, To: address, Amount: rewards[address]})
+	}
+	return events
+}
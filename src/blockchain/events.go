@@ -0,0 +1,349 @@
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+)
+
+// EventType identifies the kind of change an event reports.
+type EventType string
+
+// Event types published on the event bus.
+const (
+	EventNewBlock       EventType = "new_block"       // A block was added to the canonical chain
+	EventReorg          EventType = "reorg"           // The canonical chain switched branch
+	EventTxIncluded     EventType = "tx_included"     // A transaction was included in a canonical block
+	EventTransfer       EventType = "transfer"        // Coins moved between two addresses
+	EventMint           EventType = "mint"            // New coins were created
+	EventBridgeLock     EventType = "bridge_lock"     // Coins were locked in the cross-chain bridge
+	EventBridgeUnlock   EventType = "bridge_unlock"   // Coins were released from the cross-chain bridge
+	EventBridgeMint     EventType = "bridge_mint"     // Coins locked on the source chain were minted on the destination chain
+	EventSlash          EventType = "slash"           // A validator was slashed and jailed by evidence in a canonical block
+	EventVestingRelease EventType = "vesting_release" // Vested coins were released to their beneficiary
+	EventApproval       EventType = "approval"        // An owner changed the coins a spender may transfer on its behalf
+	EventBurn           EventType = "burn"            // Coins were destroyed
+)
+
+// BackpressurePolicy decides what happens to events for a subscriber whose
+// buffer is full. Publishing never waits for subscribers.
+type BackpressurePolicy int
+
+// Backpressure policies applied when a subscriber falls behind.
+const (
+	DropEvents  BackpressurePolicy = iota // Drop new events and count them
+	CloseOnFull                           // End the subscription
+)
+
+// DefaultEventBuffer is the number of undelivered events a subscription holds
+// before its backpressure policy applies.
+const DefaultEventBuffer = 256
+
+// Event is a change to the chain or the token ledger delivered to subscribers.
+type Event struct {
+	Type        EventType    // Kind of change
+	Height      int          // Height of the block the event belongs to, or -1 outside blocks
+	BlockHash   string       // Hash of that block, if any
+	Block       *Block       // The new block, for EventNewBlock
+	Reorg       *Reorg       // The reorganisation, for EventReorg
+	Transaction *Transaction // The transaction, for EventTxIncluded and chain transfers
+	Evidence    *Evidence    // The evidence, for EventSlash
+	From        string       // Sender of a transfer or burn, the slashed validator, or the owner of an allowance
+	To          string       // Receiver of a transfer or mint, the owner of bridged coins, or the spender of an allowance
+	Amount      Amount       // Amount transferred, minted, burned, locked or unlocked, or the allowance approved, added or removed
+	Token       string       // Symbol of the registered token a chain transfer is in, or empty for BMT
+}
+
+// EventFilter selects the events a subscription receives.
+type EventFilter struct {
+	Types   []EventType // Event types to deliver, or all if empty
+	Address string      // Only deliver events sent from or to this address or whose transaction touches it, if set
+}
+
+// SubscribeOptions configures a new subscription.
+type SubscribeOptions struct {
+	Filter     EventFilter        // Events to deliver
+	BufferSize int                // Undelivered events held before Policy applies, DefaultEventBuffer if zero
+	Policy     BackpressurePolicy // Handling of events for a full buffer
+	Replay     bool               // Replay past block events before live ones
+	FromHeight int                // Height to replay block events from
+}
+
+// EventBus delivers events to subscribers in publication order.
+type EventBus struct {
+	subscriptions map[int]*Subscription
+	nextID        int
+	mutex         sync.RWMutex
+}
+
+// Subscription receives the events matching its filter on Events. The
+// channel is closed once the subscription ends.
+type Subscription struct {
+	Events  <-chan Event // Delivered events, in publication order
+	id      int
+	bus     *EventBus
+	options SubscribeOptions
+	events  chan Event
+	queue   []Event       // Events waiting to be delivered
+	dropped uint64        // Events dropped because the buffer was full
+	wake    chan struct{} // Signals the delivery loop that the queue grew
+	done    chan struct{} // Closed when the subscription ends
+	once    sync.Once
+	mutex   sync.Mutex
+}
+
+// NewEventBus creates an event bus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subscriptions: make(map[int]*Subscription)}
+}
+
+// Subscribe registers a subscriber for live events. Replaying past blocks is
+// done through Blockchain.Subscribe.
+func (bus *EventBus) Subscribe(options SubscribeOptions) *Subscription {
+	return bus.subscribe(options, nil)
+}
+
+// subscribe registers a subscriber whose queue starts with the given events.
+// Replayed events do not count against the buffer.
+func (bus *EventBus) subscribe(options SubscribeOptions, replay []Event) *Subscription {
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultEventBuffer
+	}
+	events := make(chan Event)
+	sub := &Subscription{
+		Events:  events,
+		bus:     bus,
+		options: options,
+		events:  events,
+		queue:   replay,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	bus.mutex.Lock()
+	sub.id = bus.nextID
+	bus.nextID++
+	bus.subscriptions[sub.id] = sub
+	bus.mutex.Unlock()
+
+	go sub.run()
+	return sub
+}
+
+// Publish queues an event for every subscriber whose filter matches it.
+func (bus *EventBus) Publish(event Event) {
+	if bus == nil {
+		return
+	}
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+
+	for _, sub := range bus.subscriptions {
+		if sub.options.Filter.matches(event) {
+			sub.enqueue(event)
+		}
+	}
+}
+
+// matches reports whether an event passes the filter.
+func (filter EventFilter) matches(event Event) bool {
+	if filter.Address != "" && !event.involves(filter.Address) {
+		return false
+	}
+	if len(filter.Types) == 0 {
+		return true
+	}
+	for _, eventType := range filter.Types {
+		if event.Type == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// involves reports whether an event concerns an address, as its sender or
+// receiver or as any address its transaction touches.
+func (event Event) involves(address string) bool {
+	if event.From == address || event.To == address {
+		return true
+	}
+	if event.Transaction != nil {
+		for _, involved := range involvedAddresses(event.Transaction) {
+			if involved == address {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enqueue adds an event to the subscriber's queue, applying the backpressure
+// policy if the queue is full.
+func (sub *Subscription) enqueue(event Event) {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+
+	if len(sub.queue) >= sub.options.BufferSize {
+		sub.dropped++
+		if sub.options.Policy == CloseOnFull {
+			// The bus lock is held by Publish, so removal happens separately
+			go sub.Unsubscribe()
+		}
+		return
+	}
+	sub.queue = append(sub.queue, event)
+	select {
+	case sub.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers queued events to the subscriber until the subscription ends.
+func (sub *Subscription) run() {
+	defer close(sub.events)
+	for {
+		sub.mutex.Lock()
+		if len(sub.queue) == 0 {
+			sub.mutex.Unlock()
+			select {
+			case <-sub.wake:
+				continue
+			case <-sub.done:
+				return
+			}
+		}
+		event := sub.queue[0]
+		sub.queue = sub.queue[1:]
+		sub.mutex.Unlock()
+
+		select {
+		case sub.events <- event:
+		case <-sub.done:
+			return
+		}
+	}
+}
+
+// Dropped returns the number of events dropped because the subscriber fell behind.
+func (sub *Subscription) Dropped() uint64 {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	return sub.dropped
+}
+
+// Unsubscribe ends the subscription and closes its channel. Undelivered
+// events are discarded.
+func (sub *Subscription) Unsubscribe() {
+	sub.once.Do(func() {
+		sub.bus.mutex.Lock()
+		delete(sub.bus.subscriptions, sub.id)
+		sub.bus.mutex.Unlock()
+		close(sub.done)
+	})
+}
+
+// blockEvents lists the events of a canonical block: the block itself, the
+// slashing of each validator its evidence punishes, the inclusion and effect
+// of each of its transactions, then the minting of its rewards. Blocks held
+// as headers only produce the block event alone, and blocks whose rewards
+// the store does not hold, such as those imported in a snapshot, produce no
+// mints.
+func (bc *Blockchain) blockEvents(block *Block) []Event {
+	events := []Event{{Type: EventNewBlock, Height: block.Index, BlockHash: block.Hash, Block: block}}
+	for i := range block.Evidence {
+		evidence := &block.Evidence[i]
+		events = append(events, Event{Type: EventSlash, Height: block.Index, BlockHash: block.Hash, Evidence: evidence, From: evidence.Validator})
+	}
+	transactions := block.Transactions()
+	for i := range transactions {
+		tx := &transactions[i]
+		events = append(events, Event{
+			Type:        EventTxIncluded,
+			Height:      block.Index,
+			BlockHash:   block.Hash,
+			Transaction: tx,
+			From:        tx.Sender,
+			To:          tx.Receiver,
+			Amount:      tx.Amount,
+			Token:       tx.Token,
+		})
+		if effect, ok := transactionEffect(tx); ok {
+			effect.Height, effect.BlockHash, effect.Transaction = block.Index, block.Hash, tx
+			events = append(events, effect)
+		}
+	}
+	if !block.HeaderOnly() {
+		if rewards, err := bc.Store.GetRewards(block.Index); err == nil {
+			events = append(events, rewardEvents(block, rewards)...)
+		}
+	}
+	return events
+}
+
+// transactionEffect returns the event reporting what a transaction moved or
+// approved: a transfer or burn, a transfer out of an allowance from its
+// owner or a payout from the system address, or an approval. Other
+// governance transactions have no such event.
+func transactionEffect(tx *Transaction) (Event, bool) {
+	switch {
+	case tx.Receiver == PayoutAddress:
+		return Event{Type: EventTransfer, From: SystemAddress, To: tx.Target, Amount: tx.Amount}, true
+	case isGovernanceAddress(tx.Receiver):
+		return Event{}, false
+	case tx.Permit != nil:
+		return Event{Type: EventApproval, From: tx.Permit.Owner, To: tx.Permit.Spender, Amount: tx.Permit.Amount}, true
+	case isAllowanceAddress(tx.Receiver):
+		return Event{Type: EventApproval, From: tx.Sender, To: tx.Target, Amount: tx.Amount}, true
+	case tx.Receiver == BurnAddress:
+		return Event{Type: EventBurn, From: tx.Sender, To: tx.Receiver, Amount: tx.Amount, Token: tx.Token}, true
+	case tx.Target != "":
+		return Event{Type: EventTransfer, From: tx.Target, To: tx.Receiver, Amount: tx.Amount}, true
+	}
+	return Event{Type: EventTransfer, From: tx.Sender, To: tx.Receiver, Amount: tx.Amount, Token: tx.Token}, true
+}
+
+// publishBlock publishes the events of a block added to the canonical chain,
+// unless a reorganisation is in progress.
+func (bc *Blockchain) publishBlock(block *Block) {
+	if bc.reorganizing {
+		return
+	}
+	for _, event := range bc.blockEvents(block) {
+		bc.Events.Publish(event)
+	}
+}
+
+// publishReorg publishes a completed reorganisation followed by the events of
+// the blocks it attached.
+func (bc *Blockchain) publishReorg(reorg *Reorg) {
+	tip := bc.Chain[len(bc.Chain)-1]
+	bc.Events.Publish(Event{Type: EventReorg, Height: tip.Index, BlockHash: tip.Hash, Reorg: reorg})
+	for _, block := range reorg.Attached {
+		bc.publishBlock(block)
+	}
+}
+
+// Subscribe registers a subscriber on the chain's event bus. With Replay set,
+// the block events of the canonical chain from FromHeight are replayed
+// before any live event, with nothing missed or repeated in between. Block
+// rewards are replayed with their blocks; mints outside blocks and bridge
+// events are only delivered live.
+func (bc *Blockchain) Subscribe(options SubscribeOptions) (*Subscription, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	var replay []Event
+	if !options.Replay {
+		return bc.Events.subscribe(options, replay), nil
+	}
+	if options.FromHeight < 0 || options.FromHeight >= len(bc.Chain) {
+		return nil, fmt.Errorf("cannot replay from height %d, the tip is at %d", options.FromHeight, len(bc.Chain)-1)
+	}
+	for height := options.FromHeight; height < len(bc.Chain); height++ {
+		for _, event := range bc.blockEvents(bc.Chain[height]) {
+			if options.Filter.matches(event) {
+				replay = append(replay, event)
+			}
+		}
+	}
+	return bc.Events.subscribe(options, replay), nil
+}
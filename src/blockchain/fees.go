@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Fee market parameters. Every transaction pays BaseFee per unit of gas,
+// which is burned; anything offered above that is a tip for the proposer.
+const (
+	GasPerByte                        = 1                           // Gas charged per byte of transaction
+	InitialBaseFee             Amount = 10                          // Base fee per gas at genesis
+	MinBaseFee                 Amount = 1                           // Floor for the base fee per gas
+	TargetTransactionsPerBlock        = MaxTransactionsPerBlock / 2 // Block fullness the base fee steers toward
+	BaseFeeChangeDenominator          = 8                           // Limits base fee change to 1/8 per block
+	signatureOverhead                 = 128 + 128                   // Hex-encoded public key and signature
+)
+
+// ErrFeeTooLow is returned when a transaction does not cover the base fee.
+var ErrFeeTooLow = errors.New("transaction fee does not cover the base fee")
+
+// TransactionGas returns the gas used by a transaction, derived from its size.
+// Signature fields are counted at their fixed encoded size so the gas is the
+// same before and after signing.
+func TransactionGas(tx *Transaction) int64 {
+	unsigned := *tx
+	unsigned.PublicKey, unsigned.Signature = "", ""
+	return int64(calculateTransactionsSize([]Transaction{unsigned})+signatureOverhead) * GasPerByte
+}
+
+// RequiredFee returns the burned portion of a transaction's fee at a base fee.
+// Governance payouts, which only the governance key can sign, burn nothing.
+func RequiredFee(tx *Transaction, baseFee Amount) (Amount, error) {
+	if tx.Receiver == PayoutAddress {
+		return 0, nil
+	}
+	return baseFee.Mul(TransactionGas(tx))
+}
+
+// SplitFee divides a transaction's fee into the burned base fee and the
+// proposer's tip, failing if the fee does not cover the base fee.
+func SplitFee(tx *Transaction, baseFee Amount) (burned, tip Amount, err error) {
+	burned, err = RequiredFee(tx, baseFee)
+	if err != nil {
+		return 0, 0, err
+	}
+	tip, err = tx.Fee.Sub(burned)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: requires %s, offered %s", ErrFeeTooLow, burned, tx.Fee)
+	}
+	return burned, tip, nil
+}
+
+// NextBaseFee computes the base fee for the child of a block, raising it when
+// the parent held more than TargetTransactionsPerBlock transactions and
+// lowering it when it held fewer.
+func NextBaseFee(parent *Block) Amount {
+	baseFee := parent.BaseFee
+	if baseFee < MinBaseFee {
+		baseFee = MinBaseFee
+	}
+
+	count := int64(len(parent.Transactions()))
+	delta, err := baseFee.MulDiv(count-TargetTransactionsPerBlock, TargetTransactionsPerBlock*BaseFeeChangeDenominator)
+	if err != nil {
+		return baseFee
+	}
+	if delta == 0 && count > TargetTransactionsPerBlock {
+		delta = 1
+	}
+
+	next := baseFee + delta
+	if next < MinBaseFee {
+		return MinBaseFee
+	}
+	return next
+}
@@ -0,0 +1,404 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// commitLogName is the file within the data directory holding the commit log.
+const commitLogName = "chain.log"
+
+// ErrCorruptCommitLog is returned when opening a commit log whose damaged
+// record is followed by further records, which a crash cannot produce.
+var ErrCorruptCommitLog = errors.New("commit log is corrupt")
+
+// commitRecord is a single atomic entry in the commit log: a committed block
+// with its state update, the hash of a reverted tip block, or the blocks of an
+// imported or compacted snapshot with its complete account state.
+type commitRecord struct {
+	Block    *Block                `json:"block,omitempty"`
+	Update   StateUpdate           `json:"update"`
+	Revert   string                `json:"revert,omitempty"`
+	Snapshot []*Block              `json:"snapshot,omitempty"`
+	TxIndex  map[string]TxLocation `json:"txIndex,omitempty"` // Locations of transactions in pruned snapshot blocks
+	History  map[string][]string   `json:"history,omitempty"` // Address history from pruned snapshot blocks
+	// Elections holds the validators elected by snapshot blocks ending epochs
+	Elections map[int][]Validator `json:"elections,omitempty"`
+	// Rewards holds the block rewards of snapshot blocks still holding bodies
+	Rewards map[int]map[string]Amount `json:"rewards,omitempty"`
+}
+
+// FileStore is an embedded on-disk Store. Every block is written together
+// with its state update as one checksummed record in an append-only log, so
+// a crash leaves either the whole commit or none of it. Reverted blocks are
+// recorded as separate entries. The log is replayed into in-memory indexes
+// when the store is opened, and rewritten without pruned data when pruning.
+type FileStore struct {
+	*MemoryStore
+	file  *os.File
+	path  string // Path of the commit log
+	mutex sync.Mutex
+}
+
+// OpenFileStore opens (or creates) a file store in the given directory,
+// discarding any partially written record left by a crash.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating data directory: %v", err)
+	}
+
+	path := filepath.Join(dir, commitLogName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening commit log: %v", err)
+	}
+
+	fs := &FileStore{MemoryStore: NewMemoryStore(), file: file, path: path}
+	if err := fs.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return fs, nil
+}
+
+// replay loads every intact record and truncates a torn tail. A damaged
+// record anywhere before the tail is reported rather than discarded together
+// with the valid records after it.
+func (fs *FileStore) replay() error {
+	reader := bufio.NewReader(fs.file)
+	var offset int64
+	for record := 1; ; record++ {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break // A line without a newline is a torn write
+		}
+		if err != nil {
+			return fmt.Errorf("error reading commit log: %v", err)
+		}
+
+		decoded, ok := decodeCommitRecord(line)
+		if !ok || !fs.replayRecord(decoded) {
+			if _, err := reader.Peek(1); err == nil {
+				return fmt.Errorf("%w: record %d at offset %d", ErrCorruptCommitLog, record, offset)
+			}
+			break // Only the last record can be torn by a crash
+		}
+		offset += int64(len(line))
+	}
+
+	if err := fs.file.Truncate(offset); err != nil {
+		return fmt.Errorf("error truncating commit log: %v", err)
+	}
+	if _, err := fs.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking commit log: %v", err)
+	}
+	return nil
+}
+
+// replayRecord applies a decoded record, reporting whether it was consistent
+// with the records before it.
+func (fs *FileStore) replayRecord(record commitRecord) bool {
+	if record.Revert != "" {
+		height := len(fs.blocks) - 1
+		if fs.checkRevertHeight(height) != nil || fs.blocks[height].Hash != record.Revert {
+			return false
+		}
+		fs.applyRevert()
+		return true
+	}
+	if len(record.Snapshot) > 0 {
+		if checkSnapshotImport(record.Snapshot, len(fs.blocks)-1) != nil {
+			return false
+		}
+		fs.applySnapshot(record.Snapshot, record.Update)
+		for txHash, location := range record.TxIndex {
+			fs.txIndex[txHash] = location
+		}
+		// Pruned blocks precede every block replayed with its body
+		for address, hashes := range record.History {
+			fs.addressIndex[address] = append(append([]string(nil), hashes...), fs.addressIndex[address]...)
+		}
+		for height, validators := range record.Elections {
+			fs.elections[height] = validators
+			fs.electedAt = append(fs.electedAt, height)
+		}
+		sort.Ints(fs.electedAt)
+		for height, rewards := range record.Rewards {
+			if height < 1 || height >= len(fs.updates) {
+				return false
+			}
+			fs.updates[height].Rewards = rewards
+		}
+		return true
+	}
+	if checkCommitHeight(record.Block, len(fs.blocks)-1) != nil {
+		return false
+	}
+	fs.applyCommit(record.Block, record.Update)
+	return true
+}
+
+// CommitBlock durably appends a block and its state update, then applies
+// them to the in-memory indexes.
+func (fs *FileStore) CommitBlock(block *Block, update StateUpdate) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := checkCommitHeight(block, fs.Height()); err != nil {
+		return err
+	}
+
+	if err := fs.appendRecord(commitRecord{Block: block, Update: update}); err != nil {
+		return err
+	}
+	return fs.MemoryStore.CommitBlock(block, update)
+}
+
+// RevertBlock durably records the removal of the latest block, then restores
+// the in-memory state from before it.
+func (fs *FileStore) RevertBlock() (*Block, StateUpdate, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	height := fs.Height()
+	if err := fs.checkRevertHeight(height); err != nil {
+		return nil, StateUpdate{}, err
+	}
+	tip, err := fs.GetBlockByHeight(height)
+	if err != nil {
+		return nil, StateUpdate{}, err
+	}
+
+	if err := fs.appendRecord(commitRecord{Revert: tip.Hash}); err != nil {
+		return nil, StateUpdate{}, err
+	}
+	return fs.MemoryStore.RevertBlock()
+}
+
+// ImportSnapshot durably records an imported snapshot, then applies it to the
+// in-memory indexes.
+func (fs *FileStore) ImportSnapshot(blocks []*Block, update StateUpdate) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := checkSnapshotImport(blocks, fs.Height()); err != nil {
+		return err
+	}
+	if err := fs.appendRecord(commitRecord{Snapshot: blocks, Update: update}); err != nil {
+		return err
+	}
+	return fs.MemoryStore.ImportSnapshot(blocks, update)
+}
+
+// Prune discards old undo data and bodies, then compacts the commit log so
+// that the pruned data no longer takes up disk space.
+func (fs *FileStore) Prune(stateHeight, bodyHeight int) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := fs.MemoryStore.Prune(stateHeight, bodyHeight); err != nil {
+		return err
+	}
+	return fs.compact()
+}
+
+// compact rewrites the commit log from the in-memory indexes. The blocks up to
+// the revert floor become one snapshot record holding the account state at
+// the floor and the locations and address history of transactions in pruned
+// bodies; every later
+// block keeps its own record with its undo data. The new log replaces the old
+// one by an atomic rename, so a crash leaves one or the other intact.
+func (fs *FileStore) compact() error {
+	ms := fs.MemoryStore
+	ms.mutex.RLock()
+	records := []commitRecord{{Block: ms.blocks[0], Update: ms.updates[0]}}
+	if ms.revertFloor > 0 {
+		records = append(records, ms.floorRecord())
+	}
+	for height := ms.revertFloor + 1; height < len(ms.blocks); height++ {
+		records = append(records, commitRecord{Block: ms.blocks[height], Update: ms.updates[height]})
+	}
+	ms.mutex.RUnlock()
+
+	compacted := fs.path + ".compact"
+	file, err := os.OpenFile(compacted, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("error creating compacted commit log: %v", err)
+	}
+	writer := bufio.NewWriter(file)
+	for _, record := range records {
+		line, err := encodeCommitRecord(record)
+		if err == nil {
+			_, err = writer.Write(line)
+		}
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("error writing compacted commit log: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing compacted commit log: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("error syncing compacted commit log: %v", err)
+	}
+	if err := os.Rename(compacted, fs.path); err != nil {
+		file.Close()
+		return fmt.Errorf("error replacing commit log: %v", err)
+	}
+	if dir, err := os.Open(filepath.Dir(fs.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	fs.file.Close()
+	fs.file = file
+	return nil
+}
+
+// floorRecord builds the snapshot record covering the blocks up to the revert
+// floor, winding the account state back from the tip with the undo data of
+// the later blocks.
+func (ms *MemoryStore) floorRecord() commitRecord {
+	accounts := make(map[string]Account, len(ms.accounts))
+	for address, account := range ms.accounts {
+		accounts[address] = account
+	}
+	totalSupply, minted, burned := ms.totalSupply, ms.minted, ms.burned
+	for height := len(ms.blocks) - 1; height > ms.revertFloor; height-- {
+		for address, account := range ms.updates[height].Previous {
+			accounts[address] = account
+		}
+		totalSupply = ms.updates[height].PreviousSupply
+		minted -= ms.updates[height].Minted
+		burned -= ms.updates[height].Burned
+	}
+	for address, account := range accounts {
+		if account == (Account{}) {
+			delete(accounts, address)
+		}
+	}
+
+	txIndex := make(map[string]TxLocation)
+	for txHash, location := range ms.txIndex {
+		if location.Height <= ms.bodyFloor {
+			txIndex[txHash] = location
+		}
+	}
+	history := make(map[string][]string)
+	for address, hashes := range ms.addressIndex {
+		pruned := 0
+		for pruned < len(hashes) && ms.txIndex[hashes[pruned]].Height <= ms.bodyFloor {
+			pruned++
+		}
+		if pruned > 0 {
+			history[address] = hashes[:pruned]
+		}
+	}
+	elections := make(map[int][]Validator)
+	for _, height := range ms.electedAt {
+		if height <= ms.revertFloor {
+			elections[height] = ms.elections[height]
+		}
+	}
+	rewards := make(map[int]map[string]Amount)
+	for height := ms.bodyFloor + 1; height <= ms.revertFloor; height++ {
+		if blockRewards := ms.updates[height].Rewards; blockRewards != nil {
+			rewards[height] = blockRewards
+		}
+	}
+	return commitRecord{
+		Snapshot:  ms.blocks[1 : ms.revertFloor+1],
+		Update:    StateUpdate{Accounts: accounts, TotalSupply: totalSupply, Minted: minted, Burned: burned},
+		TxIndex:   txIndex,
+		History:   history,
+		Elections: elections,
+		Rewards:   rewards,
+	}
+}
+
+// appendRecord writes a record to the end of the log and syncs it to disk. If
+// either fails, the log is truncated back to its previous end so that a
+// partial record never precedes the next one.
+func (fs *FileStore) appendRecord(record commitRecord) error {
+	line, err := encodeCommitRecord(record)
+	if err != nil {
+		return err
+	}
+	offset, err := fs.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("error seeking commit log: %v", err)
+	}
+	if _, err := fs.file.Write(line); err != nil {
+		return fs.rollback(offset, fmt.Errorf("error writing commit log: %v", err))
+	}
+	if err := fs.file.Sync(); err != nil {
+		return fs.rollback(offset, fmt.Errorf("error syncing commit log: %v", err))
+	}
+	return nil
+}
+
+// rollback truncates the log back to offset after a failed append and
+// returns the append error, together with any error from the truncation.
+func (fs *FileStore) rollback(offset int64, cause error) error {
+	if err := fs.file.Truncate(offset); err != nil {
+		return fmt.Errorf("%v; error truncating commit log: %v", cause, err)
+	}
+	if _, err := fs.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("%v; error seeking commit log: %v", cause, err)
+	}
+	return cause
+}
+
+// Close closes the underlying commit log.
+func (fs *FileStore) Close() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.file.Close()
+}
+
+// encodeCommitRecord serialises a record as "<checksum> <json>\n".
+func encodeCommitRecord(record commitRecord) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding commit record: %v", err)
+	}
+	checksum := sha256.Sum256(data)
+
+	var buffer bytes.Buffer
+	buffer.WriteString(hex.EncodeToString(checksum[:]))
+	buffer.WriteByte(' ')
+	buffer.Write(data)
+	buffer.WriteByte('\n')
+	return buffer.Bytes(), nil
+}
+
+// decodeCommitRecord parses a log line and verifies its checksum.
+func decodeCommitRecord(line []byte) (commitRecord, bool) {
+	var record commitRecord
+	parts := bytes.SplitN(bytes.TrimSuffix(line, []byte("\n")), []byte(" "), 2)
+	if len(parts) != 2 {
+		return record, false
+	}
+
+	checksum := sha256.Sum256(parts[1])
+	if hex.EncodeToString(checksum[:]) != string(parts[0]) {
+		return record, false
+	}
+	if err := json.Unmarshal(parts[1], &record); err != nil || record.Block == nil && record.Revert == "" && len(record.Snapshot) == 0 {
+		return record, false
+	}
+	return record, true
+}
@@ -0,0 +1,212 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// MaxReorgDepth is the number of blocks below the tip after which a block is
+// final. Side branches forking deeper than this are rejected.
+const MaxReorgDepth = 64
+
+// Errors returned when a block cannot be placed in the block tree.
+var (
+	ErrKnownBlock    = errors.New("block is already known")
+	ErrUnknownParent = errors.New("parent block is unknown")
+	ErrReorgTooDeep  = errors.New("block forks below the finalized height")
+)
+
+// Reorg describes a switch of the canonical chain to another branch.
+type Reorg struct {
+	CommonAncestor *Block   // Last block shared by both branches
+	Detached       []*Block // Blocks removed from the canonical chain, tip first
+	Attached       []*Block // Blocks added to the canonical chain, in height order
+}
+
+// blockNode is a known block, canonical or on a side branch, in the block tree.
+type blockNode struct {
+	block  *Block
+	parent *blockNode
+	weight *big.Int // Cumulative work from genesis up to and including the block
+}
+
+// BlockWork returns the work a block adds to the weight of its branch: one
+// unit for the block itself plus the expected hashes behind each mini-block.
+func BlockWork(block *Block) *big.Int {
+	work := big.NewInt(1)
+	for _, sub := range block.SubBlocks {
+		for range sub.MiniBlocks {
+			work.Add(work, miniBlockWork(block.Difficulty))
+		}
+	}
+	return work
+}
+
+// addNode records a block in the block tree under its parent.
+func (bc *Blockchain) addNode(block *Block, parent *blockNode) *blockNode {
+	weight := BlockWork(block)
+	if parent != nil {
+		weight.Add(weight, parent.weight)
+	}
+	node := &blockNode{block: block, parent: parent, weight: weight}
+	bc.blocks[block.Hash] = node
+	return node
+}
+
+// tipNode returns the block tree node of the canonical tip.
+func (bc *Blockchain) tipNode() *blockNode {
+	return bc.blocks[bc.Chain[len(bc.Chain)-1].Hash]
+}
+
+// isCanonical reports whether a node is part of the canonical chain.
+func (bc *Blockchain) isCanonical(node *blockNode) bool {
+	index := node.block.Index
+	return index < len(bc.Chain) && bc.Chain[index].Hash == node.block.Hash
+}
+
+// ProcessBlock adds a block received from a peer to the block tree. A block
+// extending the tip is applied directly; a block that makes a side branch
+// heavier than the canonical chain triggers a reorganisation, which is
+// returned. A block on a lighter branch is kept until its branch wins or falls
+// below the finalized height.
+func (bc *Blockchain) ProcessBlock(block *Block) (*Reorg, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if _, known := bc.blocks[block.Hash]; known {
+		return nil, ErrKnownBlock
+	}
+	parent, exists := bc.blocks[block.PreviousHash]
+	if !exists {
+		return nil, ErrUnknownParent
+	}
+	if err := checkHeader(block, parent); err != nil {
+		return nil, err
+	}
+	if err := bc.checkCommit(block); err != nil {
+		return nil, err
+	}
+	if err := bc.checkProposer(block); err != nil {
+		return nil, err
+	}
+
+	tip := bc.tipNode()
+	if block.Index <= tip.block.Index-MaxReorgDepth {
+		return nil, ErrReorgTooDeep
+	}
+
+	node := bc.addNode(block, parent)
+	defer bc.pruneSideBranches()
+	if node.weight.Cmp(tip.weight) <= 0 {
+		return nil, nil
+	}
+	if parent == tip {
+		if err := bc.applyBlock(block); err != nil {
+			bc.discardBranch(node)
+			return nil, err
+		}
+		return nil, nil
+	}
+	return bc.reorganize(node)
+}
+
+// reorganize switches the canonical chain to the branch ending at newTip. It
+// reverts blocks back to the common ancestor and applies the new branch. If a
+// block on the new branch fails to execute, that block and its descendants
+// are discarded and the original branch is restored. Events are published
+// only once the switch has succeeded.
+func (bc *Blockchain) reorganize(newTip *blockNode) (*Reorg, error) {
+	var branch []*blockNode
+	ancestor := newTip
+	for !bc.isCanonical(ancestor) {
+		branch = append([]*blockNode{ancestor}, branch...)
+		ancestor = ancestor.parent
+	}
+	if bc.tipNode().block.Index-ancestor.block.Index > MaxReorgDepth {
+		return nil, ErrReorgTooDeep
+	}
+
+	bc.reorganizing = true
+	defer func() { bc.reorganizing = false }()
+
+	detached, err := bc.revertTo(ancestor.block.Index)
+	if err != nil {
+		return nil, fmt.Errorf("error reverting to block %d: %v", ancestor.block.Index, err)
+	}
+
+	var attached []*Block
+	for _, node := range branch {
+		if err := bc.applyBlock(node.block); err != nil {
+			bc.discardBranch(node)
+			if restoreErr := bc.restoreBranch(ancestor.block.Index, detached); restoreErr != nil {
+				return nil, fmt.Errorf("%v (restoring previous branch failed: %v)", err, restoreErr)
+			}
+			return nil, fmt.Errorf("error applying block %d of new branch: %v", node.block.Index, err)
+		}
+		attached = append(attached, node.block)
+	}
+
+	reorg := &Reorg{CommonAncestor: ancestor.block, Detached: detached, Attached: attached}
+	bc.reorganizing = false
+	bc.publishReorg(reorg)
+	return reorg, nil
+}
+
+// revertTo reverts canonical blocks until the tip is at the given height,
+// returning the reverted blocks tip first.
+func (bc *Blockchain) revertTo(height int) ([]*Block, error) {
+	var detached []*Block
+	for len(bc.Chain)-1 > height {
+		block, update, err := bc.Store.RevertBlock()
+		if err != nil {
+			return detached, err
+		}
+		bc.setState(update.Previous, update.PreviousSupply)
+		bc.Chain = bc.Chain[:len(bc.Chain)-1]
+		bc.dropSnapshotsAbove(len(bc.Chain) - 1)
+		detached = append(detached, block)
+	}
+	return detached, nil
+}
+
+// restoreBranch reverts back to the given height and reapplies the detached
+// blocks, which are given tip first.
+func (bc *Blockchain) restoreBranch(height int, detached []*Block) error {
+	if _, err := bc.revertTo(height); err != nil {
+		return err
+	}
+	for i := len(detached) - 1; i >= 0; i-- {
+		if err := bc.applyBlock(detached[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discardBranch removes an invalid block and all its descendants from the
+// block tree.
+func (bc *Blockchain) discardBranch(root *blockNode) {
+	for hash, node := range bc.blocks {
+		for ancestor := node; ancestor != nil && ancestor.block.Index >= root.block.Index; ancestor = ancestor.parent {
+			if ancestor == root {
+				delete(bc.blocks, hash)
+				break
+			}
+		}
+	}
+}
+
+// pruneSideBranches drops side branch blocks that can no longer win because
+// they are below the finalized height.
+func (bc *Blockchain) pruneSideBranches() {
+	if len(bc.blocks) == len(bc.Chain) {
+		return
+	}
+	finalized := len(bc.Chain) - 1 - MaxReorgDepth
+	for hash, node := range bc.blocks {
+		if node.block.Index <= finalized && !bc.isCanonical(node) {
+			delete(bc.blocks, hash)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+)
+
+// Seconds in a year of vesting.
+const secondsPerYear int64 = 365 * 24 * 60 * 60
+
+// AllocationBucket is a share of the initial supply set aside at genesis.
+// Grants to beneficiaries are paid out of the bucket and locked by their
+// vesting schedules; the rest of the bucket goes to the bucket's address.
+type AllocationBucket struct {
+	Name    string            // Name of the bucket
+	Address string            // Address holding the part of the bucket not granted to beneficiaries
+	Share   float64           // Fraction of the initial supply in the bucket
+	Grants  []VestingSchedule // Grants paid out of the bucket, locked until they vest
+}
+
+// GenesisConfig describes how the initial supply is allocated at genesis.
+// Every node must use the same configuration, since the allocation is part of
+// the genesis state root and the vesting schedules and governance address are
+// enforced by every block.
+type GenesisConfig struct {
+	Buckets    []AllocationBucket // Buckets the initial supply is split into, whose shares add up to one
+	Governance string             // Address whose signed governance transactions change protocol settings, or empty for none
+}
+
+// DefaultGenesis returns the allocation of the initial supply: 70% to the
+// community at the system address, and 10% each to the pre-sale, the founders
+// and team, and strategic partners. The founders' bucket is locked for two
+// years and then vests linearly until the end of the third year.
+func DefaultGenesis() GenesisConfig {
+	founders, _ := InitialSupply.MulRate(0.1)
+	return GenesisConfig{Buckets: []AllocationBucket{
+		{Name: "community", Address: SystemAddress, Share: 0.7},
+		{Name: "presale", Address: "allocation/presale", Share: 0.1},
+		{Name: "founders", Address: "allocation/founders", Share: 0.1, Grants: []VestingSchedule{{
+			Beneficiary: "allocation/founders",
+			Amount:      founders,
+			Start:       GenesisTimestamp,
+			Cliff:       2 * secondsPerYear,
+			Duration:    3 * secondsPerYear,
+		}}},
+		{Name: "partners", Address: "allocation/partners", Share: 0.1},
+	}}
+}
+
+// LoadGenesisFile reads the genesis configuration that every node of a
+// network shares from a JSON file. The configuration must name a governance
+// address, without which the community bucket could never be paid out, and
+// allocate the initial supply validly.
+func LoadGenesisFile(path string) (GenesisConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return GenesisConfig{}, fmt.Errorf("error opening genesis file: %v", err)
+	}
+	defer file.Close()
+
+	var genesis GenesisConfig
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&genesis); err != nil {
+		return GenesisConfig{}, fmt.Errorf("error reading genesis file: %v", err)
+	}
+	if genesis.Governance == "" {
+		return GenesisConfig{}, errors.New("genesis file names no governance address")
+	}
+	if _, err := genesis.Accounts(); err != nil {
+		return GenesisConfig{}, fmt.Errorf("invalid genesis allocation: %v", err)
+	}
+	return genesis, nil
+}
+
+// developmentGovernance is the governance wallet of development chains,
+// generated once per process so that the development chains of a process
+// accept each other's payouts. Separate processes generate different
+// wallets, so development chains are only meant for tests.
+var (
+	developmentGovernance *Wallet
+	developmentOnce       sync.Once
+)
+
+// DevelopmentGovernance returns the governance wallet of the development
+// chains in this process.
+func DevelopmentGovernance() *Wallet {
+	developmentOnce.Do(func() {
+		wallet, err := NewWallet()
+		if err != nil {
+			// Key generation only fails if the system's randomness does.
+			panic(err)
+		}
+		developmentGovernance = wallet
+	})
+	return developmentGovernance
+}
+
+// DevelopmentGenesis returns the default allocation governed by the
+// development governance wallet of this process, for tests. Networks of
+// separate nodes share a configuration from LoadGenesisFile instead.
+func DevelopmentGenesis() GenesisConfig {
+	genesis := DefaultGenesis()
+	genesis.Governance = DevelopmentGovernance().Address
+	return genesis
+}
+
+// Accounts returns the genesis balances of the allocation, with the coins
+// left over from rounding the shares going to the first bucket. Bucket
+// addresses start out exempt from the ownership cap, and the governance
+// address is kept in a record so that the genesis block commits to it.
+func (g GenesisConfig) Accounts() (map[string]Account, error) {
+	if len(g.Buckets) == 0 {
+		return nil, errors.New("genesis allocation needs at least one bucket")
+	}
+	var total float64
+	for _, bucket := range g.Buckets {
+		total += bucket.Share
+	}
+	if math.Abs(total-1) > 1e-9 {
+		return nil, fmt.Errorf("genesis allocation shares add up to %v, not 1", total)
+	}
+
+	accounts := make(map[string]Account)
+	credit := func(address string, amount Amount) error {
+		account := accounts[address]
+		balance, err := account.Balance.Add(amount)
+		if err != nil {
+			return err
+		}
+		account.Balance = balance
+		accounts[address] = account
+		return nil
+	}
+	granted := make(map[string]bool)
+	remaining := InitialSupply
+	for i := len(g.Buckets) - 1; i >= 0; i-- {
+		bucket := g.Buckets[i]
+		size := remaining
+		if i > 0 {
+			var err error
+			if size, err = InitialSupply.MulRate(bucket.Share); err != nil {
+				return nil, err
+			}
+		}
+		if size > remaining {
+			return nil, fmt.Errorf("bucket %s exceeds the initial supply", bucket.Name)
+		}
+		remaining -= size
+		for _, grant := range bucket.Grants {
+			if err := grant.validate(); err != nil {
+				return nil, err
+			}
+			if granted[grant.Beneficiary] {
+				return nil, fmt.Errorf("%s has more than one grant", grant.Beneficiary)
+			}
+			granted[grant.Beneficiary] = true
+			if grant.Amount > size {
+				return nil, fmt.Errorf("grants of bucket %s exceed its share of the initial supply", bucket.Name)
+			}
+			size -= grant.Amount
+			if err := credit(grant.Beneficiary, grant.Amount); err != nil {
+				return nil, err
+			}
+		}
+		if size > 0 {
+			if bucket.Address == "" {
+				return nil, fmt.Errorf("bucket %s needs an address for its ungranted coins", bucket.Name)
+			}
+			if err := credit(bucket.Address, size); err != nil {
+				return nil, err
+			}
+		}
+		if bucket.Address != "" {
+			account := accounts[bucket.Address]
+			account.CapExempt = true
+			accounts[bucket.Address] = account
+		}
+	}
+	if g.Governance != "" {
+		accounts[governanceRecord] = Account{Authority: g.Governance}
+	}
+	return accounts, nil
+}
+
+// Grants returns the vesting schedules of every bucket.
+func (g GenesisConfig) Grants() []VestingSchedule {
+	var grants []VestingSchedule
+	for _, bucket := range g.Buckets {
+		grants = append(grants, bucket.Grants...)
+	}
+	return grants
+}
@@ -0,0 +1,134 @@
+package blockchain
+
+import (
+	"errors"
+	"strings"
+)
+
+// Addresses that the governance address sends signed transactions to in
+// order to change protocol settings or pay out community coins. Only payouts
+// carry an amount; the Target of a governance transaction is the address the
+// change or payout applies to.
+const (
+	ExemptAddress   = "governance/exempt"   // Exempts the target from the ownership cap
+	UnexemptAddress = "governance/unexempt" // Removes the target's exemption from the ownership cap
+	PayoutAddress   = "governance/payout"   // Pays the amount and fee out of the community balance at the system address
+)
+
+// ErrNotGovernance is returned when a governance transaction is not sent by
+// the chain's governance address.
+var ErrNotGovernance = errors.New("sender is not the governance address")
+
+// governancePrefix starts every governance address.
+const governancePrefix = "governance/"
+
+// governanceRecord is the address of the genesis record whose Authority is
+// the chain's governance address, so that the genesis state root, and with it
+// the genesis hash, commits to the governance address.
+const governanceRecord = governancePrefix + "authority"
+
+// isGovernanceAddress reports whether an address is a governance address,
+// which no BMT can be sent to.
+func isGovernanceAddress(address string) bool {
+	return strings.HasPrefix(address, governancePrefix)
+}
+
+// NewCapExemptionTransaction creates a governance transaction exempting an
+// address from the ownership cap, or removing its exemption, and signs it
+// with the governance wallet.
+func NewCapExemptionTransaction(wallet *Wallet, address string, exempt bool, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	if address == "" {
+		return nil, errors.New("exempted address cannot be empty")
+	}
+	if fee < 0 {
+		return nil, errors.New("transaction fee cannot be negative")
+	}
+	receiver := UnexemptAddress
+	if exempt {
+		receiver = ExemptAddress
+	}
+	tx := &Transaction{
+		Sender:    wallet.Address,
+		Receiver:  receiver,
+		Fee:       fee,
+		Timestamp: timestamp,
+		Nonce:     nonce,
+		ChainID:   chainID,
+		Target:    address,
+	}
+	if err := tx.Sign(wallet); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// NewPayoutTransaction creates a governance transaction paying an amount out
+// of the community balance at the system address to a receiver, and signs it
+// with the governance wallet. The community balance pays the fee as well.
+func NewPayoutTransaction(wallet *Wallet, receiver string, amount, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	if receiver == "" {
+		return nil, errors.New("receiver address cannot be empty")
+	}
+	if amount <= 0 || fee < 0 {
+		return nil, errors.New("payout must be positive and its fee cannot be negative")
+	}
+	tx := &Transaction{
+		Sender:    wallet.Address,
+		Receiver:  PayoutAddress,
+		Amount:    amount,
+		Fee:       fee,
+		Timestamp: timestamp,
+		Nonce:     nonce,
+		ChainID:   chainID,
+		Target:    receiver,
+	}
+	if err := tx.Sign(wallet); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Payer returns the address whose balance a transaction spends: the system
+// address for payouts, and the sender otherwise.
+func (t *Transaction) Payer() string {
+	if t.Receiver == PayoutAddress {
+		return SystemAddress
+	}
+	return t.Sender
+}
+
+// executeGovernance records the effect of a governance transaction, which
+// only the governance address of the genesis configuration may send. The
+// amount of a payout is taken from the system address with the fee.
+func (bc *Blockchain) executeGovernance(tx *Transaction, execution *blockExecution) error {
+	if bc.Governance == "" || tx.Sender != bc.Governance {
+		return ErrNotGovernance
+	}
+	if tx.Target == "" || isProtocolAddress(tx.Target) {
+		return errors.New("governance transaction needs an account as its target")
+	}
+	switch tx.Receiver {
+	case ExemptAddress, UnexemptAddress:
+		execution.exemptions[tx.Target] = tx.Receiver == ExemptAddress
+		return nil
+	case PayoutAddress:
+		bc.loadBalances(execution, tx.Target)
+		if err := bc.checkOwnership(execution, tx.Target, tx.Amount); err != nil {
+			return err
+		}
+		execution.balances[tx.Target] += tx.Amount
+		return nil
+	}
+	return errors.New("unknown governance address")
+}
+
+// capExempt reports whether an address is exempt from the ownership cap,
+// including changes made earlier in the block.
+func (bc *Blockchain) capExempt(execution *blockExecution, address string) bool {
+	if exempt, changed := execution.exemptions[address]; changed {
+		return exempt
+	}
+	bc.Tokenomics.TransactionMutex.Lock()
+	defer bc.Tokenomics.TransactionMutex.Unlock()
+	return bc.Tokenomics.CapExempt[address]
+}
@@ -0,0 +1,34 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// encodeFields returns the canonical encoding of fields, each written as its
+// length in bytes, a colon and its bytes. Two different lists of fields never
+// share an encoding, and the encoding of a list followed by more fields
+// starts with the encoding of the list.
+func encodeFields(fields ...string) []byte {
+	var record []byte
+	for _, field := range fields {
+		record = strconv.AppendInt(record, int64(len(field)), 10)
+		record = append(record, ':')
+		record = append(record, field...)
+	}
+	return record
+}
+
+// hashFields returns the hex-encoded SHA-256 hash of the canonical encoding
+// of fields, so that a signature or proof of work over the hash cannot be
+// moved to another reading of the same bytes.
+func hashFields(fields ...string) string {
+	hash := sha256.Sum256(encodeFields(fields...))
+	return hex.EncodeToString(hash[:])
+}
+
+// formatInt formats an integer field for hashFields.
+func formatInt(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,130 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxHistoryPageSize is the largest number of transactions returned in one
+// page of address history.
+const MaxHistoryPageSize = 100
+
+// TxRecord is an indexed transaction with the place it was included.
+type TxRecord struct {
+	Transaction *Transaction `json:"transaction"` // The transaction
+	Location    TxLocation   `json:"location"`    // Where the transaction was included
+}
+
+// HistoryPage is one page of the transactions involving an address, newest first.
+type HistoryPage struct {
+	Address string     `json:"address"` // Address the history belongs to
+	Offset  int        `json:"offset"`  // Number of newer transactions skipped
+	Total   int        `json:"total"`   // Number of transactions involving the address
+	Records []TxRecord `json:"records"` // Transactions on this page, up to the first one whose block body was pruned
+}
+
+// indexAddresses appends the transactions of a block to the history of the
+// addresses they involve.
+func (ms *MemoryStore) indexAddresses(block *Block) {
+	for _, tx := range block.Transactions() {
+		for _, address := range involvedAddresses(&tx) {
+			ms.addressIndex[address] = append(ms.addressIndex[address], tx.Hash)
+		}
+	}
+}
+
+// unindexAddresses removes the transactions of the latest block from the
+// history of the addresses they involve.
+func (ms *MemoryStore) unindexAddresses(block *Block) {
+	for _, tx := range block.Transactions() {
+		for _, address := range involvedAddresses(&tx) {
+			history := ms.addressIndex[address]
+			if len(history) <= 1 {
+				delete(ms.addressIndex, address)
+				continue
+			}
+			ms.addressIndex[address] = history[:len(history)-1]
+		}
+	}
+}
+
+// GetAddressTxs returns up to limit hashes of transactions involving an
+// address, newest first after skipping offset of them, along with the total
+// number of such transactions.
+func (ms *MemoryStore) GetAddressTxs(address string, offset, limit int) ([]string, int, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	history := ms.addressIndex[address]
+	var hashes []string
+	for i := len(history) - 1 - offset; i >= 0 && len(hashes) < limit; i-- {
+		hashes = append(hashes, history[i])
+	}
+	return hashes, len(history), nil
+}
+
+// involvedAddresses lists the distinct addresses a transaction touches: its
+// sender, receiver and payer, and its target if it has one.
+func involvedAddresses(tx *Transaction) []string {
+	var addresses []string
+	seen := make(map[string]bool)
+	for _, address := range []string{tx.Sender, tx.Receiver, tx.Payer(), tx.Target} {
+		if address != "" && !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// GetTransaction looks up a transaction by hash with the block it was
+// included in. It returns ErrPruned if only the header of that block is kept.
+func (bc *Blockchain) GetTransaction(txHash string) (*TxRecord, error) {
+	location, err := bc.Store.GetTxLocation(txHash)
+	if err != nil {
+		return nil, err
+	}
+	block, err := bc.Store.GetBlockByHash(location.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if block.HeaderOnly() {
+		return nil, fmt.Errorf("%w: transaction %s is in the body of block %d", ErrPruned, txHash, location.Height)
+	}
+	tx := block.SubBlocks[location.SubBlock].MiniBlocks[location.MiniBlock].Transactions[location.Position]
+	return &TxRecord{Transaction: &tx, Location: location}, nil
+}
+
+// GetAddressHistory returns a page of the transactions sent or received by an
+// address, newest first. The page ends before the first transaction whose
+// block body was pruned, and ErrPruned is returned if the page would start
+// with one.
+func (bc *Blockchain) GetAddressHistory(address string, offset, limit int) (*HistoryPage, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("invalid page offset %d and limit %d", offset, limit)
+	}
+	if limit > MaxHistoryPageSize {
+		limit = MaxHistoryPageSize
+	}
+
+	hashes, total, err := bc.Store.GetAddressTxs(address, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	page := &HistoryPage{Address: address, Offset: offset, Total: total, Records: []TxRecord{}}
+	for _, txHash := range hashes {
+		record, err := bc.GetTransaction(txHash)
+		if errors.Is(err, ErrPruned) {
+			if len(page.Records) == 0 {
+				return nil, err
+			}
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error loading indexed transaction %s: %v", txHash, err)
+		}
+		page.Records = append(page.Records, *record)
+	}
+	return page, nil
+}
@@ -0,0 +1,372 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// Errors returned when the mempool refuses a transaction.
+var (
+	ErrAlreadyPending         = errors.New("transaction is already pending")
+	ErrReplacementUnderpriced = errors.New("replacement transaction fee is too low")
+	ErrMempoolFull            = errors.New("mempool is full and the transaction fee is too low")
+	ErrInsufficientFunds      = errors.New("insufficient balance for pending transactions")
+)
+
+// MempoolConfig holds the limits applied by a Mempool.
+type MempoolConfig struct {
+	MaxSize   int           // Maximum number of pending transactions
+	Expiry    time.Duration // Pending transactions older than this are dropped
+	PriceBump float64       // Minimum fractional fee increase to replace a transaction
+}
+
+// DefaultMempoolConfig returns the limits used by nodes unless configured otherwise.
+func DefaultMempoolConfig() MempoolConfig {
+	return MempoolConfig{
+		MaxSize:   5 * MaxTransactionsPerBlock,
+		Expiry:    3 * time.Hour,
+		PriceBump: 0.10,
+	}
+}
+
+// mempoolEntry is a pending transaction, the gas it uses and the time it was
+// accepted.
+type mempoolEntry struct {
+	tx    *Transaction
+	gas   int64
+	added time.Time
+}
+
+// Mempool holds verified transactions waiting to be included in a block.
+type Mempool struct {
+	Config   MempoolConfig
+	chain    *Blockchain
+	byHash   map[string]*mempoolEntry
+	bySender map[string]map[uint64]*mempoolEntry
+	mutex    sync.Mutex
+}
+
+// NewMempool creates a mempool that validates transactions against a blockchain.
+func NewMempool(chain *Blockchain, config MempoolConfig) *Mempool {
+	return &Mempool{
+		Config:   config,
+		chain:    chain,
+		byHash:   make(map[string]*mempoolEntry),
+		bySender: make(map[string]map[uint64]*mempoolEntry),
+	}
+}
+
+// Add validates a transaction against current state and adds it to the pool.
+// A transaction with the same sender and nonce as a pending one replaces it
+// only if its fee is higher by at least the configured price bump.
+func (mp *Mempool) Add(tx *Transaction) error {
+	if tx.ChainID != mp.chain.ChainID {
+		return ErrWrongChainID
+	}
+	if err := tx.VerifySignature(); err != nil {
+		return err
+	}
+	if err := checkShape(tx); err != nil {
+		return err
+	}
+	if tx.Fee < 0 {
+		return errors.New("invalid transaction fee")
+	}
+	if _, _, err := SplitFee(tx, mp.chain.CurrentBaseFee()); err != nil {
+		return err
+	}
+	if _, err := mp.chain.Store.GetTxLocation(tx.Hash); err == nil {
+		return ErrReplayedTransaction
+	}
+	stateNonce := mp.chain.GetNonce(tx.Sender)
+	if tx.Nonce < stateNonce {
+		return fmt.Errorf("%w: nonce %d already used", ErrInvalidNonce, tx.Nonce)
+	}
+	balance := mp.chain.Tokenomics.SpendableBalance(tx.Payer())
+
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	mp.prune(time.Now())
+	if _, exists := mp.byHash[tx.Hash]; exists {
+		return ErrAlreadyPending
+	}
+
+	existing := mp.bySender[tx.Sender][tx.Nonce]
+	if existing != nil && (tx.Fee <= existing.tx.Fee || tx.Fee < mp.minimumReplacementFee(existing.tx.Fee)) {
+		return ErrReplacementUnderpriced
+	}
+
+	// The payer must afford every pending transaction it pays for alongside this one
+	required, err := tx.Spend()
+	for nonce, entry := range mp.bySender[tx.Sender] {
+		if err == nil && nonce != tx.Nonce && entry.tx.Payer() == tx.Payer() {
+			var spend Amount
+			if spend, err = entry.tx.Spend(); err == nil {
+				required, err = required.Add(spend)
+			}
+		}
+	}
+	if err != nil || required > balance {
+		return ErrInsufficientFunds
+	}
+
+	entry := &mempoolEntry{tx: tx, gas: TransactionGas(tx), added: time.Now()}
+	if existing != nil {
+		mp.remove(existing)
+	} else if len(mp.byHash) >= mp.Config.MaxSize {
+		victim := mp.lowestRateEntry()
+		if victim == nil || compareFeeRate(entry, victim) <= 0 {
+			return ErrMempoolFull
+		}
+		mp.evictFrom(victim)
+	}
+
+	mp.byHash[tx.Hash] = entry
+	if mp.bySender[tx.Sender] == nil {
+		mp.bySender[tx.Sender] = make(map[uint64]*mempoolEntry)
+	}
+	mp.bySender[tx.Sender][tx.Nonce] = entry
+	return nil
+}
+
+// Pending returns up to max executable transactions in a deterministic order:
+// each sender's transactions in consecutive nonce order starting at the
+// sender's state nonce, interleaved by highest fee per gas first, with ties
+// broken by transaction hash. A sender's queue stops at the first transaction
+// whose fee no longer covers the current base fee. Each transaction is
+// executed after the ones selected before it, on top of the chain tip; one
+// that fails is evicted along with the sender's later transactions. If the
+// tip moves meanwhile, the selected transactions are executed again on the
+// new tip and those that no longer execute there are left out.
+func (mp *Mempool) Pending(max int) []*Transaction {
+	baseFee := mp.chain.CurrentBaseFee()
+
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	queues := make(map[string][]*mempoolEntry)
+	for sender, entries := range mp.bySender {
+		for nonce := mp.chain.GetNonce(sender); entries[nonce] != nil; nonce++ {
+			if _, _, err := SplitFee(entries[nonce].tx, baseFee); err != nil {
+				break
+			}
+			queues[sender] = append(queues[sender], entries[nonce])
+		}
+	}
+
+	scratch := mp.chain.newScratchBlock()
+	for len(scratch.accepted) < max {
+		var best *mempoolEntry
+		for _, queue := range queues {
+			if len(queue) > 0 && (best == nil || compareFeeRate(queue[0], best) > 0) {
+				best = queue[0]
+			}
+		}
+		if best == nil {
+			break
+		}
+		if err := scratch.try(best.tx); err != nil {
+			mp.evictFrom(best)
+			delete(queues, best.tx.Sender)
+			continue
+		}
+		queues[best.tx.Sender] = queues[best.tx.Sender][1:]
+	}
+	return scratch.accepted
+}
+
+// ProduceBlock adds a block built from the pending transactions to the chain
+// and drops the included transactions from the pool.
+func (mp *Mempool) ProduceBlock() error {
+	transactions := mp.Pending(MaxTransactionsPerBlock)
+	if len(transactions) == 0 {
+		return errors.New("no pending transactions to include")
+	}
+	if err := mp.chain.AddTransactionBlock(transactions); err != nil {
+		return err
+	}
+	mp.Prune()
+	return nil
+}
+
+// ReturnTransactions re-adds the transactions of blocks detached by a chain
+// reorganisation, skipping any that were included again or are no longer valid.
+// It returns the number of transactions put back in the pool.
+func (mp *Mempool) ReturnTransactions(blocks []*Block) int {
+	returned := 0
+	for _, block := range blocks {
+		for _, tx := range block.Transactions() {
+			tx := tx
+			if err := mp.Add(&tx); err == nil {
+				returned++
+			}
+		}
+	}
+	return returned
+}
+
+// Prune drops expired transactions and transactions whose nonce has already
+// been used on chain.
+func (mp *Mempool) Prune() {
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	for sender, entries := range mp.bySender {
+		stateNonce := mp.chain.GetNonce(sender)
+		for nonce, entry := range entries {
+			if nonce < stateNonce {
+				mp.remove(entry)
+			}
+		}
+	}
+	mp.prune(time.Now())
+}
+
+// Len returns the number of pending transactions.
+func (mp *Mempool) Len() int {
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+	return len(mp.byHash)
+}
+
+// Get retrieves a pending transaction by its hash.
+func (mp *Mempool) Get(hash string) (*Transaction, bool) {
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	entry, exists := mp.byHash[hash]
+	if !exists {
+		return nil, false
+	}
+	return entry.tx, true
+}
+
+// prune drops transactions older than the configured expiry.
+func (mp *Mempool) prune(now time.Time) {
+	if mp.Config.Expiry <= 0 {
+		return
+	}
+	for _, entry := range mp.byHash {
+		if now.Sub(entry.added) > mp.Config.Expiry {
+			mp.remove(entry)
+		}
+	}
+}
+
+// minimumReplacementFee returns the fee a replacement must at least offer.
+func (mp *Mempool) minimumReplacementFee(fee Amount) Amount {
+	bumped, err := fee.MulRate(1 + mp.Config.PriceBump)
+	if err != nil {
+		return fee
+	}
+	return bumped
+}
+
+// lowestRateEntry finds the transaction ranked last by fee per gas.
+func (mp *Mempool) lowestRateEntry() *mempoolEntry {
+	var lowest *mempoolEntry
+	for _, entry := range mp.byHash {
+		if lowest == nil || compareFeeRate(entry, lowest) < 0 {
+			lowest = entry
+		}
+	}
+	return lowest
+}
+
+// compareFeeRate ranks two entries by fee per gas, returning a positive
+// number if a ranks first. Equal rates are ranked by the lower transaction
+// hash. The fees are cross-multiplied by the gas so no rate is rounded.
+func compareFeeRate(a, b *mempoolEntry) int {
+	left := new(big.Int).Mul(big.NewInt(int64(a.tx.Fee)), big.NewInt(b.gas))
+	right := new(big.Int).Mul(big.NewInt(int64(b.tx.Fee)), big.NewInt(a.gas))
+	if order := left.Cmp(right); order != 0 {
+		return order
+	}
+	switch {
+	case a.tx.Hash < b.tx.Hash:
+		return 1
+	case a.tx.Hash > b.tx.Hash:
+		return -1
+	}
+	return 0
+}
+
+// evictFrom removes an entry and every later nonce from the same sender,
+// since those can no longer execute.
+func (mp *Mempool) evictFrom(victim *mempoolEntry) {
+	for nonce, entry := range mp.bySender[victim.tx.Sender] {
+		if nonce >= victim.tx.Nonce {
+			mp.remove(entry)
+		}
+	}
+}
+
+// remove deletes an entry from both indexes.
+func (mp *Mempool) remove(entry *mempoolEntry) {
+	delete(mp.byHash, entry.tx.Hash)
+	senderEntries := mp.bySender[entry.tx.Sender]
+	delete(senderEntries, entry.tx.Nonce)
+	if len(senderEntries) == 0 {
+		delete(mp.bySender, entry.tx.Sender)
+	}
+}
+
+// scratchBlock executes transactions on top of the chain tip without
+// applying them, so that a transaction that would make the next block
+// invalid is found before the block is built.
+type scratchBlock struct {
+	chain     *Blockchain
+	parent    string          // Hash of the tip the execution builds on
+	timestamp int64           // Timestamp the block is executed at
+	execution *blockExecution // Effect of the accepted transactions
+	accepted  []*Transaction  // Transactions that executed, in order
+}
+
+// newScratchBlock starts a scratch execution of the block after the tip.
+func (bc *Blockchain) newScratchBlock() *scratchBlock {
+	return &scratchBlock{chain: bc, timestamp: time.Now().Unix()}
+}
+
+// try executes a transaction after the accepted ones and keeps it if it
+// succeeds.
+func (s *scratchBlock) try(tx *Transaction) error {
+	s.chain.mutex.Lock()
+	defer s.chain.mutex.Unlock()
+
+	if tip := s.chain.Chain[len(s.chain.Chain)-1]; s.execution == nil || s.parent != tip.Hash {
+		s.restart()
+	}
+	if err := s.chain.executeTransaction(tx, s.execution); err != nil {
+		// The rejected transaction may have left part of its effect behind
+		s.restart()
+		return err
+	}
+	s.accepted = append(s.accepted, tx)
+	return nil
+}
+
+// restart executes the accepted transactions again on a fresh execution on
+// top of the current tip, dropping any that no longer execute there.
+func (s *scratchBlock) restart() {
+	tip := s.chain.Chain[len(s.chain.Chain)-1]
+	s.parent = tip.Hash
+	for {
+		s.execution = newBlockExecution(tip.Index+1, NextBaseFee(tip), s.chain.Coinbase)
+		s.execution.timestamp = s.timestamp
+		failed := -1
+		for i, tx := range s.accepted {
+			if s.chain.executeTransaction(tx, s.execution) != nil {
+				failed = i
+				break
+			}
+		}
+		if failed < 0 {
+			return
+		}
+		s.accepted = append(s.accepted[:failed:failed], s.accepted[failed+1:]...)
+	}
+}
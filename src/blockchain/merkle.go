@@ -0,0 +1,175 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Domain separation prefixes, so that a leaf can never be reinterpreted as an
+// inner node of the tree or vice versa.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleProofStep is one sibling hash on the path from a leaf to the root.
+type MerkleProofStep struct {
+	Hash string `json:"hash"` // Sibling hash
+	Left bool   `json:"left"` // Whether the sibling is the left child
+}
+
+// MerkleProof proves that a transaction is included in a block. The steps lead
+// from the transaction's leaf through its mini-block and sub-block roots to the
+// Merkle root in the block header.
+type MerkleProof struct {
+	TxHash     string            `json:"txHash"`     // Hash of the proven transaction
+	BlockHash  string            `json:"blockHash"`  // Hash of the containing block
+	Height     int               `json:"height"`     // Height of the containing block
+	MerkleRoot string            `json:"merkleRoot"` // Merkle root of the block header
+	Steps      []MerkleProofStep `json:"steps"`      // Sibling hashes from leaf to root
+}
+
+// TransactionLeafHash returns the Merkle leaf committing to every field of a
+// transaction, including its signature.
+func TransactionLeafHash(tx *Transaction) string {
+	data := append([]byte{merkleLeafPrefix}, tx.CalculateHash()+tx.PublicKey+tx.Signature...)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// hashMerkleNode combines two child hashes into their parent.
+func hashMerkleNode(left, right string) string {
+	data := append([]byte{merkleNodePrefix}, left+right...)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// calculateMerkleRoot calculates the Merkle root for a list of transactions.
+func calculateMerkleRoot(transactions []Transaction) string {
+	var hashes []string
+	for i := range transactions {
+		hashes = append(hashes, TransactionLeafHash(&transactions[i]))
+	}
+	return merkleRootOfHashes(hashes)
+}
+
+// merkleRootOfHashes folds a list of hashes into a single Merkle root.
+// An odd hash at the end of a level is promoted unchanged to the next level.
+func merkleRootOfHashes(hashes []string) string {
+	if len(hashes) == 0 {
+		return ""
+	}
+	for len(hashes) > 1 {
+		hashes = nextMerkleLevel(hashes)
+	}
+	return hashes[0]
+}
+
+// nextMerkleLevel pairs up the hashes of one level into their parents.
+func nextMerkleLevel(hashes []string) []string {
+	var newLevel []string
+	for i := 0; i < len(hashes); i += 2 {
+		if i+1 < len(hashes) {
+			newLevel = append(newLevel, hashMerkleNode(hashes[i], hashes[i+1]))
+		} else {
+			newLevel = append(newLevel, hashes[i])
+		}
+	}
+	return newLevel
+}
+
+// merkleProofOfHashes returns the sibling path for the hash at index, following
+// the same folding as merkleRootOfHashes. Promoted hashes add no step.
+func merkleProofOfHashes(hashes []string, index int) []MerkleProofStep {
+	var steps []MerkleProofStep
+	for len(hashes) > 1 {
+		sibling := index ^ 1
+		if sibling < len(hashes) {
+			steps = append(steps, MerkleProofStep{Hash: hashes[sibling], Left: sibling < index})
+		}
+		hashes = nextMerkleLevel(hashes)
+		index /= 2
+	}
+	return steps
+}
+
+// VerifyMerkleProof checks that a transaction is included under a Merkle root
+// taken from a trusted block header.
+func VerifyMerkleProof(tx *Transaction, proof *MerkleProof, root string) bool {
+	hash := TransactionLeafHash(tx)
+	for _, step := range proof.Steps {
+		if step.Left {
+			hash = hashMerkleNode(step.Hash, hash)
+		} else {
+			hash = hashMerkleNode(hash, step.Hash)
+		}
+	}
+	return hash == root
+}
+
+// ProveTransaction returns the sibling path from a transaction to the
+// mini-block's Merkle root.
+func (mb *MiniBlock) ProveTransaction(txHash string) ([]MerkleProofStep, error) {
+	var leaves []string
+	position := -1
+	for i := range mb.Transactions {
+		leaves = append(leaves, TransactionLeafHash(&mb.Transactions[i]))
+		if mb.Transactions[i].Hash == txHash {
+			position = i
+		}
+	}
+	if position < 0 {
+		return nil, fmt.Errorf("transaction %s is not in mini-block %s", txHash, mb.Key)
+	}
+	return merkleProofOfHashes(leaves, position), nil
+}
+
+// ProveTransaction returns a proof that a transaction is included in the block.
+func (b *Block) ProveTransaction(txHash string) (*MerkleProof, error) {
+	for s := range b.SubBlocks {
+		sub := &b.SubBlocks[s]
+		for m := range sub.MiniBlocks {
+			steps, err := sub.MiniBlocks[m].ProveTransaction(txHash)
+			if err != nil {
+				continue
+			}
+
+			var miniRoots, subRoots []string
+			for i := range sub.MiniBlocks {
+				miniRoots = append(miniRoots, calculateMerkleRoot(sub.MiniBlocks[i].Transactions))
+			}
+			for i := range b.SubBlocks {
+				subRoots = append(subRoots, b.SubBlocks[i].CalculateMerkleRoot())
+			}
+			steps = append(steps, merkleProofOfHashes(miniRoots, m)...)
+			steps = append(steps, merkleProofOfHashes(subRoots, s)...)
+
+			return &MerkleProof{
+				TxHash:     txHash,
+				BlockHash:  b.Hash,
+				Height:     b.Index,
+				MerkleRoot: b.MerkleRoot,
+				Steps:      steps,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("transaction %s is not in block %d", txHash, b.Index)
+}
+
+// GetTransactionProof returns a proof that a canonical transaction is included
+// in its block.
+func (bc *Blockchain) GetTransactionProof(txHash string) (*MerkleProof, error) {
+	location, err := bc.Store.GetTxLocation(txHash)
+	if err != nil {
+		return nil, err
+	}
+	block, err := bc.Store.GetBlockByHash(location.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	if block.HeaderOnly() {
+		return nil, fmt.Errorf("%w: body of block %d holding the transaction", ErrPruned, block.Index)
+	}
+	return block.ProveTransaction(txHash)
+}
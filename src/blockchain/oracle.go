@@ -3,19 +3,22 @@ package blockchain
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 )
 
 // OracleSystem fetches and validates external blockchain data.
 type OracleSystem struct {
-	Data  map[string]string // Mapping of transaction IDs to validation results
-	mutex sync.Mutex        // Mutex for thread safety
+	Data       map[string]string // Mapping of transaction IDs to validation results
+	Population map[int]int64     // Reported world population by year
+	mutex      sync.Mutex        // Mutex for thread safety
 }
 
 // NewOracleSystem initializes a new Oracle system.
 func NewOracleSystem() *OracleSystem {
 	return &OracleSystem{
-		Data: make(map[string]string),
+		Data:       make(map[string]string),
+		Population: make(map[int]int64),
 	}
 }
 
@@ -49,3 +52,29 @@ func (oracle *OracleSystem) ValidateTransaction(txID string) (bool, error) {
 	}
 	return false, errors.New("transaction validation failed")
 }
+
+// ReportPopulation records the world population of a year in the feed.
+func (oracle *OracleSystem) ReportPopulation(year int, population int64) error {
+	oracle.mutex.Lock()
+	defer oracle.mutex.Unlock()
+
+	if population <= 0 {
+		return errors.New("population must be positive")
+	}
+	oracle.Population[year] = population
+	return nil
+}
+
+// PopulationSeries returns the reported world population by year, oldest
+// first, for use as the population series of an EmissionConfig.
+func (oracle *OracleSystem) PopulationSeries() []PopulationRecord {
+	oracle.mutex.Lock()
+	defer oracle.mutex.Unlock()
+
+	records := make([]PopulationRecord, 0, len(oracle.Population))
+	for year, population := range oracle.Population {
+		records = append(records, PopulationRecord{Year: year, Population: population})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Year < records[j].Year })
+	return records
+}
@@ -0,0 +1,107 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultOwnershipCap is the largest fraction of the circulating supply an
+// address may be credited up to.
+const DefaultOwnershipCap = 0.01
+
+// ErrOwnershipCap is returned when a credit would take an address above the
+// ownership cap.
+var ErrOwnershipCap = errors.New("credit would exceed the ownership cap")
+
+// CirculatingSupply returns the total supply less the coins locked by vesting
+// schedules.
+func (t *Tokenomics) CirculatingSupply() Amount {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+	return t.circulatingSupply()
+}
+
+// circulatingSupply returns CirculatingSupply with the tokenomics locked.
+func (t *Tokenomics) circulatingSupply() Amount {
+	circulating := t.TotalSupply
+	for address := range t.Vesting {
+		circulating -= t.locked(address)
+	}
+	if circulating < 0 {
+		return 0
+	}
+	return circulating
+}
+
+// CapExemptions returns the addresses exempt from the ownership cap, sorted.
+func (t *Tokenomics) CapExemptions() []string {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+
+	exemptions := make([]string, 0, len(t.CapExempt))
+	for address := range t.CapExempt {
+		exemptions = append(exemptions, address)
+	}
+	sort.Strings(exemptions)
+	return exemptions
+}
+
+// isCapExempt reports whether an address is exempt from the ownership cap.
+func (t *Tokenomics) isCapExempt(address string) bool {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+	return t.CapExempt[address]
+}
+
+// ownershipRoom returns how much more an address holding a balance may be
+// credited, with the tokenomics locked.
+func (t *Tokenomics) ownershipRoom(address string, balance Amount) Amount {
+	return t.capRoom(t.CapExempt[address], balance)
+}
+
+// capRoom returns how much more a balance may grow under the ownership cap,
+// with the tokenomics locked.
+func (t *Tokenomics) capRoom(exempt bool, balance Amount) Amount {
+	if t.OwnershipCap <= 0 || exempt {
+		return t.MaxSupply
+	}
+	limit, err := t.circulatingSupply().MulRate(t.OwnershipCap)
+	if err != nil || balance >= limit {
+		return 0
+	}
+	return limit - balance
+}
+
+// checkOwnership verifies that crediting an amount to an address holding a
+// balance keeps it within the ownership cap, with the tokenomics locked.
+func (t *Tokenomics) checkOwnership(address string, balance, amount Amount) error {
+	return t.checkCapRoom(address, t.ownershipRoom(address, balance), amount)
+}
+
+// checkCapRoom verifies that a credit fits in the room an address has under
+// the ownership cap.
+func (t *Tokenomics) checkCapRoom(address string, room, amount Amount) error {
+	if amount > room {
+		return fmt.Errorf("%w: %s may hold at most %.2f%% of the circulating supply", ErrOwnershipCap, address, t.OwnershipCap*100)
+	}
+	return nil
+}
+
+// checkOwnership verifies that a credit executed by a block keeps its
+// receiver within the ownership cap, under the exemptions in force at that
+// point of the block.
+func (bc *Blockchain) checkOwnership(execution *blockExecution, address string, amount Amount) error {
+	return bc.Tokenomics.checkCapRoom(address, bc.ownershipRoom(execution, address), amount)
+}
+
+// ownershipRoom returns how much more a block may credit an address, given
+// its working balance.
+func (bc *Blockchain) ownershipRoom(execution *blockExecution, address string) Amount {
+	exempt := bc.capExempt(execution, address)
+	bc.loadBalances(execution, address)
+
+	bc.Tokenomics.TransactionMutex.Lock()
+	defer bc.Tokenomics.TransactionMutex.Unlock()
+	return bc.Tokenomics.capRoom(exempt, execution.balances[address])
+}
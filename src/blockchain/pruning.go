@@ -0,0 +1,62 @@
+package blockchain
+
+import "fmt"
+
+// Prune discards the undo data of blocks up to stateHeight and the bodies of
+// blocks up to bodyHeight. Both are capped below the finalized height, since a
+// reorganisation needs the undo data and bodies of the blocks it reverts, and
+// bodies are capped below the SnapshotBodyWindow blocks up to the latest
+// snapshot so that the snapshot can still be served to new nodes.
+func (bc *Blockchain) Prune(stateHeight, bodyHeight int) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if finalized := len(bc.Chain) - 1 - MaxReorgDepth; stateHeight > finalized {
+		stateHeight = finalized
+	}
+	if len(bc.snapshots) > 0 {
+		if limit := bc.snapshots[len(bc.snapshots)-1].Height - SnapshotBodyWindow; bodyHeight > limit {
+			bodyHeight = limit
+		}
+	}
+	if bodyHeight > stateHeight {
+		bodyHeight = stateHeight
+	}
+	if stateHeight < 1 {
+		return nil
+	}
+	if err := bc.Store.Prune(stateHeight, bodyHeight); err != nil {
+		return fmt.Errorf("error pruning up to block %d: %v", stateHeight, err)
+	}
+
+	// Swap in the header-only blocks so the pruned bodies can be freed. Blocks
+	// still shared with the store were pruned by an earlier call.
+	for height := bodyHeight; height > 0; height-- {
+		block, err := bc.Store.GetBlockByHeight(height)
+		if err != nil {
+			return err
+		}
+		if block == bc.Chain[height] {
+			break
+		}
+		bc.Chain[height] = block
+		bc.blocks[block.Hash].block = block
+	}
+	return nil
+}
+
+// GetBlock returns the canonical block at a height, or ErrPruned if only its
+// header is kept.
+func (bc *Blockchain) GetBlock(height int) (*Block, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if height < 0 || height >= len(bc.Chain) {
+		return nil, ErrNotFound
+	}
+	block := bc.Chain[height]
+	if block.HeaderOnly() {
+		return nil, fmt.Errorf("%w: only the header of block %d is kept", ErrPruned, height)
+	}
+	return block, nil
+}
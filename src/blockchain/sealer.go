@@ -0,0 +1,255 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTransactionTooLarge is returned when a transaction cannot fit in a mini-block.
+var ErrTransactionTooLarge = errors.New("transaction exceeds the mini-block size limit")
+
+// blockAssembler packs transactions into mined mini-blocks and sub-blocks,
+// sealing a mini-block when the next transaction would overflow it and a
+// sub-block when it holds MaxMiniBlocks or would exceed MaxSubBlockSize.
+type blockAssembler struct {
+	previousHash string      // Hash of the parent block the mini-blocks are mined on
+	difficulty   int         // Difficulty the mini-blocks are mined at
+	open         MiniBlock   // Mini-block currently being filled
+	miniBlocks   []MiniBlock // Sealed mini-blocks of the open sub-block
+	subBlocks    []SubBlock  // Sealed sub-blocks
+	subSize      int         // Size of the sealed mini-blocks of the open sub-block
+	size         int         // Size of every transaction added so far
+	count        int         // Number of transactions added so far
+}
+
+// newBlockAssembler starts assembling a block body on a parent block at the
+// given difficulty.
+func newBlockAssembler(previousHash string, difficulty int) *blockAssembler {
+	return &blockAssembler{previousHash: previousHash, difficulty: difficulty}
+}
+
+// fits reports whether a transaction fits in the block without exceeding
+// MaxBlockSize or MaxTransactionsPerBlock.
+func (a *blockAssembler) fits(tx Transaction) bool {
+	size := calculateTransactionsSize([]Transaction{tx})
+	return a.count < MaxTransactionsPerBlock && a.size+size <= MaxBlockSize
+}
+
+// add places a transaction in the open mini-block, sealing the mini-block
+// and sub-block first if the transaction would overflow them.
+func (a *blockAssembler) add(tx Transaction) error {
+	size := calculateTransactionsSize([]Transaction{tx})
+	if size > MaxMiniBlockSize {
+		return ErrTransactionTooLarge
+	}
+
+	if a.open.CurrentSize+size > MaxMiniBlockSize {
+		a.sealMiniBlock(true)
+	}
+	if a.subSize+a.open.CurrentSize+size > MaxSubBlockSize {
+		a.sealMiniBlock(false)
+		a.sealSubBlock()
+	}
+	a.open.Transactions = append(a.open.Transactions, tx)
+	a.open.CurrentSize += size
+	a.size += size
+	a.count++
+	return nil
+}
+
+// sealMiniBlock mines the open mini-block and adds it to the open sub-block,
+// sealing the sub-block once it holds MaxMiniBlocks.
+func (a *blockAssembler) sealMiniBlock(full bool) {
+	if len(a.open.Transactions) == 0 {
+		return
+	}
+	mini := a.open
+	mini.Index = len(a.miniBlocks)
+	mini.IsFull = full
+	mini.MerkleRoot = calculateMerkleRoot(mini.Transactions)
+	mineMiniBlock(&mini, a.previousHash, a.difficulty)
+
+	a.miniBlocks = append(a.miniBlocks, mini)
+	a.subSize += mini.CurrentSize
+	a.open = MiniBlock{}
+	if len(a.miniBlocks) == MaxMiniBlocks {
+		a.sealSubBlock()
+	}
+}
+
+// sealSubBlock rolls the sealed mini-blocks into a sub-block.
+func (a *blockAssembler) sealSubBlock() {
+	if len(a.miniBlocks) == 0 {
+		return
+	}
+	a.subBlocks = append(a.subBlocks, SubBlock{Index: len(a.subBlocks), MiniBlocks: a.miniBlocks})
+	a.miniBlocks = nil
+	a.subSize = 0
+}
+
+// finish seals everything still open and returns the sub-blocks.
+func (a *blockAssembler) finish() []SubBlock {
+	a.sealMiniBlock(false)
+	a.sealSubBlock()
+	return a.subBlocks
+}
+
+// SealerConfig holds the timeouts applied by a Sealer.
+type SealerConfig struct {
+	MiniBlockTimeout time.Duration // An open mini-block is sealed once its first transaction is this old
+	BlockTimeout     time.Duration // A pending block is sealed once its first transaction is this old
+}
+
+// DefaultSealerConfig returns the timeouts used unless configured otherwise.
+func DefaultSealerConfig() SealerConfig {
+	return SealerConfig{
+		MiniBlockTimeout: 100 * time.Millisecond,
+		BlockTimeout:     time.Second,
+	}
+}
+
+// Sealer produces blocks from a stream of transactions. Transactions fill a
+// mini-block that is mined and sealed when full or when MiniBlockTimeout
+// passes; sealed mini-blocks roll into sub-blocks, and the sub-blocks are
+// sealed into a main block and appended to the chain when the block is full
+// or BlockTimeout passes. Each transaction is executed after the pending ones
+// when it is added and refused if it fails, so that one bad transaction does
+// not get the whole sealed block rejected.
+type Sealer struct {
+	Config    SealerConfig
+	OnError   func(error) // Receives the errors of the blocks Run fails to seal, if set
+	chain     *Blockchain
+	assembler *blockAssembler
+	scratch   *scratchBlock // Execution of the pending block's transactions
+	openedAt  time.Time     // When the open mini-block received its first transaction
+	startedAt time.Time     // When the pending block received its first transaction
+	mutex     sync.Mutex
+}
+
+// NewSealer creates a sealer that appends the blocks it seals to a blockchain.
+func NewSealer(chain *Blockchain, config SealerConfig) *Sealer {
+	return &Sealer{
+		Config:    config,
+		chain:     chain,
+		assembler: newBlockAssembler(chain.miningTarget()),
+		scratch:   chain.newScratchBlock(),
+	}
+}
+
+// Add places a transaction in the open mini-block. If the transaction does not
+// fit in the pending block, that block is sealed and appended first and
+// returned. A transaction that does not execute after the pending ones is
+// refused.
+func (s *Sealer) Add(tx Transaction) (*Block, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var sealed *Block
+	if !s.assembler.fits(tx) {
+		block, err := s.seal()
+		if err != nil {
+			return nil, err
+		}
+		sealed = block
+	}
+
+	if calculateTransactionsSize([]Transaction{tx}) > MaxMiniBlockSize {
+		return sealed, ErrTransactionTooLarge
+	}
+	if err := s.scratch.try(&tx); err != nil {
+		return sealed, err
+	}
+	now := time.Now()
+	if err := s.assembler.add(tx); err != nil {
+		return sealed, err
+	}
+	if s.assembler.count == 1 {
+		s.startedAt = now
+	}
+	if len(s.assembler.open.Transactions) == 1 {
+		s.openedAt = now
+	}
+	return sealed, nil
+}
+
+// Tick seals the open mini-block and the pending block if their timeouts have
+// passed, returning the block appended to the chain, if any.
+func (s *Sealer) Tick(now time.Time) (*Block, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.assembler.count == 0 {
+		return nil, nil
+	}
+	if now.Sub(s.startedAt) >= s.Config.BlockTimeout {
+		return s.seal()
+	}
+	if len(s.assembler.open.Transactions) > 0 && now.Sub(s.openedAt) >= s.Config.MiniBlockTimeout {
+		s.assembler.sealMiniBlock(false)
+	}
+	return nil, nil
+}
+
+// Seal seals every pending transaction into a main block and appends it to
+// the chain. It returns nil if nothing is pending.
+func (s *Sealer) Seal() (*Block, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.assembler.count == 0 {
+		return nil, nil
+	}
+	return s.seal()
+}
+
+// Run calls Tick at the mini-block timeout until stop is closed. Errors are
+// passed to OnError, if set.
+func (s *Sealer) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.Config.MiniBlockTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if _, err := s.Tick(now); err != nil && s.OnError != nil {
+				s.OnError(fmt.Errorf("error sealing block: %w", err))
+			}
+		}
+	}
+}
+
+// seal appends the pending block to the chain and starts a new one. The
+// pending transactions are dropped even if the block is rejected.
+func (s *Sealer) seal() (*Block, error) {
+	subBlocks := s.assembler.finish()
+	block, err := s.chain.appendSealedBlock(subBlocks, s.assembler.previousHash, s.assembler.difficulty)
+	s.assembler = newBlockAssembler(s.chain.miningTarget())
+	s.scratch = s.chain.newScratchBlock()
+	return block, err
+}
+
+// miningTarget returns the parent hash and difficulty the mini-blocks of the
+// next block are mined at.
+func (bc *Blockchain) miningTarget() (string, int) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.Chain[len(bc.Chain)-1].Hash, nextDifficulty(bc.tipNode())
+}
+
+// appendSealedBlock wraps sealed sub-blocks in a header on top of the tip and
+// applies the block. Mini-blocks mined on a block that is no longer the tip or
+// at a difficulty that is no longer the expected one are mined again.
+func (bc *Blockchain) appendSealedBlock(subBlocks []SubBlock, previousHash string, difficulty int) (*Block, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	block := bc.childBlockWithBody(subBlocks, previousHash, difficulty)
+	if err := bc.applyNewBlock(block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
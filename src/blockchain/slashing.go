@@ -0,0 +1,288 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrInvalidEvidence is returned for evidence that does not prove punishable
+// misbehaviour.
+var ErrInvalidEvidence = errors.New("invalid evidence")
+
+// MaxEvidencePerBlock bounds the evidence a block may carry.
+const MaxEvidencePerBlock = 100
+
+// EvidenceType is the kind of misbehaviour a piece of evidence proves.
+type EvidenceType int
+
+// Kinds of misbehaviour validators are slashed for.
+const (
+	DuplicateVote EvidenceType = iota + 1 // Two conflicting votes in the same round
+	Downtime                              // Precommits missing from too many commit certificates
+)
+
+// String returns the name of the evidence type.
+func (t EvidenceType) String() string {
+	switch t {
+	case DuplicateVote:
+		return "duplicate vote"
+	case Downtime:
+		return "downtime"
+	}
+	return "unknown"
+}
+
+// Evidence proves that a validator misbehaved. Evidence of a duplicate vote
+// carries both votes, ordered by block hash. Downtime evidence names the last
+// height of a window of DowntimeWindow commit certificates, which every node
+// can recount from the certificates recorded in its chain.
+type Evidence struct {
+	Type      EvidenceType   // Kind of misbehaviour
+	Validator string         // Address of the misbehaving validator
+	Height    int            // Height of the conflicting votes, or last height of the missed window
+	VoteA     *ConsensusVote // Conflicting vote for the lower block hash, for a duplicate vote
+	VoteB     *ConsensusVote // Conflicting vote for the higher block hash, for a duplicate vote
+}
+
+// NewDuplicateVoteEvidence builds the evidence of two votes signed by the
+// same validator in the same round for different blocks.
+func NewDuplicateVoteEvidence(a, b *ConsensusVote) *Evidence {
+	if b.BlockHash < a.BlockHash {
+		a, b = b, a
+	}
+	return &Evidence{Type: DuplicateVote, Validator: a.Validator, Height: a.Height, VoteA: a, VoteB: b}
+}
+
+// Hash returns the hash identifying the evidence.
+func (e *Evidence) Hash() string {
+	record := fmt.Sprintf("%d:%s:%d", e.Type, e.Validator, e.Height)
+	for _, vote := range []*ConsensusVote{e.VoteA, e.VoteB} {
+		if vote != nil {
+			record += fmt.Sprintf(":%d/%d/%d/%s/%s", vote.Type, vote.Height, vote.Round, vote.BlockHash, vote.Signature)
+		}
+	}
+	hash := sha256.Sum256([]byte(record))
+	return hex.EncodeToString(hash[:])
+}
+
+// CalculateEvidenceRoot computes the Merkle root over the hashes of the
+// block's evidence, which is empty for a block without evidence.
+func (b *Block) CalculateEvidenceRoot() string {
+	var hashes []string
+	for i := range b.Evidence {
+		hashes = append(hashes, b.Evidence[i].Hash())
+	}
+	return merkleRootOfHashes(hashes)
+}
+
+// verifyEvidence checks evidence to be included in the block at a height. It
+// returns the first height the misbehaviour covers and the fraction of stake
+// it is punished with.
+func (bc *Blockchain) verifyEvidence(evidence *Evidence, height int) (int, float64, error) {
+	if evidence.Height >= height || height-evidence.Height > bc.Staking.UnbondingPeriod {
+		return 0, 0, fmt.Errorf("%w: misbehaviour at height %d cannot be punished at height %d", ErrInvalidEvidence, evidence.Height, height)
+	}
+
+	switch evidence.Type {
+	case DuplicateVote:
+		a, b := evidence.VoteA, evidence.VoteB
+		if a == nil || b == nil {
+			return 0, 0, fmt.Errorf("%w: duplicate vote evidence needs two votes", ErrInvalidEvidence)
+		}
+		if a.Validator != evidence.Validator || b.Validator != evidence.Validator || a.Height != evidence.Height ||
+			b.Height != evidence.Height || a.Round != b.Round || a.Type != b.Type || a.BlockHash >= b.BlockHash {
+			return 0, 0, fmt.Errorf("%w: votes are not conflicting votes of %s in one round", ErrInvalidEvidence, evidence.Validator)
+		}
+		validators := bc.ValidatorsAt(evidence.Height)
+		if validators == nil {
+			return 0, 0, fmt.Errorf("%w: no validator set at height %d", ErrInvalidEvidence, evidence.Height)
+		}
+		for _, vote := range []*ConsensusVote{a, b} {
+			if err := vote.Verify(bc.ChainID, validators); err != nil {
+				return 0, 0, fmt.Errorf("%w: %v", ErrInvalidEvidence, err)
+			}
+		}
+		return evidence.Height, bc.Staking.DoubleSignSlash, nil
+	case Downtime:
+		window := bc.Staking.DowntimeWindow
+		if window <= 0 || evidence.Height < window || evidence.VoteA != nil || evidence.VoteB != nil {
+			return 0, 0, fmt.Errorf("%w: malformed downtime evidence", ErrInvalidEvidence)
+		}
+		if evidence.Height >= height-1 {
+			return 0, 0, fmt.Errorf("%w: the certificate of height %d is not in the chain yet", ErrInvalidEvidence, evidence.Height)
+		}
+		start := evidence.Height - window + 1
+		if missed := bc.missedPrecommits(start, evidence.Height)[evidence.Validator]; missed <= bc.Staking.MaxMissedBlocks {
+			return 0, 0, fmt.Errorf("%w: %s missed %d of the precommits up to height %d, limit is %d",
+				ErrInvalidEvidence, evidence.Validator, missed, evidence.Height, bc.Staking.MaxMissedBlocks)
+		}
+		return start, bc.Staking.DowntimeSlash, nil
+	}
+	return 0, 0, fmt.Errorf("%w: unknown evidence type %d", ErrInvalidEvidence, evidence.Type)
+}
+
+// missedPrecommits counts, for every validator, the commit certificates of
+// the heights from..to that lack its precommit. The certificate of a height
+// is the one recorded by the next block; heights whose certificate was not
+// recorded are not counted.
+func (bc *Blockchain) missedPrecommits(from, to int) map[string]int {
+	missed := make(map[string]int)
+	for height := from; height <= to && height+1 < len(bc.Chain); height++ {
+		commit := bc.Chain[height+1].LastCommit
+		validators := bc.ValidatorsAt(height)
+		if commit == nil || validators == nil {
+			continue
+		}
+		signed := make(map[string]bool)
+		for _, vote := range commit.Precommits {
+			signed[vote.Validator] = true
+		}
+		for _, validator := range validators.Validators {
+			if !signed[validator.Address] {
+				missed[validator.Address]++
+			}
+		}
+	}
+	return missed
+}
+
+// executeEvidence slashes and jails the validator that evidence proves
+// misbehaved. The slashed share of its bonded and unbonding stake is burned.
+// Misbehaviour up to the height of the block that last slashed a validator
+// is not punished again.
+func (bc *Blockchain) executeEvidence(evidence *Evidence, execution *blockExecution) error {
+	start, fraction, err := bc.verifyEvidence(evidence, execution.height)
+	if err != nil {
+		return err
+	}
+	stake := bc.loadStake(execution, evidence.Validator)
+	if start <= stake.SlashedAt {
+		return fmt.Errorf("%w: %s was already slashed at height %d", ErrInvalidEvidence, evidence.Validator, stake.SlashedAt)
+	}
+
+	slashedBond, err := stake.Bonded.MulRate(fraction)
+	if err != nil {
+		return err
+	}
+	slashedUnbonding, err := stake.Unbonding.MulRate(fraction)
+	if err != nil {
+		return err
+	}
+	stake.Bonded -= slashedBond
+	stake.Unbonding -= slashedUnbonding
+	if stake.Unbonding == 0 {
+		stake.UnbondingUntil = 0
+	}
+	stake.SlashedAt = execution.height
+	stake.JailedUntil = execution.height + bc.Staking.JailPeriod
+	execution.stakes[evidence.Validator] = stake
+	execution.jailed = append(execution.jailed, evidence.Validator)
+
+	if execution.burned, err = execution.burned.Add(slashedBond + slashedUnbonding); err != nil {
+		return err
+	}
+	return nil
+}
+
+// withoutJailed returns the validators deciding a height less those jailed by
+// a block, or nil if nobody in the set was jailed or nobody would be left.
+func (bc *Blockchain) withoutJailed(height int, jailed []string) []Validator {
+	validators := bc.ValidatorsAt(height)
+	if validators == nil {
+		return nil
+	}
+	excluded := make(map[string]bool)
+	for _, address := range jailed {
+		excluded[address] = true
+	}
+	var remaining []Validator
+	for _, validator := range validators.Validators {
+		if !excluded[validator.Address] {
+			remaining = append(remaining, validator)
+		}
+	}
+	if len(remaining) == len(validators.Validators) || len(remaining) == 0 {
+		return nil
+	}
+	return remaining
+}
+
+// SubmitEvidence verifies evidence of misbehaviour against the canonical tip
+// and holds it until a block includes it. Only one piece of evidence is held
+// per validator, since a slashing covers all earlier misbehaviour.
+func (bc *Blockchain) SubmitEvidence(evidence *Evidence) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if held, ok := bc.evidence[evidence.Validator]; ok && bc.executeEvidence(held, newBlockExecution(len(bc.Chain), 0, "")) == nil {
+		return nil
+	}
+	if err := bc.executeEvidence(evidence, newBlockExecution(len(bc.Chain), 0, "")); err != nil {
+		return err
+	}
+	bc.evidence[evidence.Validator] = evidence
+	return nil
+}
+
+// PendingEvidence returns the evidence waiting to be included in a block.
+func (bc *Blockchain) PendingEvidence() []*Evidence {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	pending := make([]*Evidence, 0, len(bc.evidence))
+	for _, evidence := range bc.evidence {
+		pending = append(pending, evidence)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Validator < pending[j].Validator })
+	return pending
+}
+
+// collectEvidence gathers the evidence for the next block on the tip: held
+// evidence that still applies, followed by the downtime of validators that
+// missed too many of the latest DowntimeWindow commit certificates. Held
+// evidence that no longer applies is dropped.
+func (bc *Blockchain) collectEvidence() []Evidence {
+	height := len(bc.Chain)
+	execution := newBlockExecution(height, 0, "")
+	var collected []Evidence
+	include := func(evidence *Evidence) bool {
+		if len(collected) >= MaxEvidencePerBlock {
+			return false
+		}
+		if err := bc.executeEvidence(evidence, execution); err != nil {
+			return false
+		}
+		collected = append(collected, *evidence)
+		return true
+	}
+
+	var held []string
+	for validator := range bc.evidence {
+		held = append(held, validator)
+	}
+	sort.Strings(held)
+	for _, validator := range held {
+		if !include(bc.evidence[validator]) {
+			delete(bc.evidence, validator)
+		}
+	}
+
+	end := height - 2
+	if bc.Staking.DowntimeWindow <= 0 || end < bc.Staking.DowntimeWindow {
+		return collected
+	}
+	var offline []string
+	for validator, missed := range bc.missedPrecommits(end-bc.Staking.DowntimeWindow+1, end) {
+		if missed > bc.Staking.MaxMissedBlocks {
+			offline = append(offline, validator)
+		}
+	}
+	sort.Strings(offline)
+	for _, validator := range offline {
+		include(&Evidence{Type: Downtime, Validator: validator, Height: end})
+	}
+	return collected
+}
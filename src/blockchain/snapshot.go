@@ -0,0 +1,335 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Snapshot parameters. A node keeps the account state at the latest checkpoint
+// heights so that new nodes can download it instead of replaying every block.
+const (
+	DefaultSnapshotInterval = 1000 // Blocks between checkpoint heights
+	SnapshotChunkSize       = 1000 // Accounts per snapshot chunk
+	maxSnapshots            = 2    // Snapshots kept, newest last
+	// SnapshotBodyWindow is the number of blocks up to a checkpoint served
+	// with their bodies, enough to recompute the difficulty of the last
+	// DifficultyWindow of them from the solve times of the blocks below.
+	SnapshotBodyWindow = 2 * DifficultyWindow
+)
+
+// Snapshot errors.
+var (
+	ErrNoSnapshot        = errors.New("no snapshot available")
+	ErrUntrustedSnapshot = errors.New("snapshot checkpoint is neither trusted nor certified by the validators")
+)
+
+// Snapshot is the complete account state at a checkpoint block.
+type Snapshot struct {
+	Height    int                // Height of the checkpoint block
+	BlockHash string             // Hash of the checkpoint block
+	StateRoot string             // State root in the checkpoint block header
+	Accounts  map[string]Account // Every account with a non-zero state
+}
+
+// SnapshotManifest describes a snapshot so that it can be downloaded in
+// chunks, each checked against its hash as it arrives.
+type SnapshotManifest struct {
+	Height      int      `json:"height"`      // Height of the checkpoint block
+	BlockHash   string   `json:"blockHash"`   // Hash of the checkpoint block
+	StateRoot   string   `json:"stateRoot"`   // State root in the checkpoint block header
+	ChunkHashes []string `json:"chunkHashes"` // Hash of each chunk, in order
+}
+
+// SnapshotChunk is a slice of the accounts of a snapshot, ordered by address.
+type SnapshotChunk struct {
+	Height   int                `json:"height"`   // Height of the snapshot the chunk belongs to
+	Index    int                `json:"index"`    // Position of the chunk in the snapshot
+	Accounts map[string]Account `json:"accounts"` // Accounts in the chunk
+}
+
+// takeSnapshot records the account state at a checkpoint block, dropping the
+// oldest snapshot once more than maxSnapshots are kept.
+func (bc *Blockchain) takeSnapshot(block *Block) error {
+	accounts, err := bc.Store.Accounts()
+	if err != nil {
+		return err
+	}
+	bc.snapshots = append(bc.snapshots, &Snapshot{
+		Height:    block.Index,
+		BlockHash: block.Hash,
+		StateRoot: block.StateRoot,
+		Accounts:  accounts,
+	})
+	if len(bc.snapshots) > maxSnapshots {
+		bc.snapshots = bc.snapshots[1:]
+	}
+	return nil
+}
+
+// dropSnapshotsAbove forgets snapshots of blocks above the given height,
+// which are no longer canonical after a revert.
+func (bc *Blockchain) dropSnapshotsAbove(height int) {
+	for len(bc.snapshots) > 0 && bc.snapshots[len(bc.snapshots)-1].Height > height {
+		bc.snapshots = bc.snapshots[:len(bc.snapshots)-1]
+	}
+}
+
+// LatestSnapshot returns the manifest of the newest snapshot.
+func (bc *Blockchain) LatestSnapshot() (*SnapshotManifest, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if len(bc.snapshots) == 0 {
+		return nil, ErrNoSnapshot
+	}
+	snapshot := bc.snapshots[len(bc.snapshots)-1]
+	manifest := &SnapshotManifest{
+		Height:    snapshot.Height,
+		BlockHash: snapshot.BlockHash,
+		StateRoot: snapshot.StateRoot,
+	}
+	for _, chunk := range snapshot.chunks() {
+		manifest.ChunkHashes = append(manifest.ChunkHashes, hashSnapshotChunk(chunk))
+	}
+	return manifest, nil
+}
+
+// GetSnapshotChunk returns a chunk of the snapshot taken at the given height.
+func (bc *Blockchain) GetSnapshotChunk(height, index int) (*SnapshotChunk, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	for _, snapshot := range bc.snapshots {
+		if snapshot.Height != height {
+			continue
+		}
+		chunks := snapshot.chunks()
+		if index < 0 || index >= len(chunks) {
+			return nil, fmt.Errorf("snapshot at height %d has no chunk %d", height, index)
+		}
+		return chunks[index], nil
+	}
+	return nil, fmt.Errorf("%w at height %d", ErrNoSnapshot, height)
+}
+
+// chunks splits the accounts of a snapshot into chunks of SnapshotChunkSize
+// accounts, ordered by address. An empty snapshot has a single empty chunk.
+func (s *Snapshot) chunks() []*SnapshotChunk {
+	addresses := make([]string, 0, len(s.Accounts))
+	for address := range s.Accounts {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	var chunks []*SnapshotChunk
+	for start := 0; start == 0 || start < len(addresses); start += SnapshotChunkSize {
+		end := start + SnapshotChunkSize
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		chunk := &SnapshotChunk{Height: s.Height, Index: len(chunks), Accounts: make(map[string]Account)}
+		for _, address := range addresses[start:end] {
+			chunk.Accounts[address] = s.Accounts[address]
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// VerifyChunk checks a downloaded chunk against the manifest.
+func (m *SnapshotManifest) VerifyChunk(chunk *SnapshotChunk) error {
+	if chunk.Height != m.Height || chunk.Index < 0 || chunk.Index >= len(m.ChunkHashes) {
+		return fmt.Errorf("chunk %d at height %d is not part of the snapshot at height %d", chunk.Index, chunk.Height, m.Height)
+	}
+	if hashSnapshotChunk(chunk) != m.ChunkHashes[chunk.Index] {
+		return fmt.Errorf("snapshot chunk %d hash mismatch", chunk.Index)
+	}
+	return nil
+}
+
+// hashSnapshotChunk hashes the canonical JSON encoding of a chunk, whose
+// account keys are encoded in sorted order.
+func hashSnapshotChunk(chunk *SnapshotChunk) string {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// WithoutBody returns a copy of the block holding only its header, as served
+// to nodes catching up from a snapshot.
+func (b *Block) WithoutBody() *Block {
+	return &Block{BlockHeader: b.BlockHeader, Hash: b.Hash, Key: b.Key, Commit: b.Commit, LastCommit: b.LastCommit}
+}
+
+// HeaderOnly reports whether a block was received without its body. A block
+// with an empty body has an empty Merkle root and evidence root.
+func (b *Block) HeaderOnly() bool {
+	return len(b.SubBlocks) == 0 && len(b.Evidence) == 0 && (b.MerkleRoot != "" || b.EvidenceRoot != "")
+}
+
+// ImportSnapshot starts a chain that holds only the genesis block from a
+// downloaded snapshot. blocks holds every block after genesis up to the
+// checkpoint; the last SnapshotBodyWindow of them must carry their bodies so
+// that their difficulty and proof of work can be checked and retargeting and
+// base fees can continue from the checkpoint, while older ones may be headers
+// only. The header chain must link back to genesis and end at the checkpoint
+// block, which must be one of the trusted Checkpoints or carry a commit
+// certificate from the validators deciding its height, and the accounts must
+// produce the state root in its header. Blocks after the checkpoint are then
+// caught up with ProcessBlock as usual.
+func (bc *Blockchain) ImportSnapshot(manifest *SnapshotManifest, chunks []*SnapshotChunk, blocks []*Block) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if len(bc.Chain) != 1 {
+		return fmt.Errorf("cannot import a snapshot into a chain at height %d", len(bc.Chain)-1)
+	}
+	if err := bc.checkSnapshotBlocks(blocks, manifest); err != nil {
+		return err
+	}
+
+	if len(chunks) != len(manifest.ChunkHashes) {
+		return fmt.Errorf("snapshot has %d chunks, received %d", len(manifest.ChunkHashes), len(chunks))
+	}
+	update := StateUpdate{Accounts: make(map[string]Account)}
+	trie := NewStateTrie()
+	var err error
+	for i, chunk := range chunks {
+		if chunk.Index != i {
+			return fmt.Errorf("snapshot chunk %d received at position %d", chunk.Index, i)
+		}
+		if err := manifest.VerifyChunk(chunk); err != nil {
+			return err
+		}
+		for address, account := range chunk.Accounts {
+			if _, duplicate := update.Accounts[address]; duplicate {
+				return fmt.Errorf("account %s appears in more than one snapshot chunk", address)
+			}
+			update.Accounts[address] = account
+			trie.Update(address, account)
+			if isRecordAddress(address) {
+				continue
+			}
+			// Every coin in supply is held by some account, spendable or staked
+			for _, held := range []Amount{account.Balance, account.Bonded, account.Unbonding} {
+				if update.TotalSupply, err = update.TotalSupply.Add(held); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if trie.Root() != manifest.StateRoot {
+		return ErrStateRootMismatch
+	}
+	// The accounts do not show how the supply got there, so the issuance
+	// since genesis is taken to be the net change of the supply
+	if update.TotalSupply > InitialSupply {
+		update.Minted = update.TotalSupply - InitialSupply
+	} else {
+		update.Burned = InitialSupply - update.TotalSupply
+	}
+
+	stale, err := bc.Store.Accounts()
+	if err != nil {
+		return err
+	}
+	if err := bc.Store.ImportSnapshot(blocks, update); err != nil {
+		return fmt.Errorf("error storing snapshot: %v", err)
+	}
+
+	accounts := make(map[string]Account, len(stale)+len(update.Accounts))
+	for address := range stale {
+		accounts[address] = Account{}
+	}
+	for address, account := range update.Accounts {
+		accounts[address] = account
+	}
+	bc.setState(accounts, update.TotalSupply)
+	if err := bc.loadBlocks(); err != nil {
+		return err
+	}
+	bc.snapshots = []*Snapshot{{
+		Height:    manifest.Height,
+		BlockHash: manifest.BlockHash,
+		StateRoot: manifest.StateRoot,
+		Accounts:  update.Accounts,
+	}}
+	return nil
+}
+
+// checkSnapshotBlocks verifies that blocks form a header chain from genesis to
+// the checkpoint block of a manifest and that the checkpoint is trusted or
+// certified. A peer serving a snapshot could otherwise make up the whole
+// chain, so every header must also follow difficulty retargeting: the last
+// SnapshotBodyWindow blocks must carry bodies matching their headers, proof
+// of work and solve times, the difficulty of a block whose whole retargeting window
+// has bodies must be the one recomputed from it, and older headers may only
+// move by the one bit a retarget allows.
+func (bc *Blockchain) checkSnapshotBlocks(blocks []*Block, manifest *SnapshotManifest) error {
+	if len(blocks) != manifest.Height {
+		return fmt.Errorf("snapshot at height %d needs %d blocks after genesis, received %d", manifest.Height, manifest.Height, len(blocks))
+	}
+	bodiesFrom := len(blocks) - SnapshotBodyWindow
+	parent := &blockNode{block: bc.Chain[0]}
+	for i, block := range blocks {
+		previous := parent.block
+		if block.Index != previous.Index+1 || block.PreviousHash != previous.Hash {
+			return fmt.Errorf("block %d does not link to block %d", block.Index, previous.Index)
+		}
+		if block.Hash != block.CalculateHash() {
+			return fmt.Errorf("block %d hash mismatch", block.Index)
+		}
+		if i >= bodiesFrom {
+			if block.MerkleRoot != block.CalculateMerkleRoot() {
+				return fmt.Errorf("block %d body does not match its Merkle root", block.Index)
+			}
+			if err := checkProofOfWork(block); err != nil {
+				return err
+			}
+			if err := checkSolveTime(block, previous); err != nil {
+				return err
+			}
+		}
+		if i-DifficultyWindow >= bodiesFrom || bodiesFrom <= 0 {
+			if expected := nextDifficulty(parent); block.Difficulty != expected {
+				return invalidBlock(block, ErrBlockDifficulty, fmt.Errorf("difficulty %d, expected %d", block.Difficulty, expected))
+			}
+		} else if !withinRetarget(block.Difficulty, previous.Difficulty) {
+			return invalidBlock(block, ErrBlockDifficulty, fmt.Errorf("difficulty %d cannot follow the parent's %d", block.Difficulty, previous.Difficulty))
+		}
+		parent = &blockNode{block: block, parent: parent}
+	}
+	checkpoint := parent.block
+	if checkpoint.Hash != manifest.BlockHash || checkpoint.StateRoot != manifest.StateRoot {
+		return errors.New("snapshot does not match the checkpoint block header")
+	}
+	return bc.checkCheckpoint(checkpoint)
+}
+
+// checkCheckpoint verifies that the checkpoint block of a snapshot is one of
+// the trusted Checkpoints or carries a commit certificate from the validators
+// deciding its height. A chain holding only genesis knows no elections, so
+// once validators have been elected from stake, the checkpoint must be
+// trusted.
+func (bc *Blockchain) checkCheckpoint(checkpoint *Block) error {
+	if trusted, ok := bc.Checkpoints[checkpoint.Index]; ok {
+		if trusted != checkpoint.Hash {
+			return fmt.Errorf("%w: block %d is not the trusted checkpoint %s", ErrUntrustedSnapshot, checkpoint.Index, trusted)
+		}
+		return nil
+	}
+	if bc.ValidatorsAt(checkpoint.Index) == nil {
+		return fmt.Errorf("%w: no trusted checkpoint or validator set at height %d", ErrUntrustedSnapshot, checkpoint.Index)
+	}
+	if err := bc.checkCommit(checkpoint); err != nil {
+		return fmt.Errorf("%w: %v", ErrUntrustedSnapshot, err)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Addresses that signed transactions are sent to in order to manage stake.
+// They never hold a balance; bonded stake stays in the sender's account.
+const (
+	BondAddress     = "staking/bond"     // Bonds the amount from the sender's balance
+	UnbondAddress   = "staking/unbond"   // Starts unbonding the amount from the sender's bond
+	WithdrawAddress = "staking/withdraw" // Returns the amount of unbonded stake to the sender's balance
+)
+
+// ErrInsufficientStake is returned when an address unbonds or withdraws more
+// stake than it holds.
+var ErrInsufficientStake = errors.New("insufficient stake")
+
+// StakingConfig holds the parameters of validator bonding, elections and
+// slashing.
+type StakingConfig struct {
+	EpochLength     int     // Blocks per epoch; the validator set only changes between epochs, or when a validator is jailed
+	UnbondingPeriod int     // Blocks unbonded stake waits before it can be withdrawn, and the age limit of evidence
+	MaxValidators   int     // Most validators elected for an epoch, largest bonds first
+	MinBond         Amount  // Smallest bond an address may hold
+	DoubleSignSlash float64 // Fraction of stake slashed for signing conflicting votes
+	DowntimeSlash   float64 // Fraction of stake slashed for missing too many precommits
+	DowntimeWindow  int     // Commit certificates checked for a validator's missed precommits, or 0 to not punish downtime
+	MaxMissedBlocks int     // Most precommits a validator may miss within the window
+	JailPeriod      int     // Blocks a slashed validator is excluded from elections
+}
+
+// DefaultStakingConfig returns the default staking parameters.
+func DefaultStakingConfig() StakingConfig {
+	return StakingConfig{
+		EpochLength:     100,
+		UnbondingPeriod: 1000,
+		MaxValidators:   100,
+		MinBond:         10_000 * OneBMT,
+		DoubleSignSlash: 0.05,
+		DowntimeSlash:   0.01,
+		DowntimeWindow:  100,
+		MaxMissedBlocks: 50,
+		JailPeriod:      1000,
+	}
+}
+
+// StakeInfo is the staking state of an address.
+type StakeInfo struct {
+	Address        string // Staking address
+	PublicKey      string // Public key registered with the bond
+	Bonded         Amount // Stake currently bonded
+	Unbonding      Amount // Stake waiting out the unbonding period
+	UnbondingUntil int    // Height from which the unbonding stake can be withdrawn
+	JailedUntil    int    // Height from which the address can be elected again after being slashed
+	SlashedAt      int    // Height of the block that last slashed the address
+	Active         bool   // Whether the address validates the next block
+}
+
+// isStakingAddress reports whether an address is one of the staking addresses.
+func isStakingAddress(address string) bool {
+	return address == BondAddress || address == UnbondAddress || address == WithdrawAddress
+}
+
+// Spend returns the amount a transaction takes from its payer's balance:
+// the fee, plus the amount unless the transaction unbonds or withdraws stake,
+// releases vested coins, is in a registered token, changes an allowance or
+// transfers another owner's coins. Payouts spend their amount and fee.
+func (t *Transaction) Spend() (Amount, error) {
+	if t.Receiver == PayoutAddress {
+		return t.Amount.Add(t.Fee)
+	}
+	if t.Token != "" || t.Target != "" || isAllowanceAddress(t.Receiver) || t.Receiver == UnbondAddress || t.Receiver == WithdrawAddress || t.Receiver == ReleaseAddress {
+		return t.Fee, nil
+	}
+	return t.Amount.Add(t.Fee)
+}
+
+// loadStake copies the staking state of an address not yet touched by the
+// execution into its working stakes.
+func (bc *Blockchain) loadStake(execution *blockExecution, address string) Account {
+	if _, ok := execution.stakes[address]; !ok {
+		execution.stakes[address] = bc.stakes[address]
+	}
+	return execution.stakes[address]
+}
+
+// executeStaking records the effect of a transaction sent to a staking
+// address on the sender's stake. A bond registers the sender's public key
+// for signing consensus votes. Unbonded stake can be withdrawn once the
+// unbonding period of the latest unbonding has passed.
+func (bc *Blockchain) executeStaking(tx *Transaction, execution *blockExecution) error {
+	stake := bc.loadStake(execution, tx.Sender)
+	switch tx.Receiver {
+	case BondAddress:
+		bonded, err := stake.Bonded.Add(tx.Amount)
+		if err != nil {
+			return err
+		}
+		stake.Bonded, stake.PublicKey = bonded, tx.PublicKey
+	case UnbondAddress:
+		bonded, err := stake.Bonded.Sub(tx.Amount)
+		if err != nil {
+			return fmt.Errorf("%w: %s bonded", ErrInsufficientStake, stake.Bonded)
+		}
+		unbonding, err := stake.Unbonding.Add(tx.Amount)
+		if err != nil {
+			return err
+		}
+		stake.Bonded, stake.Unbonding = bonded, unbonding
+		stake.UnbondingUntil = execution.height + bc.Staking.UnbondingPeriod
+	case WithdrawAddress:
+		if execution.height < stake.UnbondingUntil {
+			return fmt.Errorf("unbonding stake is locked until height %d", stake.UnbondingUntil)
+		}
+		unbonding, err := stake.Unbonding.Sub(tx.Amount)
+		if err != nil {
+			return fmt.Errorf("%w: %s unbonding", ErrInsufficientStake, stake.Unbonding)
+		}
+		if err := bc.checkOwnership(execution, tx.Sender, tx.Amount); err != nil {
+			return err
+		}
+		stake.Unbonding = unbonding
+		execution.balances[tx.Sender] += tx.Amount
+	}
+	if stake.Bonded > 0 && stake.Bonded < bc.Staking.MinBond {
+		return fmt.Errorf("bond of %s is below the minimum of %s", stake.Bonded, bc.Staking.MinBond)
+	}
+	if stake.Unbonding == 0 {
+		stake.UnbondingUntil = 0
+	}
+	if !stake.hasStake() {
+		stake.PublicKey = ""
+	}
+	execution.stakes[tx.Sender] = stake
+	return nil
+}
+
+// electValidators picks the validators of the next epoch from the accounts
+// holding a bond after the block at a height: the largest bonds of those not
+// jailed, up to MaxValidators, with ties going to the lower address.
+func (bc *Blockchain) electValidators(updated map[string]Account, height int) []Validator {
+	eligible := func(account Account) bool {
+		return account.Bonded > 0 && account.JailedUntil <= height
+	}
+	var candidates []Validator
+	for address, account := range bc.stakes {
+		if _, changed := updated[address]; !changed && eligible(account) {
+			candidates = append(candidates, Validator{Address: address, PublicKey: account.PublicKey, Stake: account.Bonded})
+		}
+	}
+	for address, account := range updated {
+		if eligible(account) {
+			candidates = append(candidates, Validator{Address: address, PublicKey: account.PublicKey, Stake: account.Bonded})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Stake != candidates[j].Stake {
+			return candidates[i].Stake > candidates[j].Stake
+		}
+		return candidates[i].Address < candidates[j].Address
+	})
+	if bc.Staking.MaxValidators > 0 && len(candidates) > bc.Staking.MaxValidators {
+		candidates = candidates[:bc.Staking.MaxValidators]
+	}
+	return candidates
+}
+
+// Epoch returns the epoch of a height. Epoch e holds the blocks after height
+// e*EpochLength up to and including height (e+1)*EpochLength, and its
+// validators are elected by the block at height e*EpochLength.
+func (bc *Blockchain) Epoch(height int) int {
+	if height <= 0 || bc.Staking.EpochLength <= 0 {
+		return 0
+	}
+	return (height - 1) / bc.Staking.EpochLength
+}
+
+// ValidatorsAt returns the validator set that decides the block at a height.
+// It is the set elected at the latest epoch boundary, less the validators
+// jailed since. Before the first election, and for an epoch in which no
+// stake was bonded, the genesis set in Validators decides blocks instead.
+func (bc *Blockchain) ValidatorsAt(height int) *ValidatorSet {
+	validators, err := bc.Store.GetValidators(height - 1)
+	if err != nil || len(validators) == 0 {
+		return bc.Validators
+	}
+	set, err := NewValidatorSet(validators)
+	if err != nil {
+		bc.report(fmt.Errorf("error loading the validators elected for height %d: %w", height, err))
+		return bc.Validators
+	}
+	return set
+}
+
+// GetStake returns the staking state of an address.
+func (bc *Blockchain) GetStake(address string) StakeInfo {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.stakeInfo(address, bc.stakes[address], bc.ValidatorsAt(len(bc.Chain)))
+}
+
+// Stakes returns the staking state of every address holding stake or slashed
+// in the past, largest bond first.
+func (bc *Blockchain) Stakes() []StakeInfo {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	validators := bc.ValidatorsAt(len(bc.Chain))
+	stakes := make([]StakeInfo, 0, len(bc.stakes))
+	for address, account := range bc.stakes {
+		stakes = append(stakes, bc.stakeInfo(address, account, validators))
+	}
+	sort.Slice(stakes, func(i, j int) bool {
+		if stakes[i].Bonded != stakes[j].Bonded {
+			return stakes[i].Bonded > stakes[j].Bonded
+		}
+		return stakes[i].Address < stakes[j].Address
+	})
+	return stakes
+}
+
+// stakeInfo describes the staking state of an account.
+func (bc *Blockchain) stakeInfo(address string, account Account, validators *ValidatorSet) StakeInfo {
+	info := StakeInfo{
+		Address:        address,
+		PublicKey:      account.PublicKey,
+		Bonded:         account.Bonded,
+		Unbonding:      account.Unbonding,
+		UnbondingUntil: account.UnbondingUntil,
+		JailedUntil:    account.JailedUntil,
+		SlashedAt:      account.SlashedAt,
+	}
+	if validators != nil {
+		_, info.Active = validators.Get(address)
+	}
+	return info
+}
@@ -0,0 +1,228 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// stateTrieDepth is the number of bits in an account path, one level per bit.
+const stateTrieDepth = 256
+
+// emptySubtreeHashes holds the root hash of an empty subtree by its height
+// above the leaves. An empty leaf hashes to all zeros.
+var emptySubtreeHashes = func() (hashes [stateTrieDepth + 1][32]byte) {
+	for height := 1; height <= stateTrieDepth; height++ {
+		hashes[height] = hashStateNode(hashes[height-1], hashes[height-1])
+	}
+	return hashes
+}()
+
+// StateTrie is a sparse Merkle tree over account state. Each account lives at
+// the leaf addressed by the SHA-256 of its address, so the root commits to
+// every balance and nonce and any single account can be proven against it.
+// Only nodes that differ from an empty subtree are stored.
+type StateTrie struct {
+	nodes map[string][32]byte // Non-empty nodes keyed by depth and path prefix
+	mutex sync.RWMutex
+}
+
+// StateProofStep is a non-empty sibling on the path from an account leaf to
+// the root. Siblings that are empty subtrees are left out of the proof.
+type StateProofStep struct {
+	Depth int    `json:"depth"` // Depth of the sibling node, 256 being the leaves
+	Hash  string `json:"hash"`  // Hash of the sibling node
+}
+
+// AccountProof proves the state of an account against the state root of a block.
+type AccountProof struct {
+	Address   string           `json:"address"`   // Proven address
+	Account   Account          `json:"account"`   // State of the address
+	BlockHash string           `json:"blockHash"` // Block whose header holds the state root
+	Height    int              `json:"height"`    // Height of that block
+	StateRoot string           `json:"stateRoot"` // State root the proof leads to
+	Steps     []StateProofStep `json:"steps"`     // Non-empty siblings from leaf to root
+}
+
+// NewStateTrie creates an empty state trie.
+func NewStateTrie() *StateTrie {
+	return &StateTrie{nodes: make(map[string][32]byte)}
+}
+
+// Update sets the state of an address. A zero account is the same as an
+// absent one, so reverting an account to zero restores the earlier root.
+func (st *StateTrie) Update(address string, account Account) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	updatePath(address, account, st.node, st.setNode)
+}
+
+// rootAfter returns the root the trie would have after updating the given
+// accounts, leaving the trie unchanged. The nodes on the paths of the
+// accounts are recomputed in an overlay read before the trie's own nodes.
+func (st *StateTrie) rootAfter(accounts map[string]Account) string {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	overlay := make(map[string][32]byte)
+	node := func(depth int, path [32]byte) [32]byte {
+		if hash, exists := overlay[stateNodeKey(depth, path)]; exists {
+			return hash
+		}
+		return st.node(depth, path)
+	}
+	setNode := func(depth int, path [32]byte, hash [32]byte) {
+		overlay[stateNodeKey(depth, path)] = hash
+	}
+	for address, account := range accounts {
+		updatePath(address, account, node, setNode)
+	}
+	root := node(0, [32]byte{})
+	return hex.EncodeToString(root[:])
+}
+
+// updatePath sets the leaf of an address and recomputes the nodes on its path
+// up to the root, reading and writing nodes through the given functions.
+func updatePath(address string, account Account, node func(int, [32]byte) [32]byte, setNode func(int, [32]byte, [32]byte)) {
+	path := sha256.Sum256([]byte(address))
+	hash := emptySubtreeHashes[0]
+	if account != (Account{}) {
+		hash = hashStateLeaf(address, account)
+	}
+	for depth := stateTrieDepth; ; depth-- {
+		setNode(depth, path, hash)
+		if depth == 0 {
+			return
+		}
+		sibling := node(depth, flipPathBit(path, depth-1))
+		if pathBit(path, depth-1) == 0 {
+			hash = hashStateNode(hash, sibling)
+		} else {
+			hash = hashStateNode(sibling, hash)
+		}
+	}
+}
+
+// Root returns the hex-encoded root hash of the trie.
+func (st *StateTrie) Root() string {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	root := st.node(0, [32]byte{})
+	return hex.EncodeToString(root[:])
+}
+
+// Prove returns the non-empty siblings on the path of an address.
+func (st *StateTrie) Prove(address string) []StateProofStep {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	path := sha256.Sum256([]byte(address))
+	var steps []StateProofStep
+	for depth := stateTrieDepth; depth > 0; depth-- {
+		sibling := st.node(depth, flipPathBit(path, depth-1))
+		if sibling != emptySubtreeHashes[stateTrieDepth-depth] {
+			steps = append(steps, StateProofStep{Depth: depth, Hash: hex.EncodeToString(sibling[:])})
+		}
+	}
+	return steps
+}
+
+// VerifyAccountProof checks that the account in a proof is the state of its
+// address under a state root taken from a trusted block header.
+func VerifyAccountProof(proof *AccountProof, stateRoot string) bool {
+	path := sha256.Sum256([]byte(proof.Address))
+	hash := emptySubtreeHashes[0]
+	if proof.Account != (Account{}) {
+		hash = hashStateLeaf(proof.Address, proof.Account)
+	}
+
+	steps := proof.Steps
+	for depth := stateTrieDepth; depth > 0; depth-- {
+		sibling := emptySubtreeHashes[stateTrieDepth-depth]
+		if len(steps) > 0 && steps[0].Depth == depth {
+			decoded, err := hex.DecodeString(steps[0].Hash)
+			if err != nil || len(decoded) != len(sibling) {
+				return false
+			}
+			copy(sibling[:], decoded)
+			steps = steps[1:]
+		}
+		if pathBit(path, depth-1) == 0 {
+			hash = hashStateNode(hash, sibling)
+		} else {
+			hash = hashStateNode(sibling, hash)
+		}
+	}
+	return len(steps) == 0 && hex.EncodeToString(hash[:]) == stateRoot
+}
+
+// node returns the hash of the node at a depth on a path.
+func (st *StateTrie) node(depth int, path [32]byte) [32]byte {
+	if hash, exists := st.nodes[stateNodeKey(depth, path)]; exists {
+		return hash
+	}
+	return emptySubtreeHashes[stateTrieDepth-depth]
+}
+
+// setNode stores the hash of the node at a depth on a path, dropping it if it
+// is the hash of an empty subtree.
+func (st *StateTrie) setNode(depth int, path [32]byte, hash [32]byte) {
+	key := stateNodeKey(depth, path)
+	if hash == emptySubtreeHashes[stateTrieDepth-depth] {
+		delete(st.nodes, key)
+	} else {
+		st.nodes[key] = hash
+	}
+}
+
+// stateNodeKey identifies a node by its depth and the first depth bits of a path.
+func stateNodeKey(depth int, path [32]byte) string {
+	prefix := path[:(depth+7)/8]
+	if depth%8 != 0 {
+		prefix[len(prefix)-1] &= byte(0xFF << (8 - depth%8))
+	}
+	return strconv.Itoa(depth) + ":" + string(prefix)
+}
+
+// pathBit returns bit i of a path, counting from the most significant bit.
+func pathBit(path [32]byte, i int) byte {
+	return path[i/8] >> (7 - i%8) & 1
+}
+
+// flipPathBit returns the path with bit i inverted, giving the sibling's path.
+func flipPathBit(path [32]byte, i int) [32]byte {
+	path[i/8] ^= 1 << (7 - i%8)
+	return path
+}
+
+// hashStateLeaf commits to an address and its account state. The staking
+// fields are only committed to once the address has staked.
+func hashStateLeaf(address string, account Account) [32]byte {
+	record := fmt.Sprintf("%d:%s:%d:%d", len(address), address, int64(account.Balance), account.Nonce)
+	if account.hasStakingRecord() || account.PublicKey != "" {
+		record += fmt.Sprintf(":%d:%d:%d:%s", int64(account.Bonded), int64(account.Unbonding), account.UnbondingUntil, account.PublicKey)
+	}
+	if account.SlashedAt != 0 {
+		record += fmt.Sprintf(":%d:%d", account.JailedUntil, account.SlashedAt)
+	}
+	if account.Released != 0 {
+		record += fmt.Sprintf(":%d", int64(account.Released))
+	}
+	if account.Authority != "" {
+		record += fmt.Sprintf(":%d:%d:%s", account.Decimals, int64(account.Cap), account.Authority)
+	}
+	if account.CapExempt {
+		record += ":exempt"
+	}
+	return sha256.Sum256(append([]byte{merkleLeafPrefix}, record...))
+}
+
+// hashStateNode combines two child hashes into their parent.
+func hashStateNode(left, right [32]byte) [32]byte {
+	data := append([]byte{merkleNodePrefix}, left[:]...)
+	return sha256.Sum256(append(data, right[:]...))
+}
@@ -0,0 +1,435 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Errors returned by a Store for missing data.
+var (
+	ErrNotFound = errors.New("not found in store")
+	ErrPruned   = errors.New("data has been pruned")
+)
+
+// Account is the persisted state of a single address.
+type Account struct {
+	Balance        Amount // Balance in BMT
+	Nonce          uint64 // Nonce expected on the next transaction from this address
+	Bonded         Amount // Stake bonded by the address as a validator
+	Unbonding      Amount // Stake released from the bond and waiting out the unbonding period
+	UnbondingUntil int    // Height from which the unbonding stake can be withdrawn
+	PublicKey      string // Public key registered with the bond, which signs consensus votes
+	JailedUntil    int    // Height from which a jailed validator can be elected again
+	SlashedAt      int    // Height of the block that last slashed the validator
+	Released       Amount // Coins released from the address's vesting schedule
+	Decimals       int    // Decimal places of the token a registry entry defines
+	Cap            Amount // Supply cap of the token a registry entry defines, whose supply is the Balance
+	Authority      string // Address allowed to mint the token a registry entry defines
+	CapExempt      bool   // Whether governance exempts the address from the ownership cap
+}
+
+// hasStake reports whether an account holds bonded or unbonding stake.
+func (a Account) hasStake() bool {
+	return a.Bonded != 0 || a.Unbonding != 0
+}
+
+// hasStakingRecord reports whether an account holds stake or has been
+// slashed, so that its staking state must be kept.
+func (a Account) hasStakingRecord() bool {
+	return a.hasStake() || a.SlashedAt != 0
+}
+
+// TxLocation records where a transaction was included in the chain.
+type TxLocation struct {
+	BlockHash string // Hash of the containing block
+	Height    int    // Height of the containing block
+	SubBlock  int    // Index of the sub-block within the block
+	MiniBlock int    // Index of the mini-block within the sub-block
+	Position  int    // Index of the transaction within the mini-block
+}
+
+// StateUpdate holds the account changes produced by a block, along with the
+// prior state of the same accounts so the block can be reverted.
+type StateUpdate struct {
+	Accounts       map[string]Account // Updated accounts keyed by address
+	TotalSupply    Amount             // Total supply after the block
+	Previous       map[string]Account // Accounts before the block, keyed by address
+	PreviousSupply Amount             // Total supply before the block
+	Validators     []Validator        // Validators elected by a block ending an epoch or jailing validators
+	Elected        bool               // Whether the block records Validators, which may be empty
+	Rewards        map[string]Amount  // Block reward minted to each address, included in Accounts
+	Minted         Amount             // Coins minted by the block, or since genesis for a snapshot
+	Burned         Amount             // Coins burned by the block, or since genesis for a snapshot
+}
+
+// Store persists blocks, account state, the transaction index and the
+// history of transactions involving each address.
+// CommitBlock must write a block and its state update atomically, and
+// RevertBlock must remove the latest block and restore the state before it
+// atomically. ImportSnapshot fills a store holding only the genesis block
+// with the blocks up to a snapshot and the complete account state at it;
+// blocks up to the snapshot cannot be reverted afterwards. Prune discards the
+// undo data and bodies of old blocks.
+type Store interface {
+	GetBlockByHash(hash string) (*Block, error)
+	GetBlockByHeight(height int) (*Block, error)
+	Height() int // Height of the latest block, or -1 for an empty store
+	GetAccount(address string) (Account, error)
+	Accounts() (map[string]Account, error)
+	TotalSupply() Amount
+	Issuance() (minted, burned Amount) // Coins minted and burned since genesis, up to the latest block
+	GetTxLocation(txHash string) (TxLocation, error)
+	GetAddressTxs(address string, offset, limit int) ([]string, int, error)
+	GetValidators(height int) ([]Validator, error)
+	GetRewards(height int) (map[string]Amount, error)
+	CommitBlock(block *Block, update StateUpdate) error
+	RevertBlock() (*Block, StateUpdate, error)
+	ImportSnapshot(blocks []*Block, update StateUpdate) error
+	Prune(stateHeight, bodyHeight int) error
+	Close() error
+}
+
+// MemoryStore is a Store kept entirely in memory, intended for tests.
+type MemoryStore struct {
+	blocks       []*Block
+	updates      []StateUpdate
+	byHash       map[string]*Block
+	accounts     map[string]Account
+	txIndex      map[string]TxLocation
+	addressIndex map[string][]string // Hashes of transactions involving each address, oldest first
+	totalSupply  Amount
+	minted       Amount              // Coins minted since genesis
+	burned       Amount              // Coins burned since genesis
+	elections    map[int][]Validator // Validators elected by the blocks ending epochs, by height
+	electedAt    []int               // Heights of those blocks in ascending order
+	revertFloor  int                 // Blocks at or below this height cannot be reverted
+	bodyFloor    int                 // Blocks at or below this height are held as headers only
+	mutex        sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		byHash:       make(map[string]*Block),
+		accounts:     make(map[string]Account),
+		txIndex:      make(map[string]TxLocation),
+		addressIndex: make(map[string][]string),
+		elections:    make(map[int][]Validator),
+	}
+}
+
+// GetBlockByHash retrieves a block by its hash.
+func (ms *MemoryStore) GetBlockByHash(hash string) (*Block, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	block, exists := ms.byHash[hash]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return block, nil
+}
+
+// GetBlockByHeight retrieves a block by its height.
+func (ms *MemoryStore) GetBlockByHeight(height int) (*Block, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	if height < 0 || height >= len(ms.blocks) {
+		return nil, ErrNotFound
+	}
+	return ms.blocks[height], nil
+}
+
+// Height returns the height of the latest block, or -1 if the store is empty.
+func (ms *MemoryStore) Height() int {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	return len(ms.blocks) - 1
+}
+
+// GetAccount retrieves the state of an address.
+func (ms *MemoryStore) GetAccount(address string) (Account, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	account, exists := ms.accounts[address]
+	if !exists {
+		return Account{}, ErrNotFound
+	}
+	return account, nil
+}
+
+// Accounts returns a copy of every stored account.
+func (ms *MemoryStore) Accounts() (map[string]Account, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	accounts := make(map[string]Account, len(ms.accounts))
+	for address, account := range ms.accounts {
+		accounts[address] = account
+	}
+	return accounts, nil
+}
+
+// TotalSupply returns the total supply recorded by the latest block.
+func (ms *MemoryStore) TotalSupply() Amount {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	return ms.totalSupply
+}
+
+// Issuance returns the coins minted and burned since genesis, up to the
+// latest block.
+func (ms *MemoryStore) Issuance() (minted, burned Amount) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	return ms.minted, ms.burned
+}
+
+// GetTxLocation retrieves the location of a transaction by its hash.
+func (ms *MemoryStore) GetTxLocation(txHash string) (TxLocation, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	location, exists := ms.txIndex[txHash]
+	if !exists {
+		return TxLocation{}, ErrNotFound
+	}
+	return location, nil
+}
+
+// CommitBlock appends a block and applies its state update.
+func (ms *MemoryStore) CommitBlock(block *Block, update StateUpdate) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if err := checkCommitHeight(block, len(ms.blocks)-1); err != nil {
+		return err
+	}
+	ms.applyCommit(block, update)
+	return nil
+}
+
+// applyCommit writes a block and its state update into the in-memory indexes.
+func (ms *MemoryStore) applyCommit(block *Block, update StateUpdate) {
+	ms.blocks = append(ms.blocks, block)
+	ms.updates = append(ms.updates, update)
+	ms.byHash[block.Hash] = block
+	for address, account := range update.Accounts {
+		ms.accounts[address] = account
+	}
+	for txHash, location := range txLocations(block) {
+		ms.txIndex[txHash] = location
+	}
+	ms.indexAddresses(block)
+	if update.Elected {
+		ms.elections[block.Index] = update.Validators
+		ms.electedAt = append(ms.electedAt, block.Index)
+	}
+	ms.totalSupply = update.TotalSupply
+	ms.minted += update.Minted
+	ms.burned += update.Burned
+}
+
+// RevertBlock removes the latest block and restores the accounts it changed.
+func (ms *MemoryStore) RevertBlock() (*Block, StateUpdate, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if err := ms.checkRevertHeight(len(ms.blocks) - 1); err != nil {
+		return nil, StateUpdate{}, err
+	}
+	block, update := ms.applyRevert()
+	return block, update, nil
+}
+
+// ImportSnapshot appends the blocks following genesis up to a snapshot and
+// replaces the account state with the snapshot's.
+func (ms *MemoryStore) ImportSnapshot(blocks []*Block, update StateUpdate) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if err := checkSnapshotImport(blocks, len(ms.blocks)-1); err != nil {
+		return err
+	}
+	ms.applySnapshot(blocks, update)
+	return nil
+}
+
+// applySnapshot writes imported blocks and account state into the in-memory indexes.
+func (ms *MemoryStore) applySnapshot(blocks []*Block, update StateUpdate) {
+	for _, block := range blocks {
+		ms.applyCommit(block, StateUpdate{})
+	}
+	ms.accounts = make(map[string]Account, len(update.Accounts))
+	for address, account := range update.Accounts {
+		ms.accounts[address] = account
+	}
+	ms.totalSupply = update.TotalSupply
+	ms.minted, ms.burned = update.Minted, update.Burned
+	ms.revertFloor = len(ms.blocks) - 1
+	for _, block := range blocks {
+		if block.HeaderOnly() {
+			ms.bodyFloor = block.Index
+		}
+	}
+}
+
+// Prune discards the undo data of the blocks up to stateHeight, after which
+// they cannot be reverted, and the bodies of the blocks up to bodyHeight,
+// keeping their headers and transaction locations.
+func (ms *MemoryStore) Prune(stateHeight, bodyHeight int) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if err := checkPruneHeights(stateHeight, bodyHeight, len(ms.blocks)-1); err != nil {
+		return err
+	}
+	ms.applyPrune(stateHeight, bodyHeight)
+	return nil
+}
+
+// applyPrune drops pruned undo data and bodies from the in-memory indexes.
+// The rewards of a block are kept until its body is pruned.
+func (ms *MemoryStore) applyPrune(stateHeight, bodyHeight int) {
+	for height := ms.revertFloor + 1; height <= stateHeight; height++ {
+		ms.updates[height] = StateUpdate{Rewards: ms.updates[height].Rewards}
+		ms.revertFloor = height
+	}
+	for height := ms.bodyFloor + 1; height <= bodyHeight; height++ {
+		header := ms.blocks[height].WithoutBody()
+		ms.blocks[height] = header
+		ms.byHash[header.Hash] = header
+		ms.updates[height].Rewards = nil
+		ms.bodyFloor = height
+	}
+}
+
+// applyRevert removes the latest block from the in-memory indexes.
+func (ms *MemoryStore) applyRevert() (*Block, StateUpdate) {
+	last := len(ms.blocks) - 1
+	block, update := ms.blocks[last], ms.updates[last]
+	ms.blocks, ms.updates = ms.blocks[:last], ms.updates[:last]
+	delete(ms.byHash, block.Hash)
+	for address, account := range update.Previous {
+		ms.accounts[address] = account
+	}
+	for txHash := range txLocations(block) {
+		delete(ms.txIndex, txHash)
+	}
+	ms.unindexAddresses(block)
+	if _, elected := ms.elections[block.Index]; elected {
+		delete(ms.elections, block.Index)
+		ms.electedAt = ms.electedAt[:len(ms.electedAt)-1]
+	}
+	ms.totalSupply = update.PreviousSupply
+	ms.minted -= update.Minted
+	ms.burned -= update.Burned
+	return block, update
+}
+
+// GetValidators returns the validators of the latest election at or below a
+// height, which are empty if nobody was eligible at that election.
+func (ms *MemoryStore) GetValidators(height int) ([]Validator, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	i := sort.SearchInts(ms.electedAt, height+1)
+	if i == 0 {
+		return nil, ErrNotFound
+	}
+	return ms.elections[ms.electedAt[i-1]], nil
+}
+
+// GetRewards returns the block reward minted to each address by the block at
+// a height. Rewards are kept with the block's body; they are not held for
+// the genesis block or for blocks imported in a snapshot.
+func (ms *MemoryStore) GetRewards(height int) (map[string]Amount, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	if height < 0 || height >= len(ms.blocks) {
+		return nil, ErrNotFound
+	}
+	if height <= ms.bodyFloor {
+		return nil, ErrPruned
+	}
+	rewards := ms.updates[height].Rewards
+	if rewards == nil {
+		return nil, ErrNotFound
+	}
+	return rewards, nil
+}
+
+// Close releases the store. The in-memory store holds no resources.
+func (ms *MemoryStore) Close() error {
+	return nil
+}
+
+// checkCommitHeight ensures a block extends the store's current height.
+func checkCommitHeight(block *Block, height int) error {
+	if block.Index != height+1 {
+		return fmt.Errorf("cannot commit block at height %d on top of height %d", block.Index, height)
+	}
+	return nil
+}
+
+// checkRevertHeight ensures the block at the given height can be reverted.
+func (ms *MemoryStore) checkRevertHeight(height int) error {
+	if height < 1 {
+		return errors.New("cannot revert the genesis block")
+	}
+	if height <= ms.revertFloor {
+		return fmt.Errorf("%w: cannot revert block %d at or below height %d", ErrPruned, height, ms.revertFloor)
+	}
+	return nil
+}
+
+// checkPruneHeights ensures pruning keeps the latest block intact and never
+// drops a body whose undo data is still kept, so that every revertible block
+// can be returned in full.
+func checkPruneHeights(stateHeight, bodyHeight, height int) error {
+	if stateHeight >= height || bodyHeight >= height {
+		return fmt.Errorf("cannot prune up to the latest block at height %d", height)
+	}
+	if bodyHeight > stateHeight {
+		return fmt.Errorf("cannot prune bodies up to height %d while undo data is kept from height %d", bodyHeight, stateHeight+1)
+	}
+	return nil
+}
+
+// checkSnapshotImport ensures a snapshot is imported on top of genesis only
+// and that its blocks are in height order.
+func checkSnapshotImport(blocks []*Block, height int) error {
+	if height != 0 {
+		return fmt.Errorf("cannot import a snapshot into a store at height %d", height)
+	}
+	for i, block := range blocks {
+		if block.Index != i+1 {
+			return fmt.Errorf("snapshot block %d found at position %d", block.Index, i+1)
+		}
+	}
+	return nil
+}
+
+// txLocations lists the location of every transaction in a block.
+func txLocations(block *Block) map[string]TxLocation {
+	locations := make(map[string]TxLocation)
+	for s, sub := range block.SubBlocks {
+		for m, mini := range sub.MiniBlocks {
+			for p, tx := range mini.Transactions {
+				locations[tx.Hash] = TxLocation{
+					BlockHash: block.Hash,
+					Height:    block.Index,
+					SubBlock:  s,
+					MiniBlock: m,
+					Position:  p,
+				}
+			}
+		}
+	}
+	return locations
+}
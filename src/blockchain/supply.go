@@ -0,0 +1,151 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BurnAddress is the address signed transactions are sent to in order to
+// destroy the amount, which is removed from the total supply.
+const BurnAddress = "supply/burn"
+
+// ErrSupplyMismatch is returned when the coins held by the accounts do not
+// add up to the total supply.
+var ErrSupplyMismatch = errors.New("account balances do not add up to the total supply")
+
+// SupplyReport breaks down the supply of BMT coins.
+type SupplyReport struct {
+	Total         Amount `json:"total"`         // Coins in existence
+	Max           Amount `json:"max"`           // Hard cap on the total supply
+	Circulating   Amount `json:"circulating"`   // Total less the coins locked by vesting or in the bridge
+	Minted        Amount `json:"minted"`        // Coins minted since genesis
+	Burned        Amount `json:"burned"`        // Coins burned since genesis
+	VestingLocked Amount `json:"vestingLocked"` // Coins locked by vesting schedules
+	BridgeLocked  Amount `json:"bridgeLocked"`  // Coins locked in the cross-chain bridge
+	Staked        Amount `json:"staked"`        // Coins bonded or unbonding, included in the total
+}
+
+// Burn destroys coins of an address, removing them from the total supply.
+// Coins locked by the address's vesting schedule cannot be burned.
+func (t *Tokenomics) Burn(from string, amount Amount) error {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+
+	if amount <= 0 {
+		return errors.New("burn amount must be greater than zero")
+	}
+	remaining, err := t.Balances[from].Sub(amount)
+	if err != nil {
+		return errors.New("insufficient balance")
+	}
+	if locked := t.locked(from); remaining < locked {
+		return fmt.Errorf("%w: %s of the balance is locked", ErrLockedFunds, locked)
+	}
+
+	t.Balances[from] = remaining
+	t.TotalSupply -= amount
+	t.Burned += amount
+	t.Events.Publish(Event{Type: EventBurn, Height: -1, From: from, Amount: amount})
+	return nil
+}
+
+// SupplyReport returns the breakdown of the ledger's supply, with the coins
+// locked in a bridge, if given, taken out of circulation.
+func (t *Tokenomics) SupplyReport(bridge *CrossChainBridge) SupplyReport {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+	return t.supplyReport(t.Minted, t.Burned, bridge)
+}
+
+// supplyReport builds a supply report with the given issuance, with the
+// tokenomics locked.
+func (t *Tokenomics) supplyReport(minted, burned Amount, bridge *CrossChainBridge) SupplyReport {
+	report := SupplyReport{
+		Total:       t.TotalSupply,
+		Max:         t.MaxSupply,
+		Circulating: t.circulatingSupply(),
+		Minted:      minted,
+		Burned:      burned,
+	}
+	for address := range t.Vesting {
+		report.VestingLocked += t.locked(address)
+	}
+	if bridge != nil {
+		report.BridgeLocked = bridge.TotalLocked()
+	}
+	if report.Circulating -= report.BridgeLocked; report.Circulating < 0 {
+		report.Circulating = 0
+	}
+	return report
+}
+
+// CheckSupply verifies that the balances add up to the total supply. The
+// tokenomics of a chain do not hold staked coins; Blockchain.CheckSupply
+// checks a chain's ledger.
+func (t *Tokenomics) CheckSupply() error {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+
+	var held Amount
+	for _, balance := range t.Balances {
+		held += balance
+	}
+	if held != t.TotalSupply {
+		return fmt.Errorf("%w: balances hold %s, total supply is %s", ErrSupplyMismatch, held, t.TotalSupply)
+	}
+	return nil
+}
+
+// SupplyReport returns the breakdown of the chain's supply up to the latest
+// block, with the coins locked in a bridge, if given, taken out of
+// circulation.
+func (bc *Blockchain) SupplyReport(bridge *CrossChainBridge) SupplyReport {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	minted, burned := bc.Store.Issuance()
+	bc.Tokenomics.TransactionMutex.Lock()
+	report := bc.Tokenomics.supplyReport(minted, burned, bridge)
+	bc.Tokenomics.TransactionMutex.Unlock()
+	for _, account := range bc.stakes {
+		report.Staked += account.Bonded + account.Unbonding
+	}
+	return report
+}
+
+// CheckSupply verifies that the coins held by the stored accounts, spendable
+// or staked, add up to the total supply, that the supply is the genesis
+// supply plus the coins minted less those burned, and that the balances of
+// the tokenomics match the store.
+func (bc *Blockchain) CheckSupply() error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	accounts, err := bc.Store.Accounts()
+	if err != nil {
+		return fmt.Errorf("error loading accounts: %v", err)
+	}
+	var held Amount
+	for address, account := range accounts {
+		if isRecordAddress(address) {
+			continue
+		}
+		for _, amount := range []Amount{account.Balance, account.Bonded, account.Unbonding} {
+			if held, err = held.Add(amount); err != nil {
+				return err
+			}
+		}
+		if balance := bc.Tokenomics.GetBalance(address); balance != account.Balance {
+			return fmt.Errorf("%w: tokenomics hold %s for %s, the store %s", ErrSupplyMismatch, balance, address, account.Balance)
+		}
+	}
+	supply := bc.Store.TotalSupply()
+	if held != supply {
+		return fmt.Errorf("%w: accounts hold %s, total supply is %s", ErrSupplyMismatch, held, supply)
+	}
+	minted, burned := bc.Store.Issuance()
+	if expected := InitialSupply + minted - burned; supply != expected {
+		return fmt.Errorf("%w: total supply is %s, issuance since genesis gives %s", ErrSupplyMismatch, supply, expected)
+	}
+	return nil
+}
@@ -2,28 +2,42 @@ package blockchain
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 )
 
 // Tokenomics defines the properties and logic of the BMT Coin.
 type Tokenomics struct {
-	TotalSupply      float64            // Total supply of BMT coins
-	MaxSupply        float64            // Maximum supply allowed
-	Balances         map[string]float64 // Mapping of addresses to balances
-	TransactionMutex sync.Mutex         // Mutex for thread-safe operations
+	TotalSupply      Amount                     // Total supply of BMT coins
+	MaxSupply        Amount                     // Maximum supply allowed
+	Minted           Amount                     // Coins created by MintCoins
+	Burned           Amount                     // Coins destroyed by Burn
+	Balances         map[string]Amount          // Mapping of addresses to balances
+	Vesting          map[string]VestingSchedule // Vesting schedules locking granted coins, by beneficiary
+	Released         map[string]Amount          // Vested coins released to each beneficiary
+	OwnershipCap     float64                    // Largest fraction of the circulating supply a credit may take an address to, or 0 for no cap
+	CapExempt        map[string]bool            // Addresses exempt from the ownership cap, kept in the account state of a chain
+	Events           *EventBus                  // Bus publishing transfers, mints, burns and vesting releases, if set
+	TransactionMutex sync.Mutex                 // Mutex for thread-safe operations
 }
 
 // NewTokenomics initializes the tokenomics with total supply and max supply.
-func NewTokenomics(totalSupply, maxSupply float64) *Tokenomics {
+func NewTokenomics(totalSupply, maxSupply Amount) *Tokenomics {
 	return &Tokenomics{
-		TotalSupply: totalSupply,
-		MaxSupply:   maxSupply,
-		Balances:    make(map[string]float64),
+		TotalSupply:  totalSupply,
+		MaxSupply:    maxSupply,
+		Balances:     make(map[string]Amount),
+		Vesting:      make(map[string]VestingSchedule),
+		Released:     make(map[string]Amount),
+		OwnershipCap: DefaultOwnershipCap,
+		CapExempt:    make(map[string]bool),
 	}
 }
 
-// Transfer handles the transfer of BMT coins between wallets.
-func (t *Tokenomics) Transfer(from, to string, amount float64) error {
+// Transfer handles the transfer of BMT coins between wallets. Coins locked by
+// the sender's vesting schedule cannot be transferred, and the receiver must
+// stay within the ownership cap.
+func (t *Tokenomics) Transfer(from, to string, amount Amount) error {
 	t.TransactionMutex.Lock()
 	defer t.TransactionMutex.Unlock()
 
@@ -32,19 +46,31 @@ func (t *Tokenomics) Transfer(from, to string, amount float64) error {
 	}
 
 	// Check if the sender has enough balance
-	if t.Balances[from] < amount {
+	fromBalance, err := t.Balances[from].Sub(amount)
+	if err != nil {
 		return errors.New("insufficient balance")
 	}
+	if locked := t.locked(from); fromBalance < locked {
+		return fmt.Errorf("%w: %s of the balance is locked", ErrLockedFunds, locked)
+	}
+	if from != to {
+		if err := t.checkOwnership(to, t.Balances[to], amount); err != nil {
+			return err
+		}
+	}
 
-	// Perform the transfer
-	t.Balances[from] -= amount
+	// Perform the transfer; balances are bounded by the total supply so the
+	// credit cannot overflow
+	t.Balances[from] = fromBalance
 	t.Balances[to] += amount
+	t.Events.Publish(Event{Type: EventTransfer, Height: -1, From: from, To: to, Amount: amount})
 
 	return nil
 }
 
-// MintCoins adds new coins to a specified wallet (e.g., rewards or incentives).
-func (t *Tokenomics) MintCoins(to string, amount float64) error {
+// MintCoins adds new coins to a specified wallet (e.g., rewards or incentives),
+// within the max supply and the ownership cap.
+func (t *Tokenomics) MintCoins(to string, amount Amount) error {
 	t.TransactionMutex.Lock()
 	defer t.TransactionMutex.Unlock()
 
@@ -53,20 +79,60 @@ func (t *Tokenomics) MintCoins(to string, amount float64) error {
 	}
 
 	// Ensure we do not exceed max supply
-	if t.TotalSupply+amount > t.MaxSupply {
+	if amount > t.headroom(t.TotalSupply) {
 		return errors.New("minting exceeds max supply")
 	}
+	if err := t.checkOwnership(to, t.Balances[to], amount); err != nil {
+		return err
+	}
 
 	// Mint coins
 	t.Balances[to] += amount
 	t.TotalSupply += amount
+	t.Minted += amount
+	t.Events.Publish(Event{Type: EventMint, Height: -1, To: to, Amount: amount})
 
 	return nil
 }
 
+// headroom returns how many coins can be minted on top of a supply without
+// exceeding the max supply.
+func (t *Tokenomics) headroom(supply Amount) Amount {
+	if supply >= t.MaxSupply {
+		return 0
+	}
+	return t.MaxSupply - supply
+}
+
 // GetBalance retrieves the balance of a specific wallet.
-func (t *Tokenomics) GetBalance(address string) float64 {
+func (t *Tokenomics) GetBalance(address string) Amount {
 	t.TransactionMutex.Lock()
 	defer t.TransactionMutex.Unlock()
 	return t.Balances[address]
 }
+
+// setBalances overwrites the balances, released vesting amounts and ownership
+// cap exemptions of the given accounts, other than records, and the total
+// supply.
+func (t *Tokenomics) setBalances(accounts map[string]Account, totalSupply Amount) {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+
+	for address, account := range accounts {
+		if isRecordAddress(address) {
+			continue
+		}
+		t.Balances[address] = account.Balance
+		if account.Released != 0 {
+			t.Released[address] = account.Released
+		} else {
+			delete(t.Released, address)
+		}
+		if account.CapExempt {
+			t.CapExempt[address] = true
+		} else {
+			delete(t.CapExempt, address)
+		}
+	}
+	t.TotalSupply = totalSupply
+}
@@ -0,0 +1,217 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Addresses that signed transactions in a token are sent to in order to
+// manage the token. Transactions in a token sent to BurnAddress burn the
+// amount of the token.
+const (
+	IssueTokenAddress = "token/issue" // Registers the transaction's token with its decimals and a supply cap of the amount, minted by the sender
+	MintTokenAddress  = "token/mint"  // Mints the amount of the token to the sender, who must be its mint authority
+)
+
+// MaxTokenDecimals is the most decimal places a token may have.
+const MaxTokenDecimals = 18
+
+// Errors returned when a transaction in a token is rejected.
+var (
+	ErrUnknownToken        = errors.New("token is not registered")
+	ErrTokenExists         = errors.New("token is already registered")
+	ErrNotMintAuthority    = errors.New("sender is not the token's mint authority")
+	ErrTokenCap            = errors.New("mint exceeds the token's supply cap")
+	ErrInsufficientTokens  = errors.New("insufficient token balance")
+	ErrInvalidTokenSymbol  = errors.New("token symbol must be 2 to 12 upper-case letters and digits, starting with a letter")
+	ErrInvalidTokenDetails = fmt.Errorf("token needs a positive supply cap and at most %d decimals", MaxTokenDecimals)
+)
+
+// tokenSymbol matches the symbols tokens may be registered under.
+var tokenSymbol = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,11}$`)
+
+// TokenInfo describes a token issued on the chain.
+type TokenInfo struct {
+	Symbol    string `json:"symbol"`    // Symbol the token is registered under
+	Decimals  int    `json:"decimals"`  // Decimal places of the token
+	Cap       Amount `json:"cap"`       // Most units of the token that may exist
+	Supply    Amount `json:"supply"`    // Units of the token minted and not burned
+	Authority string `json:"authority"` // Address allowed to mint the token
+}
+
+// Token registry entries and token balances are kept in the account state
+// under token addresses: "token/<symbol>" holds the registry entry, whose
+// Balance is the token's supply, and "token/<symbol>/<holder>" holds the
+// balance of a holder.
+const tokenPrefix = "token/"
+
+// isTokenAddress reports whether an address is a token address, which no
+// BMT can be sent to.
+func isTokenAddress(address string) bool {
+	return strings.HasPrefix(address, tokenPrefix)
+}
+
+// tokenEntry returns the address of a token's registry entry.
+func tokenEntry(symbol string) string {
+	return tokenPrefix + symbol
+}
+
+// tokenBalance returns the address of a holder's balance of a token.
+func tokenBalance(symbol, holder string) string {
+	return tokenPrefix + symbol + "/" + holder
+}
+
+// NewTokenTransaction creates a transaction in a registered token from the
+// wallet's address and signs it. The amount is in units of the token; the
+// fee is paid in BMT.
+func NewTokenTransaction(wallet *Wallet, symbol, receiver string, amount, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	return newTokenTransaction(wallet, symbol, 0, receiver, amount, fee, nonce, chainID, timestamp)
+}
+
+// NewIssueTokenTransaction creates a transaction registering a token with
+// the wallet's address as its mint authority and signs it.
+func NewIssueTokenTransaction(wallet *Wallet, symbol string, decimals int, cap, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	return newTokenTransaction(wallet, symbol, decimals, IssueTokenAddress, cap, fee, nonce, chainID, timestamp)
+}
+
+// newTokenTransaction creates a transaction in a token and signs it.
+func newTokenTransaction(wallet *Wallet, symbol string, decimals int, receiver string, amount, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	if symbol == "" {
+		return nil, errors.New("token symbol cannot be empty")
+	}
+	tx, err := NewTransaction(wallet.Address, receiver, amount, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if fee < 0 {
+		return nil, errors.New("transaction fee cannot be negative")
+	}
+	tx.Fee, tx.Nonce, tx.ChainID = fee, nonce, chainID
+	tx.Token, tx.Decimals = symbol, decimals
+	if err := tx.Sign(wallet); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// loadRecord copies the token or approval record at an address not yet
+// touched by the execution into its working records.
+func (bc *Blockchain) loadRecord(execution *blockExecution, address string) Account {
+	if _, ok := execution.records[address]; !ok {
+		execution.records[address] = bc.records[address]
+	}
+	return execution.records[address]
+}
+
+// executeToken records the effect of a transaction in a token: registering
+// it, minting it, burning it or transferring it to another address.
+func (bc *Blockchain) executeToken(tx *Transaction, execution *blockExecution) error {
+	if tx.Receiver == IssueTokenAddress {
+		return bc.executeIssueToken(tx, execution)
+	}
+	entry := bc.loadRecord(execution, tokenEntry(tx.Token))
+	if entry.Authority == "" {
+		return fmt.Errorf("%w: %s", ErrUnknownToken, tx.Token)
+	}
+
+	if tx.Receiver == MintTokenAddress {
+		if tx.Sender != entry.Authority {
+			return ErrNotMintAuthority
+		}
+		supply, err := entry.Balance.Add(tx.Amount)
+		if err != nil || supply > entry.Cap {
+			return fmt.Errorf("%w of %s", ErrTokenCap, entry.Cap)
+		}
+		entry.Balance = supply
+		execution.records[tokenEntry(tx.Token)] = entry
+		return bc.creditToken(execution, tx.Token, tx.Sender, tx.Amount)
+	}
+	if tx.Receiver != BurnAddress && isProtocolAddress(tx.Receiver) {
+		return fmt.Errorf("tokens cannot be sent to %s", tx.Receiver)
+	}
+
+	holder := bc.loadRecord(execution, tokenBalance(tx.Token, tx.Sender))
+	remaining, err := holder.Balance.Sub(tx.Amount)
+	if err != nil {
+		return fmt.Errorf("%w: %s holds %s %s", ErrInsufficientTokens, tx.Sender, holder.Balance, tx.Token)
+	}
+	holder.Balance = remaining
+	execution.records[tokenBalance(tx.Token, tx.Sender)] = holder
+	if tx.Receiver == BurnAddress {
+		entry.Balance -= tx.Amount
+		execution.records[tokenEntry(tx.Token)] = entry
+		return nil
+	}
+	return bc.creditToken(execution, tx.Token, tx.Receiver, tx.Amount)
+}
+
+// executeIssueToken registers the token of a transaction with its decimals,
+// a supply cap of its amount and its sender as the mint authority.
+func (bc *Blockchain) executeIssueToken(tx *Transaction, execution *blockExecution) error {
+	if !tokenSymbol.MatchString(tx.Token) || tx.Token == "BMT" {
+		return ErrInvalidTokenSymbol
+	}
+	if tx.Decimals < 0 || tx.Decimals > MaxTokenDecimals || tx.Amount <= 0 {
+		return ErrInvalidTokenDetails
+	}
+	if bc.loadRecord(execution, tokenEntry(tx.Token)).Authority != "" {
+		return fmt.Errorf("%w: %s", ErrTokenExists, tx.Token)
+	}
+	execution.records[tokenEntry(tx.Token)] = Account{Decimals: tx.Decimals, Cap: tx.Amount, Authority: tx.Sender}
+	return nil
+}
+
+// creditToken adds an amount of a token to a holder's balance.
+func (bc *Blockchain) creditToken(execution *blockExecution, symbol, holder string, amount Amount) error {
+	account := bc.loadRecord(execution, tokenBalance(symbol, holder))
+	balance, err := account.Balance.Add(amount)
+	if err != nil {
+		return err
+	}
+	account.Balance = balance
+	execution.records[tokenBalance(symbol, holder)] = account
+	return nil
+}
+
+// GetToken returns the registry entry of a token, if it is registered.
+func (bc *Blockchain) GetToken(symbol string) (TokenInfo, bool) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	entry, ok := bc.records[tokenEntry(symbol)]
+	if !ok || entry.Authority == "" {
+		return TokenInfo{}, false
+	}
+	return TokenInfo{Symbol: symbol, Decimals: entry.Decimals, Cap: entry.Cap, Supply: entry.Balance, Authority: entry.Authority}, true
+}
+
+// Tokens returns the registered tokens, sorted by symbol.
+func (bc *Blockchain) Tokens() []TokenInfo {
+	bc.mutex.Lock()
+	var symbols []string
+	for address, entry := range bc.records {
+		if isTokenAddress(address) && entry.Authority != "" {
+			symbols = append(symbols, strings.TrimPrefix(address, tokenPrefix))
+		}
+	}
+	bc.mutex.Unlock()
+
+	sort.Strings(symbols)
+	tokens := make([]TokenInfo, 0, len(symbols))
+	for _, symbol := range symbols {
+		if token, ok := bc.GetToken(symbol); ok {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// GetTokenBalance returns the balance of a token held by an address.
+func (bc *Blockchain) GetTokenBalance(symbol, address string) Amount {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.records[tokenBalance(symbol, address)].Balance
+}
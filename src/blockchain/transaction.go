@@ -1,31 +1,48 @@
 package blockchain
 
 import (
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"strconv"
 )
 
+// Errors returned when a signed transaction fails verification.
+var (
+	ErrInvalidHash         = errors.New("transaction hash does not match its contents")
+	ErrInvalidSignature    = errors.New("invalid transaction signature")
+	ErrSenderMismatch      = errors.New("sender address does not match public key")
+	ErrWrongChainID        = errors.New("transaction chain ID does not match this chain")
+	ErrInvalidNonce        = errors.New("transaction nonce is out of order")
+	ErrReplayedTransaction = errors.New("transaction has already been included")
+	ErrUnsignedTransaction = errors.New("transaction is not signed")
+)
+
 // Transaction represents a single transaction in the blockchain.
 type Transaction struct {
 	Sender    string  // Address of the sender
 	Receiver  string  // Address of the receiver
-	Amount    float64 // Amount being transferred (supports up to 0.0000001 BMT)
+	Amount    Amount  // Amount being transferred (supports up to 0.0000001 BMT)
+	Fee       Amount  // Fee offered to the block producer for inclusion
 	Timestamp int64   // Unix timestamp of the transaction
+	Nonce     uint64  // Per-account sequence number of the sender
+	ChainID   string  // Identifier of the chain the transaction is valid on
+	Token     string  // Symbol of the registered token the amount is in, or empty for BMT
+	Decimals  int     // Decimal places of a token issued by the transaction
+	Target    string  // Governance target, allowance spender or owner of the coins a spender transfers, if any
+	Permit    *Permit // Permit applied by a transaction to the permit address, if any
+	PublicKey string  // Hex-encoded public key of the sender
+	Signature string  // Hex-encoded ECDSA signature over the hash
 	Hash      string  // Hash of the transaction
 }
 
 // NewTransaction creates a new transaction with given details.
-func NewTransaction(sender, receiver string, amount float64, timestamp int64) (*Transaction, error) {
+func NewTransaction(sender, receiver string, amount Amount, timestamp int64) (*Transaction, error) {
 	// Validate inputs
 	if sender == "" || receiver == "" {
 		return nil, errors.New("sender and receiver addresses cannot be empty")
 	}
-	if amount <= 0 {
-		return nil, errors.New("transaction amount must be positive")
-	}
-	if amount < 0.0000001 {
+	if amount < BaseUnit {
 		return nil, errors.New("transaction amount must be at least 0.0000001 BMT")
 	}
 
@@ -43,14 +60,90 @@ func NewTransaction(sender, receiver string, amount float64, timestamp int64) (*
 	return tx, nil
 }
 
-// CalculateHash generates a hash for the transaction.
+// NewSignedTransaction creates a transaction from the wallet's address and signs it.
+func NewSignedTransaction(wallet *Wallet, receiver string, amount, fee Amount, nonce uint64, chainID string, timestamp int64) (*Transaction, error) {
+	tx, err := NewTransaction(wallet.Address, receiver, amount, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if fee < 0 {
+		return nil, errors.New("transaction fee cannot be negative")
+	}
+	tx.Fee = fee
+	tx.Nonce = nonce
+	tx.ChainID = chainID
+	if err := tx.Sign(wallet); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Sign recalculates the hash and signs it with the wallet's private key.
+func (t *Transaction) Sign(wallet *Wallet) error {
+	if wallet.Address != t.Sender {
+		return ErrSenderMismatch
+	}
+	t.Hash = t.CalculateHash()
+	signature, err := wallet.SignTransaction(t.Hash)
+	if err != nil {
+		return err
+	}
+	t.PublicKey = wallet.PublicKey
+	t.Signature = signature
+	return nil
+}
+
+// CalculateHash generates a hash for the transaction over a canonical
+// encoding of its fields, so that no other transaction shares its hash. Each
+// optional field that is set is preceded by its own tag, so that no two
+// combinations of them encode alike.
 func (t *Transaction) CalculateHash() string {
-	record := t.Sender + t.Receiver + strconv.FormatInt(t.Timestamp, 10) + strconv.FormatFloat(t.Amount, 'f', 7, 64)
-	hash := sha256.Sum256([]byte(record))
-	return hex.EncodeToString(hash[:])
+	fields := []string{t.Sender, t.Receiver, formatInt(t.Timestamp), formatInt(int64(t.Amount)),
+		formatInt(int64(t.Fee)), strconv.FormatUint(t.Nonce, 10), t.ChainID}
+	if t.Token != "" {
+		fields = append(fields, "token", t.Token)
+	}
+	if t.Decimals != 0 {
+		fields = append(fields, "decimals", strconv.Itoa(t.Decimals))
+	}
+	if t.Target != "" {
+		fields = append(fields, "target", t.Target)
+	}
+	if t.Permit != nil {
+		fields = append(fields, "permit", t.Permit.Hash(), t.Permit.PublicKey, t.Permit.Signature)
+	}
+	return hashFields(fields...)
 }
 
 // Validate checks if the transaction is valid.
 func (t *Transaction) Validate() bool {
 	return t.Hash == t.CalculateHash()
 }
+
+// VerifySignature checks the hash, that the public key belongs to the sender
+// and that the signature over the hash was made with that key.
+func (t *Transaction) VerifySignature() error {
+	if t.Signature == "" {
+		return ErrUnsignedTransaction
+	}
+	if !t.Validate() {
+		return ErrInvalidHash
+	}
+
+	pubKeyBytes, err := hex.DecodeString(t.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSenderMismatch, err)
+	}
+	if GenerateAddress(pubKeyBytes) != t.Sender {
+		return ErrSenderMismatch
+	}
+
+	valid, err := VerifySignature(t.PublicKey, t.Signature, t.Hash)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxClockDrift is the number of seconds a block timestamp may run ahead of
+// the local clock.
+const MaxClockDrift int64 = 2 * 60
+
+// Errors identifying the validation rule a block breaks. Blocks failing
+// validation are reported with a *BlockValidationError wrapping one of these
+// or another sentinel such as ErrStateRootMismatch.
+var (
+	ErrBlockHeight        = errors.New("block height does not follow its parent")
+	ErrPreviousHash       = errors.New("block does not reference its parent")
+	ErrBlockHash          = errors.New("block hash does not match its header")
+	ErrBlockTimestamp     = errors.New("block timestamp is out of bounds")
+	ErrBlockRoots         = errors.New("block roots do not match its contents")
+	ErrBlockLimits        = errors.New("block exceeds the size limits")
+	ErrBlockBaseFee       = errors.New("block base fee does not follow its parent")
+	ErrBlockDifficulty    = errors.New("block difficulty does not follow its parent")
+	ErrProofOfWork        = errors.New("block proof of work is invalid")
+	ErrBlockTransaction   = errors.New("block holds an invalid transaction")
+	ErrIneligibleProposer = errors.New("block proposer is not an eligible validator")
+)
+
+// BlockValidationError reports which validation rule a block broke and how.
+// errors.Is matches both the rule and the underlying cause, such as the
+// ErrInvalidNonce of a rejected transaction.
+type BlockValidationError struct {
+	Height int    // Height of the rejected block
+	Hash   string // Hash of the rejected block
+	Rule   error  // Sentinel error of the broken rule
+	Err    error  // What broke the rule
+}
+
+// Error describes the broken rule and its cause.
+func (e *BlockValidationError) Error() string {
+	return fmt.Sprintf("block %d rejected: %v: %v", e.Height, e.Rule, e.Err)
+}
+
+// Unwrap returns the broken rule and its cause.
+func (e *BlockValidationError) Unwrap() []error {
+	return []error{e.Rule, e.Err}
+}
+
+// invalidBlock reports a block breaking a validation rule.
+func invalidBlock(block *Block, rule, err error) error {
+	return &BlockValidationError{Height: block.Index, Hash: block.Hash, Rule: rule, Err: err}
+}
+
+// checkBlockHeader verifies the header fields of a block that depend only on
+// its parent: height, linkage, hash, timestamp and the recorded commit.
+func checkBlockHeader(block, parent *Block) error {
+	if block.Index != parent.Index+1 {
+		return invalidBlock(block, ErrBlockHeight, fmt.Errorf("height %d does not follow parent height %d", block.Index, parent.Index))
+	}
+	if block.PreviousHash != parent.Hash {
+		return invalidBlock(block, ErrPreviousHash, fmt.Errorf("previous hash %s, parent is %s", block.PreviousHash, parent.Hash))
+	}
+	if hash := block.CalculateHash(); block.Hash != hash {
+		return invalidBlock(block, ErrBlockHash, fmt.Errorf("header hashes to %s", hash))
+	}
+	if err := checkTimestamp(block); err != nil {
+		return err
+	}
+	if block.Timestamp < parent.Timestamp {
+		return invalidBlock(block, ErrBlockTimestamp, fmt.Errorf("timestamp %d is before the parent's %d", block.Timestamp, parent.Timestamp))
+	}
+	if hash := block.LastCommit.Hash(); block.LastCommitHash != hash {
+		return invalidBlock(block, ErrBlockRoots, fmt.Errorf("last commit hashes to %s, header has %s", hash, block.LastCommitHash))
+	}
+	return nil
+}
+
+// checkTimestamp verifies that a block is not dated too far ahead of the
+// local clock.
+func checkTimestamp(block *Block) error {
+	if limit := time.Now().Unix() + MaxClockDrift; block.Timestamp > limit {
+		return invalidBlock(block, ErrBlockTimestamp, fmt.Errorf("timestamp %d is more than %d seconds ahead of the local clock", block.Timestamp, MaxClockDrift))
+	}
+	return nil
+}
+
+// checkBlockBody verifies that a block's contents match the roots in its
+// header and stay within the size limits.
+func checkBlockBody(block *Block) error {
+	if root := block.CalculateMerkleRoot(); block.MerkleRoot != root {
+		return invalidBlock(block, ErrBlockRoots, fmt.Errorf("transactions have Merkle root %s, header has %s", root, block.MerkleRoot))
+	}
+	if root := block.CalculateEvidenceRoot(); block.EvidenceRoot != root {
+		return invalidBlock(block, ErrBlockRoots, fmt.Errorf("evidence has root %s, header has %s", root, block.EvidenceRoot))
+	}
+	if err := checkBlockLimits(block); err != nil {
+		return invalidBlock(block, ErrBlockLimits, err)
+	}
+	return nil
+}
+
+// checkTransactions verifies the integrity of every transaction in a block
+// without the chain state: the hash and signature of every transaction.
+// Nonces and balances are checked when the block is executed.
+func checkTransactions(block *Block) error {
+	transactions := block.Transactions()
+	for i := range transactions {
+		tx := &transactions[i]
+		if err := tx.VerifySignature(); err != nil {
+			return invalidBlock(block, ErrBlockTransaction, fmt.Errorf("transaction %s: %w", tx.Hash, err))
+		}
+	}
+	return nil
+}
+
+// checkHeader verifies a block against its parent in the block tree: its
+// header, body, base fee, difficulty and the proof of work and solve times of
+// its mini-blocks.
+func checkHeader(block *Block, parent *blockNode) error {
+	if err := checkBlockHeader(block, parent.block); err != nil {
+		return err
+	}
+	if err := checkBlockBody(block); err != nil {
+		return err
+	}
+	if expected := NextBaseFee(parent.block); block.BaseFee != expected {
+		return invalidBlock(block, ErrBlockBaseFee, fmt.Errorf("base fee %s, expected %s", block.BaseFee, expected))
+	}
+	if expected := nextDifficulty(parent); block.Difficulty != expected {
+		return invalidBlock(block, ErrBlockDifficulty, fmt.Errorf("difficulty %d, expected %d", block.Difficulty, expected))
+	}
+	if err := checkProofOfWork(block); err != nil {
+		return invalidBlock(block, ErrProofOfWork, err)
+	}
+	if err := checkSolveTime(block, parent.block); err != nil {
+		return invalidBlock(block, ErrProofOfWork, err)
+	}
+	return nil
+}
+
+// checkProposer verifies that a block at a height with a validator set was
+// proposed by one of its validators.
+func (bc *Blockchain) checkProposer(block *Block) error {
+	validators := bc.ValidatorsAt(block.Index)
+	if validators == nil {
+		return nil
+	}
+	if _, ok := validators.Get(block.Proposer); !ok {
+		return invalidBlock(block, ErrIneligibleProposer, fmt.Errorf("%s is not a validator at height %d", block.Proposer, block.Index))
+	}
+	return nil
+}
+
+// ValidateBlock runs every validation rule against a block built on the
+// canonical tip without adding it: the header against the tip, the body
+// against its roots and the size limits, the proposer against the validator
+// set, and the evidence and transactions against the tip's state, which must
+// produce the state root in the header. The first broken rule is returned as
+// a *BlockValidationError.
+func (bc *Blockchain) ValidateBlock(block *Block) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.validateBlock(block)
+}
+
+// validateBlock runs ValidateBlock with the chain locked.
+func (bc *Blockchain) validateBlock(block *Block) error {
+	if err := checkHeader(block, bc.tipNode()); err != nil {
+		return err
+	}
+	if err := bc.checkProposer(block); err != nil {
+		return err
+	}
+	root, err := bc.stateRootAfter(block)
+	if err != nil {
+		var validationErr *BlockValidationError
+		if errors.As(err, &validationErr) {
+			return err
+		}
+		return invalidBlock(block, ErrBlockTransaction, err)
+	}
+	if root != block.StateRoot {
+		return invalidBlock(block, ErrStateRootMismatch, fmt.Errorf("executing the block gives %s, header has %s", root, block.StateRoot))
+	}
+	return nil
+}
+
+// ValidateChain checks every canonical block against its parent and returns
+// the first rule broken. Blocks held as headers only, below an imported
+// snapshot or pruned, are checked by their headers alone. The base fee is
+// checked when the parent holds its body, and the difficulty is recomputed
+// when the whole retargeting window does; otherwise it may only move by the
+// one bit a retarget allows. Balances and nonces were checked when each block
+// was executed and cannot be checked again without the state before it.
+func (bc *Blockchain) ValidateChain() error {
+	bodiesFrom := 0 // Height from which every block holds its body
+	parent := &blockNode{block: bc.Chain[0]}
+	for i := 1; i < len(bc.Chain); i++ {
+		block, previous := bc.Chain[i], parent.block
+		if err := checkBlockHeader(block, previous); err != nil {
+			return err
+		}
+		if block.Index-DifficultyWindow >= bodiesFrom || bodiesFrom == 0 {
+			if expected := nextDifficulty(parent); block.Difficulty != expected {
+				return invalidBlock(block, ErrBlockDifficulty, fmt.Errorf("difficulty %d, expected %d", block.Difficulty, expected))
+			}
+		} else if !withinRetarget(block.Difficulty, previous.Difficulty) {
+			return invalidBlock(block, ErrBlockDifficulty, fmt.Errorf("difficulty %d cannot follow the parent's %d", block.Difficulty, previous.Difficulty))
+		}
+		if block.HeaderOnly() {
+			bodiesFrom = block.Index + 1
+		} else {
+			if err := checkBlockBody(block); err != nil {
+				return err
+			}
+			if previous.Index >= bodiesFrom {
+				if expected := NextBaseFee(previous); block.BaseFee != expected {
+					return invalidBlock(block, ErrBlockBaseFee, fmt.Errorf("base fee %s, expected %s", block.BaseFee, expected))
+				}
+			}
+			if err := checkProofOfWork(block); err != nil {
+				return invalidBlock(block, ErrProofOfWork, err)
+			}
+			if err := checkSolveTime(block, previous); err != nil {
+				return invalidBlock(block, ErrProofOfWork, err)
+			}
+			if err := checkTransactions(block); err != nil {
+				return err
+			}
+		}
+		if err := bc.checkProposer(block); err != nil {
+			return err
+		}
+		parent = &blockNode{block: block, parent: parent}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// ErrUnknownValidator is returned for a vote or proposal signed by an address
+// outside the validator set.
+var ErrUnknownValidator = errors.New("address is not in the validator set")
+
+// Validator is a member of the validator set. Its votes are weighted by its
+// bonded stake.
+type Validator struct {
+	Address   string // Address derived from the public key
+	PublicKey string // Hex-encoded public key that signs proposals and votes
+	Stake     Amount // Bonded stake weighting the validator's votes
+}
+
+// ValidatorSet is the set of validators that proposes and votes on blocks.
+type ValidatorSet struct {
+	Validators []Validator // Members sorted by address
+	totalStake Amount
+}
+
+// NewValidatorSet checks a list of validators and builds a set from it. Every
+// validator needs a public key matching its address and a positive stake.
+func NewValidatorSet(validators []Validator) (*ValidatorSet, error) {
+	if len(validators) == 0 {
+		return nil, errors.New("validator set cannot be empty")
+	}
+
+	set := &ValidatorSet{Validators: append([]Validator(nil), validators...)}
+	sort.Slice(set.Validators, func(i, j int) bool { return set.Validators[i].Address < set.Validators[j].Address })
+	for i, validator := range set.Validators {
+		if i > 0 && set.Validators[i-1].Address == validator.Address {
+			return nil, fmt.Errorf("validator %s is listed twice", validator.Address)
+		}
+		publicKey, err := hex.DecodeString(validator.PublicKey)
+		if err != nil || GenerateAddress(publicKey) != validator.Address {
+			return nil, fmt.Errorf("public key of validator %s does not match its address", validator.Address)
+		}
+		if validator.Stake <= 0 {
+			return nil, fmt.Errorf("validator %s has no bonded stake", validator.Address)
+		}
+		if set.totalStake, err = set.totalStake.Add(validator.Stake); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// TotalStake returns the stake bonded by all validators.
+func (vs *ValidatorSet) TotalStake() Amount {
+	return vs.totalStake
+}
+
+// QuorumStake returns the smallest stake that is more than two thirds of the
+// total stake.
+func (vs *ValidatorSet) QuorumStake() Amount {
+	twoThirds, _ := vs.totalStake.MulDiv(2, 3)
+	return twoThirds + BaseUnit
+}
+
+// hasQuorum reports whether a stake is more than two thirds of the total.
+func (vs *ValidatorSet) hasQuorum(stake Amount) bool {
+	return stake >= vs.QuorumStake()
+}
+
+// exceedsOneThird reports whether a stake is more than a third of the total,
+// so that at least one honest validator contributed to it.
+func (vs *ValidatorSet) exceedsOneThird(stake Amount) bool {
+	oneThird, _ := vs.totalStake.Div(3)
+	return stake > oneThird
+}
+
+// Get returns the validator with the given address.
+func (vs *ValidatorSet) Get(address string) (Validator, bool) {
+	i := sort.Search(len(vs.Validators), func(i int) bool { return vs.Validators[i].Address >= address })
+	if i < len(vs.Validators) && vs.Validators[i].Address == address {
+		return vs.Validators[i], true
+	}
+	return Validator{}, false
+}
+
+// Proposer returns the validator expected to propose the block at a height
+// in a round. It is drawn from a hash of the height and round, each
+// validator with a chance in proportion to its stake, so every node picks
+// the same proposer and the role rotates with every height and round.
+func (vs *ValidatorSet) Proposer(height, round int) Validator {
+	seed := sha256.Sum256([]byte(strconv.Itoa(height) + "/" + strconv.Itoa(round)))
+	draw := new(big.Int).Mod(new(big.Int).SetBytes(seed[:]), big.NewInt(int64(vs.totalStake)))
+	target := Amount(draw.Int64())
+	for _, validator := range vs.Validators {
+		if target < validator.Stake {
+			return validator
+		}
+		target -= validator.Stake
+	}
+	return vs.Validators[len(vs.Validators)-1]
+}
+
+// stakeOf returns the combined stake of a group of validators.
+func (vs *ValidatorSet) stakeOf(addresses map[string]bool) Amount {
+	var stake Amount
+	for address := range addresses {
+		if validator, ok := vs.Get(address); ok {
+			stake += validator.Stake
+		}
+	}
+	return stake
+}
@@ -0,0 +1,215 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ReleaseAddress is the address signed transactions are sent to in order to
+// release the amount from the sender's vesting schedule. It never holds a
+// balance; released coins are already in the sender's account.
+const ReleaseAddress = "vesting/release"
+
+// isProtocolAddress reports whether an address is a staking address, the
+// release address, the burn address, a token or approval address, an
+// allowance address or a governance address, which never hold a balance.
+func isProtocolAddress(address string) bool {
+	return isStakingAddress(address) || address == ReleaseAddress || address == BurnAddress ||
+		isRecordAddress(address) || isAllowanceAddress(address) || isGovernanceAddress(address)
+}
+
+// ErrLockedFunds is returned when a transfer would spend coins still locked
+// by a vesting schedule.
+var ErrLockedFunds = errors.New("funds are locked by a vesting schedule")
+
+// VestingSchedule locks coins granted to a beneficiary and lets them vest
+// linearly from Start over Duration seconds, with nothing vesting before the
+// cliff. A schedule whose cliff equals its duration vests all at once.
+// Granted coins are held in the beneficiary's balance but cannot be spent
+// until they have vested and been released.
+type VestingSchedule struct {
+	Beneficiary string // Address the coins are granted to
+	Amount      Amount // Coins granted
+	Start       int64  // Unix time vesting starts
+	Cliff       int64  // Seconds after Start before anything vests
+	Duration    int64  // Seconds after Start when everything has vested
+}
+
+// VestingInfo is the state of a beneficiary's vesting schedule at a time.
+type VestingInfo struct {
+	VestingSchedule
+	Vested     Amount // Coins vested so far
+	Released   Amount // Vested coins released so far, which can be spent
+	Releasable Amount // Vested coins not yet released
+	Locked     Amount // Coins not yet released, which cannot be spent
+}
+
+// validate checks that a schedule grants coins over a sensible period.
+func (s VestingSchedule) validate() error {
+	if s.Beneficiary == "" || s.Amount <= 0 {
+		return errors.New("vesting schedule needs a beneficiary and a positive amount")
+	}
+	if s.Duration <= 0 || s.Cliff < 0 || s.Cliff > s.Duration {
+		return fmt.Errorf("vesting schedule of %s needs a positive duration and a cliff within it", s.Beneficiary)
+	}
+	return nil
+}
+
+// Vested returns the coins of the schedule vested at a Unix time.
+func (s VestingSchedule) Vested(at int64) Amount {
+	elapsed := at - s.Start
+	switch {
+	case elapsed < s.Cliff:
+		return 0
+	case elapsed >= s.Duration:
+		return s.Amount
+	}
+	vested, err := s.Amount.MulDiv(elapsed, s.Duration)
+	if err != nil {
+		return 0
+	}
+	return vested
+}
+
+// info returns the state of the schedule at a Unix time after releasing an
+// amount.
+func (s VestingSchedule) info(released Amount, at int64) VestingInfo {
+	info := VestingInfo{VestingSchedule: s, Vested: s.Vested(at), Released: released, Locked: s.Amount - released}
+	if info.Vested > released {
+		info.Releasable = info.Vested - released
+	}
+	return info
+}
+
+// AddVestingSchedule locks a grant to a beneficiary, who may hold one
+// schedule. The granted coins are credited separately, by the genesis
+// allocation or a mint.
+func (t *Tokenomics) AddVestingSchedule(schedule VestingSchedule) error {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+
+	if err := schedule.validate(); err != nil {
+		return err
+	}
+	if _, exists := t.Vesting[schedule.Beneficiary]; exists {
+		return fmt.Errorf("%s already has a vesting schedule", schedule.Beneficiary)
+	}
+	t.Vesting[schedule.Beneficiary] = schedule
+	return nil
+}
+
+// VestingInfo returns the state of an address's vesting schedule at a Unix
+// time, if it has one.
+func (t *Tokenomics) VestingInfo(address string, at int64) (VestingInfo, bool) {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+
+	schedule, ok := t.Vesting[address]
+	if !ok {
+		return VestingInfo{}, false
+	}
+	return schedule.info(t.Released[address], at), true
+}
+
+// Release releases the coins of an address's vesting schedule vested at a
+// Unix time, making them spendable, and returns the amount released.
+func (t *Tokenomics) Release(address string, at int64) (Amount, error) {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+
+	schedule, ok := t.Vesting[address]
+	if !ok {
+		return 0, fmt.Errorf("%s has no vesting schedule", address)
+	}
+	info := schedule.info(t.Released[address], at)
+	if info.Releasable == 0 {
+		return 0, errors.New("nothing has vested since the last release")
+	}
+	t.Released[address] += info.Releasable
+	t.Events.Publish(Event{Type: EventVestingRelease, Height: -1, To: address, Amount: info.Releasable})
+	return info.Releasable, nil
+}
+
+// SpendableBalance returns the balance of an address less the coins locked
+// by its vesting schedule.
+func (t *Tokenomics) SpendableBalance(address string) Amount {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+
+	spendable, err := t.Balances[address].Sub(t.locked(address))
+	if err != nil {
+		return 0
+	}
+	return spendable
+}
+
+// locked returns the coins of an address's vesting schedule not yet
+// released.
+func (t *Tokenomics) locked(address string) Amount {
+	schedule, ok := t.Vesting[address]
+	if !ok || t.Released[address] >= schedule.Amount {
+		return 0
+	}
+	return schedule.Amount - t.Released[address]
+}
+
+// getReleased returns the coins released from an address's vesting schedule.
+func (t *Tokenomics) getReleased(address string) Amount {
+	t.TransactionMutex.Lock()
+	defer t.TransactionMutex.Unlock()
+	return t.Released[address]
+}
+
+// loadReleased copies the released amount of an address not yet touched by
+// the execution into its working released amounts.
+func (bc *Blockchain) loadReleased(execution *blockExecution, address string) Amount {
+	if _, ok := execution.released[address]; !ok {
+		execution.released[address] = bc.Tokenomics.getReleased(address)
+	}
+	return execution.released[address]
+}
+
+// checkLocked verifies that a sender's balance after a transaction still
+// covers the coins locked by its vesting schedule.
+func (bc *Blockchain) checkLocked(address string, remaining Amount, execution *blockExecution) error {
+	schedule, ok := bc.vestingSchedule(address)
+	if !ok {
+		return nil
+	}
+	if locked := schedule.info(bc.loadReleased(execution, address), execution.timestamp).Locked; remaining < locked {
+		return fmt.Errorf("%w: %s of the balance is locked", ErrLockedFunds, locked)
+	}
+	return nil
+}
+
+// executeRelease records a transaction releasing vested coins of the
+// sender's vesting schedule at the block's timestamp.
+func (bc *Blockchain) executeRelease(tx *Transaction, execution *blockExecution) error {
+	schedule, ok := bc.vestingSchedule(tx.Sender)
+	if !ok {
+		return fmt.Errorf("%s has no vesting schedule", tx.Sender)
+	}
+	released := bc.loadReleased(execution, tx.Sender)
+	info := schedule.info(released, execution.timestamp)
+	if tx.Amount > info.Releasable {
+		return fmt.Errorf("only %s of the vesting schedule is releasable", info.Releasable)
+	}
+	execution.released[tx.Sender] = released + tx.Amount
+	return nil
+}
+
+// vestingSchedule returns the vesting schedule of an address, if any.
+func (bc *Blockchain) vestingSchedule(address string) (VestingSchedule, bool) {
+	bc.Tokenomics.TransactionMutex.Lock()
+	defer bc.Tokenomics.TransactionMutex.Unlock()
+	schedule, ok := bc.Tokenomics.Vesting[address]
+	return schedule, ok
+}
+
+// GetVesting returns the state of an address's vesting schedule at the
+// timestamp of the latest block, if it has one.
+func (bc *Blockchain) GetVesting(address string) (VestingInfo, bool) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.Tokenomics.VestingInfo(address, bc.Chain[len(bc.Chain)-1].Timestamp)
+}
@@ -15,7 +15,7 @@ type Wallet struct {
 	PrivateKey *ecdsa.PrivateKey
 	PublicKey  string
 	Address    string
-	Balance    float64 // Balance in BMT
+	Balance    Amount // Balance in BMT
 }
 
 // NewWallet creates a new wallet with a unique key pair.
@@ -25,14 +25,17 @@ func NewWallet() (*Wallet, error) {
 		return nil, err
 	}
 
-	publicKey := append(privateKey.PublicKey.X.Bytes(), privateKey.PublicKey.Y.Bytes()...)
+	// Fixed-width coordinates keep the encoded key at 64 bytes
+	publicKey := make([]byte, 64)
+	privateKey.PublicKey.X.FillBytes(publicKey[:32])
+	privateKey.PublicKey.Y.FillBytes(publicKey[32:])
 	address := GenerateAddress(publicKey)
 
 	return &Wallet{
 		PrivateKey: privateKey,
 		PublicKey:  hex.EncodeToString(publicKey),
 		Address:    address,
-		Balance:    0.0,
+		Balance:    0,
 	}, nil
 }
 
@@ -50,7 +53,10 @@ func (w *Wallet) SignTransaction(transactionHash string) (string, error) {
 		return "", err
 	}
 
-	signature := append(r.Bytes(), s.Bytes()...)
+	// Fixed-width r and s so the verifier can split the signature in half
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
 	return hex.EncodeToString(signature), nil
 }
 
@@ -71,6 +77,9 @@ func VerifySignature(publicKey, signature, transactionHash string) (bool, error)
 	if err != nil {
 		return false, err
 	}
+	if len(sigBytes) != 64 {
+		return false, errors.New("invalid signature length")
+	}
 
 	r := new(big.Int).SetBytes(sigBytes[:len(sigBytes)/2])
 	s := new(big.Int).SetBytes(sigBytes[len(sigBytes)/2:])
@@ -81,6 +90,6 @@ func VerifySignature(publicKey, signature, transactionHash string) (bool, error)
 }
 
 // UpdateBalance updates the wallet's balance by a specified amount.
-func (w *Wallet) UpdateBalance(amount float64) {
+func (w *Wallet) UpdateBalance(amount Amount) {
 	w.Balance += amount
 }
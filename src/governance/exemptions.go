@@ -0,0 +1,35 @@
+package governance
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+)
+
+// CapExemption is a change to the addresses exempt from the ownership cap,
+// such as the system, bridge and pool addresses.
+type CapExemption struct {
+	Address string // Address whose exemption changes
+	Exempt  bool   // Whether the address becomes exempt or loses its exemption
+}
+
+// NewCapExemptionProposal creates a proposal to exempt an address from the
+// ownership cap, or to remove its exemption.
+func NewCapExemptionProposal(id, description, address string, exempt bool, threshold float64) *Proposal {
+	proposal := NewProposal(id, description, threshold)
+	proposal.Exemption = &CapExemption{Address: address, Exempt: exempt}
+	return proposal
+}
+
+// ExemptionTransaction creates the governance transaction that carries out
+// the exemption change of a passed proposal, signed with the chain's
+// governance wallet. The change takes effect on every node once a block
+// including the transaction is committed.
+func (p *Proposal) ExemptionTransaction(wallet *blockchain.Wallet, fee blockchain.Amount, nonce uint64, chainID string, timestamp int64) (*blockchain.Transaction, error) {
+	if p.Exemption == nil {
+		return nil, errors.New("proposal does not change the ownership cap exemptions")
+	}
+	if _, passed := p.CountVotes(); !passed {
+		return nil, errors.New("proposal has not passed")
+	}
+	return blockchain.NewCapExemptionTransaction(wallet, p.Exemption.Address, p.Exemption.Exempt, fee, nonce, chainID, timestamp)
+}
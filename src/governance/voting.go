@@ -1,7 +1,6 @@
 package governance
 
 import (
-	"errors"
 	"sync"
 )
 
@@ -11,6 +10,7 @@ type Proposal struct {
 	Description string            // Description of the proposal
 	Votes       map[string]bool   // Votes from nodes (true for yes, false for no)
 	Threshold   float64           // Percentage of "yes" votes required to pass
+	Exemption   *CapExemption     // Change to the ownership cap exemptions, if the proposal makes one
 	mutex       sync.Mutex        // Mutex for thread safety
 }
 
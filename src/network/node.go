@@ -4,6 +4,7 @@ import (
 	"BMT-Blockchain/src/blockchain"
 	"BMT-Blockchain/src/governance"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
@@ -15,6 +16,10 @@ type Node struct {
 	Address    string                // Network address (e.g., "127.0.0.1:8080")
 	Peers      map[string]*Peer      // List of connected peers
 	Blockchain *blockchain.Blockchain // Blockchain managed by this node
+	Mempool    *blockchain.Mempool    // Pending transactions awaiting inclusion
+	Storage    StorageConfig          // History kept by the node and how it is pruned
+	Consensus  *blockchain.BFTConsensus // Consensus engine, if the node takes part in consensus
+	OnError    func(error)           // Receives the errors the node recovers from while serving peers, if set
 	mutex      sync.Mutex            // Mutex for thread safety
 }
 
@@ -24,36 +29,80 @@ type Peer struct {
 	Address string // Network address of the peer
 }
 
-// NewNode creates a new node with a given ID and address.
-func NewNode(id, address string) *Node {
-	return &Node{
+// NewNode creates a new node with a given ID and address whose blockchain is
+// kept in memory. Every node of a network must be built from the same genesis
+// configuration, such as one read by blockchain.LoadGenesisFile, which names
+// the governance address whose payouts and settings the nodes accept.
+func NewNode(id, address string, genesis blockchain.GenesisConfig) (*Node, error) {
+	return newNode(id, address, blockchain.NewMemoryStore(), genesis)
+}
+
+// NewNodeWithDataDir creates a node whose blockchain is persisted in dataDir,
+// from the genesis configuration shared by the network as for NewNode.
+func NewNodeWithDataDir(id, address, dataDir string, genesis blockchain.GenesisConfig) (*Node, error) {
+	store, err := blockchain.OpenFileStore(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	return newNode(id, address, store, genesis)
+}
+
+// newNode creates a node whose blockchain is held in a store, closing the
+// store if the blockchain cannot be loaded. A node holding the governance
+// key sets Blockchain.Treasurer to sign payouts itself.
+func newNode(id, address string, store blockchain.Store, genesis blockchain.GenesisConfig) (*Node, error) {
+	if genesis.Governance == "" {
+		store.Close()
+		return nil, errors.New("genesis configuration names no governance address")
+	}
+	bc, err := blockchain.NewBlockchainWithGenesis(store, genesis)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	node := &Node{
 		ID:         id,
 		Address:    address,
 		Peers:      make(map[string]*Peer),
-		Blockchain: blockchain.NewBlockchain(),
+		Blockchain: bc,
+		Mempool:    blockchain.NewMempool(bc, blockchain.DefaultMempoolConfig()),
+		Storage:    DefaultStorageConfig(),
 	}
+	bc.OnError = node.report
+	return node, nil
 }
 
-// Start launches the node to listen for incoming connections.
-func (node *Node) Start() {
-	fmt.Printf("Node %s is starting at %s...\n", node.ID, node.Address)
+// Start launches the node to listen for incoming connections. It returns an
+// error if the node cannot listen at its address and otherwise serves peers
+// until the process exits.
+func (node *Node) Start() error {
 	listener, err := net.Listen("tcp", node.Address)
 	if err != nil {
-		fmt.Printf("Error starting node: %v\n", err)
-		return
+		return fmt.Errorf("error starting node: %v", err)
 	}
 	defer listener.Close()
 
+	// Garbage collection runs for the life of the node
+	go node.RunGarbageCollector(nil)
+
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			fmt.Printf("Error accepting connection: %v\n", err)
+			node.report(fmt.Errorf("error accepting connection: %v", err))
 			continue
 		}
 		go node.handleConnection(conn)
 	}
 }
 
+// report passes an error the node recovered from to OnError, if set.
+func (node *Node) report(err error) {
+	if node.OnError != nil {
+		node.OnError(err)
+	}
+}
+
 // handleConnection handles incoming messages from peers.
 func (node *Node) handleConnection(conn net.Conn) {
 	defer conn.Close()
@@ -61,25 +110,39 @@ func (node *Node) handleConnection(conn net.Conn) {
 	decoder := json.NewDecoder(conn)
 	var message map[string]interface{}
 	if err := decoder.Decode(&message); err != nil {
-		fmt.Printf("Error decoding message: %v\n", err)
+		node.report(fmt.Errorf("error decoding message: %v", err))
 		return
 	}
 
 	messageType, ok := message["type"].(string)
 	if !ok {
-		fmt.Println("Invalid message format")
+		node.report(errors.New("invalid message format"))
 		return
 	}
 
 	switch messageType {
 	case "new_block":
 		node.handleNewBlock(message)
+	case "new_transaction":
+		node.handleNewTransaction(message)
 	case "sync_request":
 		node.handleSyncRequest(conn)
+	case "snapshot_manifest_request":
+		node.handleSnapshotManifestRequest(conn)
+	case "snapshot_chunk_request":
+		node.handleSnapshotChunkRequest(conn, message)
+	case "headers_request":
+		node.handleBlocksRequest(conn, message, false)
+	case "blocks_request":
+		node.handleBlocksRequest(conn, message, true)
 	case "vote_proposal":
 		node.handleVoteProposal(message)
+	case "consensus":
+		node.handleConsensusMessage(message)
+	case "evidence":
+		node.handleEvidence(message)
 	default:
-		fmt.Printf("Unknown message type: %s\n", messageType)
+		node.report(fmt.Errorf("unknown message type: %s", messageType))
 	}
 }
 
@@ -87,24 +150,46 @@ func (node *Node) handleConnection(conn net.Conn) {
 func (node *Node) handleNewBlock(message map[string]interface{}) {
 	blockData, err := json.Marshal(message["block"])
 	if err != nil {
-		fmt.Printf("Error parsing block: %v\n", err)
+		node.report(fmt.Errorf("error parsing block: %v", err))
 		return
 	}
 
 	var newBlock blockchain.Block
 	if err := json.Unmarshal(blockData, &newBlock); err != nil {
-		fmt.Printf("Error unmarshalling block: %v\n", err)
+		node.report(fmt.Errorf("error unmarshalling block: %v", err))
 		return
 	}
 
 	node.mutex.Lock()
 	defer node.mutex.Unlock()
 
-	if node.Blockchain.GetLatestBlock().Hash == newBlock.PreviousHash {
-		node.Blockchain.Chain = append(node.Blockchain.Chain, &newBlock)
-		fmt.Printf("Block added to node %s: %+v\n", node.ID, newBlock)
-	} else {
-		fmt.Printf("Invalid block received by node %s\n", node.ID)
+	reorg, err := node.Blockchain.ProcessBlock(&newBlock)
+	if err != nil {
+		node.report(fmt.Errorf("block %d rejected: %w", newBlock.Index, err))
+		return
+	}
+	if reorg != nil {
+		node.Mempool.ReturnTransactions(reorg.Detached)
+	}
+	node.Mempool.Prune()
+}
+
+// handleNewTransaction adds a transaction received from a peer to the mempool.
+func (node *Node) handleNewTransaction(message map[string]interface{}) {
+	txData, err := json.Marshal(message["transaction"])
+	if err != nil {
+		node.report(fmt.Errorf("error parsing transaction: %v", err))
+		return
+	}
+
+	var tx blockchain.Transaction
+	if err := json.Unmarshal(txData, &tx); err != nil {
+		node.report(fmt.Errorf("error unmarshalling transaction: %v", err))
+		return
+	}
+
+	if err := node.Mempool.Add(&tx); err != nil {
+		node.report(fmt.Errorf("transaction %s rejected: %w", tx.Hash, err))
 	}
 }
 
@@ -115,7 +200,68 @@ func (node *Node) handleSyncRequest(conn net.Conn) {
 
 	encoder := json.NewEncoder(conn)
 	if err := encoder.Encode(node.Blockchain.Chain); err != nil {
-		fmt.Printf("Error sending blockchain: %v\n", err)
+		node.report(fmt.Errorf("error sending blockchain: %v", err))
+	}
+}
+
+// handleSnapshotManifestRequest sends the manifest of the node's latest snapshot.
+func (node *Node) handleSnapshotManifestRequest(conn net.Conn) {
+	manifest, err := node.Blockchain.LatestSnapshot()
+	if err != nil {
+		node.report(fmt.Errorf("error serving snapshot manifest: %w", err))
+		return
+	}
+
+	encoder := json.NewEncoder(conn)
+	if err := encoder.Encode(manifest); err != nil {
+		node.report(fmt.Errorf("error sending snapshot manifest: %v", err))
+	}
+}
+
+// handleSnapshotChunkRequest sends one chunk of a snapshot.
+func (node *Node) handleSnapshotChunkRequest(conn net.Conn, message map[string]interface{}) {
+	height, heightOK := message["height"].(float64)
+	index, indexOK := message["index"].(float64)
+	if !heightOK || !indexOK {
+		node.report(errors.New("invalid snapshot chunk request"))
+		return
+	}
+
+	chunk, err := node.Blockchain.GetSnapshotChunk(int(height), int(index))
+	if err != nil {
+		node.report(fmt.Errorf("error serving snapshot chunk: %w", err))
+		return
+	}
+
+	encoder := json.NewEncoder(conn)
+	if err := encoder.Encode(chunk); err != nil {
+		node.report(fmt.Errorf("error sending snapshot chunk: %v", err))
+	}
+}
+
+// handleBlocksRequest sends a range of canonical blocks, at most
+// MaxBlocksPerRequest of them, either in full or as headers only.
+func (node *Node) handleBlocksRequest(conn net.Conn, message map[string]interface{}, withBodies bool) {
+	from, fromOK := message["from"].(float64)
+	to, toOK := message["to"].(float64)
+	if !fromOK || !toOK {
+		node.report(errors.New("invalid block range request"))
+		return
+	}
+	if to >= from+MaxBlocksPerRequest {
+		to = from + MaxBlocksPerRequest - 1
+	}
+
+	blocks := node.Blockchain.GetBlocks(int(from), int(to))
+	if !withBodies {
+		for i, block := range blocks {
+			blocks[i] = block.WithoutBody()
+		}
+	}
+
+	encoder := json.NewEncoder(conn)
+	if err := encoder.Encode(blocks); err != nil {
+		node.report(fmt.Errorf("error sending blocks: %v", err))
 	}
 }
 
@@ -123,20 +269,120 @@ func (node *Node) handleSyncRequest(conn net.Conn) {
 func (node *Node) handleVoteProposal(message map[string]interface{}) {
 	proposalData, err := json.Marshal(message["proposal"])
 	if err != nil {
-		fmt.Printf("Error parsing proposal: %v\n", err)
+		node.report(fmt.Errorf("error parsing proposal: %v", err))
 		return
 	}
 
 	var proposal governance.Proposal
 	if err := json.Unmarshal(proposalData, &proposal); err != nil {
-		fmt.Printf("Error unmarshalling proposal: %v\n", err)
+		node.report(fmt.Errorf("error unmarshalling proposal: %v", err))
 		return
 	}
 
 	vote := true // Example: Node always votes "yes"
 	proposal.Vote(node.ID, vote)
+}
 
-	fmt.Printf("Node %s voted %v on proposal %s.\n", node.ID, vote, proposal.ID)
+// handleConsensusMessage passes a block proposal or vote from a peer to the
+// node's consensus engine.
+func (node *Node) handleConsensusMessage(message map[string]interface{}) {
+	if node.Consensus == nil {
+		return
+	}
+	messageData, err := json.Marshal(message["message"])
+	if err != nil {
+		node.report(fmt.Errorf("error parsing consensus message: %v", err))
+		return
+	}
+
+	var consensusMessage blockchain.ConsensusMessage
+	if err := json.Unmarshal(messageData, &consensusMessage); err != nil {
+		node.report(fmt.Errorf("error unmarshalling consensus message: %v", err))
+		return
+	}
+
+	if err := node.Consensus.HandleMessage(consensusMessage); err != nil {
+		node.report(fmt.Errorf("consensus message rejected: %w", err))
+	}
+}
+
+// handleEvidence holds evidence of validator misbehaviour received from a
+// peer until a block includes it.
+func (node *Node) handleEvidence(message map[string]interface{}) {
+	evidenceData, err := json.Marshal(message["evidence"])
+	if err != nil {
+		node.report(fmt.Errorf("error parsing evidence: %v", err))
+		return
+	}
+
+	var evidence blockchain.Evidence
+	if err := json.Unmarshal(evidenceData, &evidence); err != nil {
+		node.report(fmt.Errorf("error unmarshalling evidence: %v", err))
+		return
+	}
+
+	if err := node.Blockchain.SubmitEvidence(&evidence); err != nil {
+		node.report(fmt.Errorf("evidence against %s rejected: %w", evidence.Validator, err))
+	}
+}
+
+// StartConsensus makes the node a validator with the given wallet. Blocks are
+// proposed from the mempool and consensus messages are sent to all peers.
+// The blockchain must have a validator set.
+func (node *Node) StartConsensus(wallet *blockchain.Wallet, config blockchain.BFTConfig) error {
+	consensus, err := blockchain.NewBFTConsensus(node.Blockchain, wallet, config)
+	if err != nil {
+		return err
+	}
+	consensus.Broadcast = node.BroadcastConsensus
+	consensus.OnError = func(err error) {
+		node.report(fmt.Errorf("consensus: %w", err))
+	}
+	consensus.Transactions = func(max int) []*blockchain.Transaction {
+		node.Mempool.Prune()
+		return node.Mempool.Pending(max)
+	}
+	node.Consensus = consensus
+	consensus.Start()
+	return nil
+}
+
+// BroadcastConsensus sends a block proposal or vote to all peers.
+func (node *Node) BroadcastConsensus(message blockchain.ConsensusMessage) {
+	for _, peer := range node.Peers {
+		go func(peer *Peer) {
+			conn, err := net.Dial("tcp", peer.Address)
+			if err != nil {
+				node.report(fmt.Errorf("error connecting to peer %s: %v", peer.ID, err))
+				return
+			}
+			defer conn.Close()
+
+			encoder := json.NewEncoder(conn)
+			if err := encoder.Encode(map[string]interface{}{"type": "consensus", "message": message}); err != nil {
+				node.report(fmt.Errorf("error sending consensus message to peer %s: %v", peer.ID, err))
+			}
+		}(peer)
+	}
+}
+
+// BroadcastEvidence sends evidence of validator misbehaviour to all peers.
+func (node *Node) BroadcastEvidence(evidence *blockchain.Evidence) {
+	for _, peer := range node.Peers {
+		go func(peer *Peer) {
+			conn, err := net.Dial("tcp", peer.Address)
+			if err != nil {
+				node.report(fmt.Errorf("error connecting to peer %s: %v", peer.ID, err))
+				return
+			}
+			defer conn.Close()
+
+			encoder := json.NewEncoder(conn)
+			if err := encoder.Encode(map[string]interface{}{"type": "evidence", "evidence": evidence}); err != nil {
+				node.report(fmt.Errorf("error sending evidence to peer %s: %v", peer.ID, err))
+			}
+		}(peer)
+	}
 }
 
 // Connect adds a peer to the node's list of connected peers.
@@ -149,7 +395,6 @@ func (node *Node) Connect(peerID, peerAddress string) {
 			ID:      peerID,
 			Address: peerAddress,
 		}
-		fmt.Printf("Node %s connected to peer %s at %s\n", node.ID, peerID, peerAddress)
 	}
 }
 
@@ -159,7 +404,7 @@ func (node *Node) ProposeVote(proposal *governance.Proposal) {
 		go func(peer *Peer) {
 			conn, err := net.Dial("tcp", peer.Address)
 			if err != nil {
-				fmt.Printf("Error connecting to peer %s: %v\n", peer.ID, err)
+				node.report(fmt.Errorf("error connecting to peer %s: %v", peer.ID, err))
 				return
 			}
 			defer conn.Close()
@@ -171,26 +416,29 @@ func (node *Node) ProposeVote(proposal *governance.Proposal) {
 
 			encoder := json.NewEncoder(conn)
 			if err := encoder.Encode(message); err != nil {
-				fmt.Printf("Error sending proposal to peer %s: %v\n", peer.ID, err)
-			} else {
-				fmt.Printf("Proposal sent to peer %s.\n", peer.ID)
+				node.report(fmt.Errorf("error sending proposal to peer %s: %v", peer.ID, err))
 			}
 		}(peer)
 	}
 }
 
+// FastSyncBlockchain brings a new node up to date from a peer's latest
+// snapshot instead of replaying the peer's whole chain.
+func (node *Node) FastSyncBlockchain(peerAddress string, synchronizer *Synchronizer) error {
+	if err := synchronizer.FastSync(node, peerAddress); err != nil {
+		return fmt.Errorf("error fast syncing blockchain with peer %s: %w", peerAddress, err)
+	}
+	return nil
+}
+
 // SyncBlockchain synchronizes the blockchain with a peer.
-func (node *Node) SyncBlockchain(peerAddress string, synchronizer *Synchronizer) {
+func (node *Node) SyncBlockchain(peerAddress string, synchronizer *Synchronizer) error {
 	newBlockchain, err := synchronizer.RequestBlockchain(peerAddress)
 	if err != nil {
-		fmt.Printf("Error syncing blockchain with peer %s: %v\n", peerAddress, err)
-		return
+		return fmt.Errorf("error syncing blockchain with peer %s: %w", peerAddress, err)
 	}
-
-	err = synchronizer.UpdateBlockchain(node, newBlockchain)
-	if err != nil {
-		fmt.Printf("Error updating blockchain: %v\n", err)
-	} else {
-		fmt.Printf("Node %s successfully synced blockchain from peer %s.\n", node.ID, peerAddress)
+	if err := synchronizer.UpdateBlockchain(node, newBlockchain); err != nil {
+		return fmt.Errorf("error updating blockchain: %w", err)
 	}
+	return nil
 }
@@ -0,0 +1,74 @@
+package network
+
+import (
+	"fmt"
+	"time"
+)
+
+// NodeMode selects how much chain history a node keeps.
+type NodeMode string
+
+// Node modes, from keeping everything to keeping only recent blocks in full.
+const (
+	ArchiveMode      NodeMode = "archive"       // Keeps every block and all undo data
+	FullMode         NodeMode = "full"          // Prunes undo data older than StateHistory blocks
+	PrunedBlocksMode NodeMode = "pruned-blocks" // Also keeps only headers beyond BlockHorizon blocks
+)
+
+// StorageConfig controls how a node prunes its chain history.
+type StorageConfig struct {
+	Mode         NodeMode      // History kept by the node
+	StateHistory int           // Blocks below the tip whose undo data is kept outside archive mode
+	BlockHorizon int           // Blocks below the tip whose bodies are kept in pruned-blocks mode
+	GCInterval   time.Duration // Time between garbage collection passes
+}
+
+// DefaultStorageConfig returns an archive configuration with the history
+// limits applied when switching to a pruning mode.
+func DefaultStorageConfig() StorageConfig {
+	return StorageConfig{
+		Mode:         ArchiveMode,
+		StateHistory: 128,
+		BlockHorizon: 1024,
+		GCInterval:   time.Minute,
+	}
+}
+
+// CollectGarbage runs one pruning pass according to the node's storage mode.
+// Pruning never reaches the blocks a reorganisation could still revert.
+func (node *Node) CollectGarbage() error {
+	config := node.Storage
+	tip := node.Blockchain.GetLatestBlock().Index
+
+	switch config.Mode {
+	case ArchiveMode:
+		return nil
+	case FullMode:
+		return node.Blockchain.Prune(tip-config.StateHistory, 0)
+	case PrunedBlocksMode:
+		return node.Blockchain.Prune(tip-config.StateHistory, tip-config.BlockHorizon)
+	default:
+		return fmt.Errorf("unknown node mode %q", config.Mode)
+	}
+}
+
+// RunGarbageCollector prunes the node's history every GCInterval until stop
+// is closed. Archive nodes return immediately.
+func (node *Node) RunGarbageCollector(stop <-chan struct{}) {
+	if node.Storage.Mode == ArchiveMode {
+		return
+	}
+	ticker := time.NewTicker(node.Storage.GCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := node.CollectGarbage(); err != nil {
+				node.report(fmt.Errorf("error collecting garbage: %w", err))
+			}
+		}
+	}
+}
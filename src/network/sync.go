@@ -3,11 +3,16 @@ package network
 import (
 	"BMT-Blockchain/src/blockchain"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
 )
 
+// MaxBlocksPerRequest is the largest number of blocks or headers a node sends
+// in reply to a single range request.
+const MaxBlocksPerRequest = 500
+
 // Synchronizer handles blockchain synchronization across nodes.
 type Synchronizer struct {
 	mutex sync.Mutex
@@ -38,23 +43,167 @@ func (s *Synchronizer) RequestBlockchain(peerAddress string) ([]*blockchain.Bloc
 	return blockchainData, nil
 }
 
-// UpdateBlockchain replaces the current blockchain with the received blockchain if valid.
-func (s *Synchronizer) UpdateBlockchain(node *Node, newBlockchain []*blockchain.Block) error {
+// request sends a message to a peer and decodes its reply into response.
+func (s *Synchronizer) request(peerAddress string, message map[string]interface{}, response interface{}) error {
+	conn, err := net.Dial("tcp", peerAddress)
+	if err != nil {
+		return fmt.Errorf("error connecting to peer: %v", err)
+	}
+	defer conn.Close()
+
+	encoder := json.NewEncoder(conn)
+	if err := encoder.Encode(message); err != nil {
+		return fmt.Errorf("error sending %s: %v", message["type"], err)
+	}
+
+	decoder := json.NewDecoder(conn)
+	if err := decoder.Decode(response); err != nil {
+		return fmt.Errorf("error decoding reply to %s: %v", message["type"], err)
+	}
+	return nil
+}
+
+// RequestSnapshotManifest requests the manifest of a peer's latest snapshot.
+func (s *Synchronizer) RequestSnapshotManifest(peerAddress string) (*blockchain.SnapshotManifest, error) {
+	var manifest blockchain.SnapshotManifest
+	message := map[string]interface{}{"type": "snapshot_manifest_request"}
+	if err := s.request(peerAddress, message, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// RequestSnapshotChunk requests one chunk of a snapshot from a peer and
+// checks it against the manifest.
+func (s *Synchronizer) RequestSnapshotChunk(peerAddress string, manifest *blockchain.SnapshotManifest, index int) (*blockchain.SnapshotChunk, error) {
+	var chunk blockchain.SnapshotChunk
+	message := map[string]interface{}{"type": "snapshot_chunk_request", "height": manifest.Height, "index": index}
+	if err := s.request(peerAddress, message, &chunk); err != nil {
+		return nil, err
+	}
+	if err := manifest.VerifyChunk(&chunk); err != nil {
+		return nil, err
+	}
+	return &chunk, nil
+}
+
+// RequestBlocks requests the canonical blocks from height from to height to
+// inclusive from a peer, in batches of at most MaxBlocksPerRequest. With
+// withBodies unset only the headers are requested. A negative to requests
+// every block up to the peer's tip.
+func (s *Synchronizer) RequestBlocks(peerAddress string, from, to int, withBodies bool) ([]*blockchain.Block, error) {
+	messageType := "headers_request"
+	if withBodies {
+		messageType = "blocks_request"
+	}
+
+	var blocks []*blockchain.Block
+	start := from
+	for to < 0 || from <= to {
+		last := from + MaxBlocksPerRequest - 1
+		if to >= 0 && last > to {
+			last = to
+		}
+		var batch []*blockchain.Block
+		message := map[string]interface{}{"type": messageType, "from": from, "to": last}
+		if err := s.request(peerAddress, message, &batch); err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		if batch[0].Index != from {
+			return nil, fmt.Errorf("requested blocks from %d, received blocks from %d", from, batch[0].Index)
+		}
+		blocks = append(blocks, batch...)
+		from += len(batch)
+	}
+	if to >= 0 && len(blocks) != to-start+1 {
+		return nil, fmt.Errorf("peer returned %d blocks from height %d, requested up to height %d", len(blocks), start, to)
+	}
+	return blocks, nil
+}
+
+// FastSync brings a node holding only the genesis block up to date from a
+// peer's latest snapshot. It downloads the snapshot chunk by chunk, the
+// headers up to the checkpoint and the bodies of the blocks just below it,
+// imports the snapshot once it matches the checkpoint's state root, and
+// then catches up on the blocks after the checkpoint. The node's chain must
+// trust the checkpoint or know the validators that certified it.
+func (s *Synchronizer) FastSync(node *Node, peerAddress string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if len(newBlockchain) <= len(node.Blockchain.Chain) {
-		return fmt.Errorf("received blockchain is not longer than the current blockchain")
+	manifest, err := s.RequestSnapshotManifest(peerAddress)
+	if err != nil {
+		return err
+	}
+	chunks := make([]*blockchain.SnapshotChunk, len(manifest.ChunkHashes))
+	for i := range chunks {
+		if chunks[i], err = s.RequestSnapshotChunk(peerAddress, manifest, i); err != nil {
+			return fmt.Errorf("error downloading snapshot chunk %d: %v", i, err)
+		}
+	}
+
+	// Retargeting and base fees need the bodies of the blocks below the checkpoint
+	bodiesFrom := manifest.Height - blockchain.SnapshotBodyWindow + 1
+	if bodiesFrom < 1 {
+		bodiesFrom = 1
+	}
+	headers, err := s.RequestBlocks(peerAddress, 1, bodiesFrom-1, false)
+	if err != nil {
+		return fmt.Errorf("error downloading headers: %v", err)
+	}
+	recent, err := s.RequestBlocks(peerAddress, bodiesFrom, manifest.Height, true)
+	if err != nil {
+		return fmt.Errorf("error downloading recent blocks: %v", err)
+	}
+	if err := node.Blockchain.ImportSnapshot(manifest, chunks, append(headers, recent...)); err != nil {
+		return fmt.Errorf("error importing snapshot: %v", err)
 	}
 
+	latest, err := s.RequestBlocks(peerAddress, manifest.Height+1, -1, true)
+	if err != nil {
+		return fmt.Errorf("error downloading blocks after the snapshot: %v", err)
+	}
+	for _, block := range latest {
+		if _, err := node.Blockchain.ProcessBlock(block); err != nil && !errors.Is(err, blockchain.ErrKnownBlock) {
+			return fmt.Errorf("error processing block %d: %v", block.Index, err)
+		}
+	}
+	node.Mempool.Prune()
+	return nil
+}
+
+// UpdateBlockchain feeds the blocks of the received blockchain into the node's
+// block tree. If the received branch is heavier, the node reorganises onto it
+// and the transactions of the detached blocks are returned to its mempool.
+func (s *Synchronizer) UpdateBlockchain(node *Node, newBlockchain []*blockchain.Block) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	// Validate the received blockchain
 	tempBlockchain := &blockchain.Blockchain{Chain: newBlockchain}
-	if !tempBlockchain.IsValid() {
+	if len(newBlockchain) == 0 || !tempBlockchain.IsValid() {
 		return fmt.Errorf("received blockchain is invalid")
 	}
+	if newBlockchain[0].Hash != node.Blockchain.Chain[0].Hash {
+		return fmt.Errorf("received blockchain has a different genesis block")
+	}
 
-	// Replace the current blockchain
-	node.Blockchain.Chain = newBlockchain
-	fmt.Printf("Node %s updated its blockchain with new data.\n", node.ID)
+	// Process the blocks the node does not know yet
+	for _, block := range newBlockchain[1:] {
+		reorg, err := node.Blockchain.ProcessBlock(block)
+		if errors.Is(err, blockchain.ErrKnownBlock) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error processing block %d: %v", block.Index, err)
+		}
+		if reorg != nil {
+			node.Mempool.ReturnTransactions(reorg.Detached)
+		}
+	}
+	node.Mempool.Prune()
 	return nil
 }
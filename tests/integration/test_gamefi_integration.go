@@ -2,6 +2,7 @@ package integration_test
 
 import (
 	"BMT-Blockchain/src/applications/gamefi"
+	"BMT-Blockchain/src/blockchain"
 	"testing"
 )
 
@@ -14,15 +15,18 @@ func TestGameFiIntegration(t *testing.T) {
 	if err != nil {
 		t.Errorf("Failed to mint NFT: %v", err)
 	}
+	if nft.Owner != "Alice" {
+		t.Errorf("Expected NFT owner to be Alice, got %s", nft.Owner)
+	}
 
 	// List NFT for Sale
-	err = marketplace.ListNFT("Alice", "NFT001", 100.0)
+	err = marketplace.ListNFT("Alice", "NFT001", 100*blockchain.OneBMT)
 	if err != nil {
 		t.Errorf("Failed to list NFT for sale: %v", err)
 	}
 
 	// Buy NFT
-	err = marketplace.BuyNFT("Bob", "NFT001", 100.0)
+	err = marketplace.BuyNFT("Bob", "NFT001", 100*blockchain.OneBMT)
 	if err != nil {
 		t.Errorf("Failed to buy NFT: %v", err)
 	}
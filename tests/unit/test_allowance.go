@@ -0,0 +1,116 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransferFromSpendsTheAllowance(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	pool, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+	if err := bc.AddTransactionWithTokenomics(blockchain.SystemAddress, pool.Address, blockchain.OneBMT); err != nil {
+		t.Fatalf("Failed to fund the pool: %v", err)
+	}
+	sub := bc.Events.Subscribe(blockchain.SubscribeOptions{Filter: blockchain.EventFilter{Types: []blockchain.EventType{blockchain.EventApproval}}})
+	defer sub.Unsubscribe()
+	commit := func(tx *blockchain.Transaction, err error) error {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Failed to sign transaction: %v", err)
+		}
+		return bc.AddTransactionBlock([]*blockchain.Transaction{tx})
+	}
+
+	early, err := blockchain.NewTransferFromTransaction(pool, alice.Address, "Bob", 10*blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)
+	if err := commit(early, err); !errors.Is(err, blockchain.ErrInsufficientAllowance) {
+		t.Errorf("Expected a transfer without an allowance to be rejected, got %v", err)
+	}
+	if err := commit(blockchain.NewApproveTransaction(alice, pool.Address, 30*blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)); err != nil {
+		t.Fatalf("Failed to approve: %v", err)
+	}
+	if err := commit(blockchain.NewIncreaseAllowanceTransaction(alice, pool.Address, 20*blockchain.OneBMT, cent, 1, bc.ChainID, 1700000000)); err != nil {
+		t.Fatalf("Failed to increase the allowance: %v", err)
+	}
+	if err := commit(blockchain.NewDecreaseAllowanceTransaction(alice, pool.Address, 60*blockchain.OneBMT, cent, 2, bc.ChainID, 1700000000)); !errors.Is(err, blockchain.ErrInsufficientAllowance) {
+		t.Errorf("Expected decreasing below zero to be rejected, got %v", err)
+	}
+
+	balance := bc.Tokenomics.GetBalance(alice.Address)
+	if err := commit(blockchain.NewTransferFromTransaction(pool, alice.Address, "Bob", 40*blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)); err != nil {
+		t.Fatalf("Failed to transfer from the allowance: %v", err)
+	}
+	if got := bc.Tokenomics.GetBalance("Bob"); got != 40*blockchain.OneBMT {
+		t.Errorf("Expected Bob to receive 40 BMT, got %s", got)
+	}
+	if got := bc.Tokenomics.GetBalance(alice.Address); got != balance-40*blockchain.OneBMT {
+		t.Errorf("Expected the owner to pay the amount but not the fee, got %s", got)
+	}
+	if allowance := bc.GetAllowance(alice.Address, pool.Address); allowance != 10*blockchain.OneBMT {
+		t.Errorf("Expected 10 BMT of allowance left, got %s", allowance)
+	}
+	if err := commit(blockchain.NewTransferFromTransaction(pool, alice.Address, "Bob", 11*blockchain.OneBMT, cent, 1, bc.ChainID, 1700000000)); !errors.Is(err, blockchain.ErrInsufficientAllowance) {
+		t.Errorf("Expected a transfer beyond the allowance to be rejected, got %v", err)
+	}
+
+	for i, expected := range []blockchain.Amount{30 * blockchain.OneBMT, 20 * blockchain.OneBMT} {
+		event := nextEvent(t, sub)
+		if event.From != alice.Address || event.To != pool.Address || event.Amount != expected || event.Height != 3+i {
+			t.Errorf("Expected an approval of %s at height %d, got %+v", expected, 3+i, event)
+		}
+	}
+}
+
+func TestPermitApprovesWithTheOwnersSignature(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	relayer, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, relayer, 10*blockchain.OneBMT)
+	deadline := time.Now().Add(time.Hour).Unix()
+	submit := func(permit *blockchain.Permit) error {
+		t.Helper()
+		tx, err := blockchain.NewPermitTransaction(relayer, permit, cent, bc.GetNonce(relayer.Address), bc.ChainID, 1700000000)
+		if err != nil {
+			t.Fatalf("Failed to sign transaction: %v", err)
+		}
+		return bc.AddTransactionBlock([]*blockchain.Transaction{tx})
+	}
+	sign := func(amount blockchain.Amount, nonce uint64, deadline int64, chainID string) *blockchain.Permit {
+		t.Helper()
+		permit, err := blockchain.NewPermit(alice, "Market", amount, nonce, deadline, chainID)
+		if err != nil {
+			t.Fatalf("Failed to sign permit: %v", err)
+		}
+		return permit
+	}
+
+	if err := submit(sign(25, 0, deadline, "other-chain")); !errors.Is(err, blockchain.ErrInvalidPermit) {
+		t.Errorf("Expected a permit for another chain to be rejected, got %v", err)
+	}
+	if err := submit(sign(25, 0, 1000, bc.ChainID)); !errors.Is(err, blockchain.ErrInvalidPermit) {
+		t.Errorf("Expected an expired permit to be rejected, got %v", err)
+	}
+	if err := submit(sign(25, 1, deadline, bc.ChainID)); !errors.Is(err, blockchain.ErrInvalidPermit) {
+		t.Errorf("Expected a permit with a future nonce to be rejected, got %v", err)
+	}
+	permit := sign(25, 0, deadline, bc.ChainID)
+	forged := *permit
+	forged.Amount = 1000
+	if err := submit(&forged); !errors.Is(err, blockchain.ErrInvalidPermit) {
+		t.Errorf("Expected a tampered permit to be rejected, got %v", err)
+	}
+
+	if err := submit(permit); err != nil {
+		t.Fatalf("Failed to apply permit: %v", err)
+	}
+	if allowance := bc.GetAllowance(alice.Address, "Market"); allowance != 25 {
+		t.Errorf("Expected an allowance of 25, got %d", allowance)
+	}
+	if nonce := bc.GetPermitNonce(alice.Address); nonce != 1 {
+		t.Errorf("Expected the next permit nonce to be 1, got %d", nonce)
+	}
+	if err := submit(permit); !errors.Is(err, blockchain.ErrInvalidPermit) {
+		t.Errorf("Expected a replayed permit to be rejected, got %v", err)
+	}
+}
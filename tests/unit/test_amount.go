@@ -0,0 +1,68 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseAndFormatAmount(t *testing.T) {
+	cases := map[string]blockchain.Amount{
+		"0.0000001": blockchain.BaseUnit,
+		"1":         blockchain.OneBMT,
+		"12.5":      125_000_000,
+		"-0.25":     -2_500_000,
+	}
+	for text, want := range cases {
+		got, err := blockchain.ParseAmount(text)
+		if err != nil || got != want {
+			t.Errorf("ParseAmount(%q) = %d, %v; want %d", text, got, err, want)
+		}
+	}
+
+	if blockchain.Amount(125_000_000).String() != "12.5000000" {
+		t.Errorf("Unexpected formatting: %s", blockchain.Amount(125_000_000))
+	}
+	if _, err := blockchain.ParseAmount("0.00000001"); !errors.Is(err, blockchain.ErrInvalidAmount) {
+		t.Errorf("Expected sub-unit precision to be rejected, got %v", err)
+	}
+}
+
+func TestAmountJSONRoundTrip(t *testing.T) {
+	var decoded struct{ Amount blockchain.Amount }
+	if err := json.Unmarshal([]byte(`{"Amount": 0.1}`), &decoded); err != nil || decoded.Amount != blockchain.OneBMT/10 {
+		t.Fatalf("Failed to decode JSON number: %v, %d", err, decoded.Amount)
+	}
+
+	data, _ := json.Marshal(decoded)
+	if string(data) != `{"Amount":"0.1000000"}` {
+		t.Errorf("Unexpected JSON encoding: %s", data)
+	}
+}
+
+func TestCheckedAmountArithmetic(t *testing.T) {
+	if _, err := blockchain.Amount(math.MaxInt64).Add(1); !errors.Is(err, blockchain.ErrAmountOverflow) {
+		t.Errorf("Expected overflow, got %v", err)
+	}
+	if _, err := blockchain.OneBMT.Sub(2 * blockchain.OneBMT); !errors.Is(err, blockchain.ErrNegativeAmount) {
+		t.Errorf("Expected negative result to be rejected, got %v", err)
+	}
+	if _, err := blockchain.Amount(math.MaxInt64).Sub(-1); !errors.Is(err, blockchain.ErrAmountOverflow) {
+		t.Errorf("Expected overflow, got %v", err)
+	}
+	if _, err := blockchain.OneBMT.Div(0); !errors.Is(err, blockchain.ErrDivisionByZero) {
+		t.Errorf("Expected division by zero, got %v", err)
+	}
+
+	// 0.1 + 0.2 must be exactly 0.3, unlike float64
+	sum, _ := blockchain.Amount(1_000_000).Add(2_000_000)
+	if sum.String() != "0.3000000" {
+		t.Errorf("Expected 0.3000000, got %s", sum)
+	}
+	third, _ := blockchain.OneBMT.MulDiv(1, 3)
+	if third != 3_333_333 {
+		t.Errorf("Expected division to round toward zero, got %d", third)
+	}
+}
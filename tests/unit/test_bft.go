@@ -0,0 +1,212 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+	"time"
+)
+
+// bftNetwork is a set of validators exchanging consensus messages in process.
+type bftNetwork struct {
+	validators *blockchain.ValidatorSet
+	wallets    map[string]*blockchain.Wallet
+	chains     map[string]*blockchain.Blockchain
+	engines    map[string]*blockchain.BFTConsensus
+}
+
+// newBFTNetwork creates validators with equal stake, each with its own chain
+// and consensus engine. Nothing runs until the engines are started.
+func newBFTNetwork(t *testing.T, size int) *bftNetwork {
+	network := &bftNetwork{
+		wallets: make(map[string]*blockchain.Wallet),
+		chains:  make(map[string]*blockchain.Blockchain),
+		engines: make(map[string]*blockchain.BFTConsensus),
+	}
+	var validators []blockchain.Validator
+	for i := 0; i < size; i++ {
+		wallet, _ := blockchain.NewWallet()
+		network.wallets[wallet.Address] = wallet
+		validators = append(validators, blockchain.Validator{Address: wallet.Address, PublicKey: wallet.PublicKey, Stake: 1000 * blockchain.OneBMT})
+	}
+	set, err := blockchain.NewValidatorSet(validators)
+	if err != nil {
+		t.Fatalf("Failed to create validator set: %v", err)
+	}
+	network.validators = set
+
+	config := blockchain.BFTConfig{
+		ProposeTimeout:   100 * time.Millisecond,
+		PrevoteTimeout:   50 * time.Millisecond,
+		PrecommitTimeout: 50 * time.Millisecond,
+		TimeoutDelta:     20 * time.Millisecond,
+	}
+	done := make(chan struct{})
+	inboxes := make(map[string]chan blockchain.ConsensusMessage)
+	for address, wallet := range network.wallets {
+		chain := blockchain.NewBlockchain()
+		chain.Validators = set
+		engine, err := blockchain.NewBFTConsensus(chain, wallet, config)
+		if err != nil {
+			t.Fatalf("Failed to create consensus: %v", err)
+		}
+		network.chains[address] = chain
+		network.engines[address] = engine
+		inbox := make(chan blockchain.ConsensusMessage, 1024)
+		inboxes[address] = inbox
+		go func() {
+			for {
+				select {
+				case message := <-inbox:
+					engine.HandleMessage(message)
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	for address, engine := range network.engines {
+		sender := address
+		engine.Broadcast = func(message blockchain.ConsensusMessage) {
+			for address, inbox := range inboxes {
+				if address == sender {
+					continue
+				}
+				select {
+				case inbox <- message:
+				case <-done:
+				}
+			}
+		}
+	}
+	t.Cleanup(func() {
+		for _, engine := range network.engines {
+			engine.Stop()
+		}
+		close(done)
+	})
+	return network
+}
+
+// commitUpTo waits until every engine in the list has committed a height,
+// then stops them so their chains can be inspected.
+func commitUpTo(t *testing.T, engines []*blockchain.BFTConsensus, height int) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for _, engine := range engines {
+		for {
+			current, _, _ := engine.State()
+			if current > height {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for height %d, consensus is at %d", height, current)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	for _, engine := range engines {
+		engine.Stop()
+	}
+}
+
+func TestBFTCommitsBlocksWithCertificates(t *testing.T) {
+	network := newBFTNetwork(t, 4)
+	var chains []*blockchain.Blockchain
+	var engines []*blockchain.BFTConsensus
+	for address, engine := range network.engines {
+		chains = append(chains, network.chains[address])
+		engines = append(engines, engine)
+		engine.Start()
+	}
+	commitUpTo(t, engines, 3)
+
+	for height := 1; height <= 3; height++ {
+		block := chains[0].Chain[height]
+		for _, chain := range chains[1:] {
+			if chain.Chain[height].Hash != block.Hash {
+				t.Fatalf("Validators committed different blocks at height %d", height)
+			}
+		}
+		if block.Commit == nil || block.Commit.Verify(chains[0].ChainID, network.validators) != nil {
+			t.Fatalf("Expected block %d to carry a valid commit certificate", height)
+		}
+		if block.Proposer != network.validators.Proposer(height, block.Commit.Round).Address {
+			t.Errorf("Expected block %d to credit the proposer of its round", height)
+		}
+	}
+
+	// Peers with a validator set only accept certified blocks
+	follower := blockchain.NewBlockchain()
+	follower.Validators = network.validators
+	uncertified := *chains[0].Chain[1]
+	uncertified.Commit = nil
+	if _, err := follower.ProcessBlock(&uncertified); !errors.Is(err, blockchain.ErrMissingCommit) {
+		t.Errorf("Expected a block without a certificate to be rejected, got %v", err)
+	}
+	if _, err := follower.ProcessBlock(chains[0].Chain[1]); err != nil {
+		t.Errorf("Expected a certified block to be accepted, got %v", err)
+	}
+}
+
+func TestBFTChangesRoundWhenProposerIsOffline(t *testing.T) {
+	network := newBFTNetwork(t, 4)
+	offline := network.validators.Proposer(1, 0).Address
+
+	var chains []*blockchain.Blockchain
+	var engines []*blockchain.BFTConsensus
+	for address, engine := range network.engines {
+		if address == offline {
+			continue
+		}
+		chains = append(chains, network.chains[address])
+		engines = append(engines, engine)
+		engine.Start()
+	}
+	commitUpTo(t, engines, 1)
+
+	block := chains[0].Chain[1]
+	if block.Commit.Round == 0 || block.Proposer == offline {
+		t.Errorf("Expected block 1 to be committed in a later round, got round %d", block.Commit.Round)
+	}
+	for _, vote := range block.Commit.Precommits {
+		if vote.Validator == offline {
+			t.Error("Expected no precommit from the offline validator")
+		}
+	}
+}
+
+func TestCommitCertificateRequiresQuorum(t *testing.T) {
+	network := newBFTNetwork(t, 4)
+	var precommits []blockchain.ConsensusVote
+	for _, wallet := range network.wallets {
+		vote, err := blockchain.NewConsensusVote(wallet, blockchain.DefaultChainID, blockchain.Precommit, 1, 0, "block")
+		if err != nil {
+			t.Fatalf("Failed to sign vote: %v", err)
+		}
+		precommits = append(precommits, *vote)
+	}
+
+	commit := &blockchain.CommitCertificate{Height: 1, BlockHash: "block", Precommits: precommits[:3]}
+	if err := commit.Verify(blockchain.DefaultChainID, network.validators); err != nil {
+		t.Errorf("Expected three of four equal validators to be a quorum, got %v", err)
+	}
+	commit.Precommits = precommits[:2]
+	if err := commit.Verify(blockchain.DefaultChainID, network.validators); !errors.Is(err, blockchain.ErrNoQuorum) {
+		t.Errorf("Expected half of the stake to fall short of a quorum, got %v", err)
+	}
+	commit.Precommits = []blockchain.ConsensusVote{precommits[0], precommits[0], precommits[1]}
+	if err := commit.Verify(blockchain.DefaultChainID, network.validators); !errors.Is(err, blockchain.ErrInvalidVote) {
+		t.Errorf("Expected a repeated precommit to be rejected, got %v", err)
+	}
+	if err := commit.Verify("other-chain", network.validators); !errors.Is(err, blockchain.ErrInvalidVote) {
+		t.Errorf("Expected precommits signed for another chain to be rejected, got %v", err)
+	}
+
+	outsider, _ := blockchain.NewWallet()
+	vote, _ := blockchain.NewConsensusVote(outsider, blockchain.DefaultChainID, blockchain.Precommit, 1, 0, "block")
+	commit.Precommits = append(precommits[:2:2], *vote)
+	if err := commit.Verify(blockchain.DefaultChainID, network.validators); !errors.Is(err, blockchain.ErrUnknownValidator) {
+		t.Errorf("Expected a precommit from outside the set to be rejected, got %v", err)
+	}
+}
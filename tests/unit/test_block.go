@@ -6,14 +6,40 @@ import (
 )
 
 func TestBlockCreation(t *testing.T) {
-	block := blockchain.NewBlock(1, []string{"Transaction 1", "Transaction 2"}, "0000")
+	tx1, _ := blockchain.NewTransaction("Alice", "Bob", 10, 1700000000)
+	tx2, _ := blockchain.NewTransaction("Bob", "Carol", 5, 1700000001)
+	block := blockchain.NewBlock(1, []blockchain.Transaction{*tx1, *tx2}, "0000")
 	if block.Index != 1 {
 		t.Errorf("Expected block index 1, got %d", block.Index)
 	}
-	if len(block.Transactions) != 2 {
-		t.Errorf("Expected 2 transactions, got %d", len(block.Transactions))
+	if len(block.Transactions()) != 2 {
+		t.Errorf("Expected 2 transactions, got %d", len(block.Transactions()))
 	}
 	if block.PreviousHash != "0000" {
 		t.Errorf("Expected previous hash '0000', got %s", block.PreviousHash)
 	}
 }
+
+func TestBlockCommitsToTieredBody(t *testing.T) {
+	tx, _ := blockchain.NewTransaction("Alice", "Bob", 1, 1700000000)
+	block := blockchain.NewBlock(1, []blockchain.Transaction{*tx}, "0000")
+	if len(block.SubBlocks) != 1 || len(block.SubBlocks[0].MiniBlocks) != 1 {
+		t.Fatalf("Expected one sub-block with one mini-block, got %+v", block.SubBlocks)
+	}
+	if block.MerkleRoot != block.CalculateMerkleRoot() {
+		t.Error("Header Merkle root does not match body")
+	}
+
+	block.SubBlocks[0].MiniBlocks[0].Transactions[0].Amount = 2
+	if block.MerkleRoot == block.CalculateMerkleRoot() {
+		t.Error("Expected tampering with a mini-block transaction to change the Merkle root")
+	}
+}
+
+func TestGenesisBlockIsDeterministic(t *testing.T) {
+	first := blockchain.NewBlockchain().GetLatestBlock()
+	second := blockchain.NewBlockchain().GetLatestBlock()
+	if first.Index != 0 || first.Hash != second.Hash {
+		t.Errorf("Expected identical genesis blocks, got %s and %s", first.Hash, second.Hash)
+	}
+}
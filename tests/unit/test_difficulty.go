@@ -0,0 +1,105 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMiniBlocksCarryProofOfWork(t *testing.T) {
+	tx, _ := blockchain.NewTransaction("Alice", "Bob", blockchain.OneBMT, 1700000000)
+	block := blockchain.NewBlock(1, []blockchain.Transaction{*tx}, "")
+
+	if block.Difficulty != blockchain.InitialDifficulty {
+		t.Fatalf("Expected difficulty %d, got %d", blockchain.InitialDifficulty, block.Difficulty)
+	}
+	mini := block.SubBlocks[0].MiniBlocks[0]
+	if !strings.HasPrefix(mini.Hash, "00") {
+		t.Errorf("Expected mini-block hash with 8 leading zero bits, got %s", mini.Hash)
+	}
+}
+
+func TestDifficultyRetargetsAndIsVerified(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+
+	// Mining at 8 bits is far faster than the target, so difficulty rises
+	if difficulty := bc.GetLatestBlock().Difficulty; difficulty != blockchain.InitialDifficulty+1 {
+		t.Errorf("Expected difficulty to rise to %d, got %d", blockchain.InitialDifficulty+1, difficulty)
+	}
+
+	peer := blockchain.NewBlockchain()
+	if _, err := peer.ProcessBlock(bc.Chain[1]); err != nil {
+		t.Fatalf("Expected valid block to be accepted: %v", err)
+	}
+
+	tampered := *bc.Chain[2]
+	tampered.SubBlocks = append([]blockchain.SubBlock(nil), tampered.SubBlocks...)
+	tampered.SubBlocks[0].MiniBlocks = append([]blockchain.MiniBlock(nil), tampered.SubBlocks[0].MiniBlocks...)
+	tampered.SubBlocks[0].MiniBlocks[0].Nonce++
+	if _, err := peer.ProcessBlock(&tampered); err == nil {
+		t.Error("Expected a block with an invalid nonce to be rejected")
+	}
+
+	easier := *bc.Chain[2]
+	easier.Difficulty--
+	easier.Hash = easier.CalculateHash()
+	if _, err := peer.ProcessBlock(&easier); err == nil {
+		t.Error("Expected a block with the wrong difficulty to be rejected")
+	}
+}
+
+func TestMiniBlockWorkIsBoundToTheParent(t *testing.T) {
+	source := blockchain.NewBlockchain()
+	source.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+	source.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", 2*blockchain.OneBMT)
+	target := blockchain.NewBlockchain()
+	target.AddTransactionWithTokenomics(blockchain.SystemAddress, "Bobby", blockchain.OneBMT)
+
+	// The work of block 2 was done on the source's block 1
+	moved := *source.Chain[2]
+	moved.PreviousHash = target.Chain[1].Hash
+	moved.Hash = moved.CalculateHash()
+	if err := target.ValidateBlock(&moved); !errors.Is(err, blockchain.ErrProofOfWork) {
+		t.Errorf("Expected mini-blocks mined on another parent to be rejected, got %v", err)
+	}
+
+	// Digits moved from the index into the timestamp give another header
+	shifted := *source.Chain[1]
+	shifted.Index, shifted.Timestamp = 11, source.Chain[1].Timestamp%1_000_000_000
+	if shifted.CalculateHash() == source.Chain[1].Hash {
+		t.Error("Expected a re-encoded header to hash differently")
+	}
+}
+
+func TestSolveTimesAreCommittedAndBoundedByTimestamps(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+	peer := blockchain.NewBlockchain()
+	tamper := func(solveTime int64) *blockchain.Block {
+		block := *bc.Chain[1]
+		block.SubBlocks = append([]blockchain.SubBlock(nil), block.SubBlocks...)
+		block.SubBlocks[0].MiniBlocks = append([]blockchain.MiniBlock(nil), block.SubBlocks[0].MiniBlocks...)
+		block.SubBlocks[0].MiniBlocks[0].SolveTime = solveTime
+		return &block
+	}
+
+	// A relayed copy with another solve time keeps the hash but not the header sum
+	if _, err := peer.ProcessBlock(tamper(bc.Chain[1].SolveTime + 1)); !errors.Is(err, blockchain.ErrProofOfWork) {
+		t.Errorf("Expected a block with a changed solve time to be rejected, got %v", err)
+	}
+
+	// A miner cannot claim more time than passed since the parent block
+	inflated := tamper(1 << 62)
+	inflated.SolveTime = 1 << 62
+	inflated.Hash = inflated.CalculateHash()
+	if _, err := peer.ProcessBlock(inflated); !errors.Is(err, blockchain.ErrProofOfWork) {
+		t.Errorf("Expected an inflated solve time to be rejected, got %v", err)
+	}
+
+	if _, err := peer.ProcessBlock(bc.Chain[1]); err != nil {
+		t.Errorf("Expected the original block to be accepted, got %v", err)
+	}
+}
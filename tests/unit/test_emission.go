@@ -0,0 +1,150 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// populationGrowth returns an emission schedule of ten-block years that
+// issues one BMT per person the population grows by from 2024 to 2025.
+func populationGrowth(growth int64) blockchain.EmissionConfig {
+	return blockchain.EmissionConfig{
+		Population:     []blockchain.PopulationRecord{{Year: 2024, Population: 1000}, {Year: 2025, Population: 1000 + growth}},
+		GenesisYear:    2025,
+		BlocksPerYear:  10,
+		CoinsPerPerson: blockchain.OneBMT,
+		ProposerShare:  0.2,
+	}
+}
+
+func TestEmissionFollowsPopulationGrowth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "population.csv")
+	data := "year,population\n# United Nations estimates\n2024,100\n2025,130\n2026,120\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to write population file: %v", err)
+	}
+	records, err := blockchain.LoadPopulationFile(path)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("Failed to load population file: %v, %+v", err, records)
+	}
+	oracle := blockchain.NewOracleSystem()
+	for _, record := range []blockchain.PopulationRecord{records[2], records[0], records[1]} {
+		oracle.ReportPopulation(record.Year, record.Population)
+	}
+	if series := oracle.PopulationSeries(); len(series) != 3 || series[0] != records[0] || series[2] != records[2] {
+		t.Errorf("Expected the oracle feed to match the file, got %+v", series)
+	}
+
+	config := blockchain.EmissionConfig{Population: records, GenesisYear: 2025, BlocksPerYear: 4, CoinsPerPerson: blockchain.BaseUnit}
+	for year, expected := range map[int]blockchain.Amount{2025: 30, 2026: 0, 2027: 0} {
+		if emission, _ := config.YearlyEmission(year); emission != expected {
+			t.Errorf("Expected %d to issue %s, got %s", year, expected, emission)
+		}
+	}
+	var total blockchain.Amount
+	for height, expected := range []blockchain.Amount{0, 8, 8, 7, 7, 0} {
+		reward, _ := config.BlockReward(height)
+		if reward != expected {
+			t.Errorf("Expected block %d to issue %d base units, got %d", height, expected, reward)
+		}
+		total += reward
+	}
+	if total != 30 || config.Year(5) != 2026 {
+		t.Errorf("Expected the year's blocks to issue its whole emission, got %d", total)
+	}
+
+	os.WriteFile(path, []byte("2025,130\n2024,100\n"), 0o644)
+	if _, err := blockchain.LoadPopulationFile(path); err == nil {
+		t.Error("Expected an unordered population series to be rejected")
+	}
+}
+
+func TestBlockRewardsGoToProposerAndStakers(t *testing.T) {
+	bc, wallets := newStakingChain(t, 100*blockchain.OneBMT, 101*blockchain.OneBMT)
+	alice, bob := wallets[0], wallets[1]
+	err := bc.AddTransactionBlock([]*blockchain.Transaction{
+		stakingTx(t, bc, alice, blockchain.BondAddress, 30*blockchain.OneBMT),
+		stakingTx(t, bc, bob, blockchain.BondAddress, 10*blockchain.OneBMT),
+	})
+	if err != nil {
+		t.Fatalf("Failed to bond: %v", err)
+	}
+	bc.Emission = populationGrowth(100)
+
+	// Without validators the proposer receives the whole reward
+	system := bc.Tokenomics.GetBalance(blockchain.SystemAddress)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Dave", blockchain.OneBMT)
+	if balance := bc.Tokenomics.GetBalance(blockchain.SystemAddress); balance != system+9*blockchain.OneBMT {
+		t.Errorf("Expected the proposer to receive the 10 BMT reward, got %s from %s", balance, system)
+	}
+
+	sub := bc.Events.Subscribe(blockchain.SubscribeOptions{Filter: blockchain.EventFilter{Types: []blockchain.EventType{blockchain.EventMint}}})
+	defer sub.Unsubscribe()
+	supply := bc.Tokenomics.TotalSupply
+	aliceBalance, bobBalance := bc.Tokenomics.GetBalance(alice.Address), bc.Tokenomics.GetBalance(bob.Address)
+	bc.Coinbase = alice.Address
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Dave", 2*blockchain.OneBMT)
+
+	// Alice proposes for 2 BMT and shares the other 8 BMT with Bob by stake
+	if balance := bc.Tokenomics.GetBalance(alice.Address); balance != aliceBalance+8*blockchain.OneBMT {
+		t.Errorf("Expected alice to receive 8 BMT, got %s from %s", balance, aliceBalance)
+	}
+	if balance := bc.Tokenomics.GetBalance(bob.Address); balance != bobBalance+2*blockchain.OneBMT {
+		t.Errorf("Expected bob to receive 2 BMT, got %s from %s", balance, bobBalance)
+	}
+	if bc.Tokenomics.TotalSupply != supply+10*blockchain.OneBMT {
+		t.Errorf("Expected the reward to add to the supply, got %s from %s", bc.Tokenomics.TotalSupply, supply)
+	}
+	minted := make(map[string]blockchain.Amount)
+	for i := 0; i < 2; i++ {
+		event := nextEvent(t, sub)
+		if event.Height != 5 {
+			t.Errorf("Expected mints in block 5, got height %d", event.Height)
+		}
+		minted[event.To] = event.Amount
+	}
+	if minted[alice.Address] != 8*blockchain.OneBMT || minted[bob.Address] != 2*blockchain.OneBMT {
+		t.Errorf("Unexpected mint events: %+v", minted)
+	}
+
+	// A replay of the block delivers the same mints
+	replay, err := bc.Subscribe(blockchain.SubscribeOptions{
+		Filter:     blockchain.EventFilter{Types: []blockchain.EventType{blockchain.EventMint}},
+		Replay:     true,
+		FromHeight: 5,
+	})
+	if err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	defer replay.Unsubscribe()
+	for i := 0; i < 2; i++ {
+		event := nextEvent(t, replay)
+		if event.Height != 5 || minted[event.To] != event.Amount {
+			t.Errorf("Expected the replayed mints to match the live ones, got %+v", event)
+		}
+	}
+	if err := bc.ValidateChain(); err != nil {
+		t.Errorf("Expected the chain to stay valid, got %v", err)
+	}
+}
+
+func TestEmissionStopsAtTheSupplyCap(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	if bc.Tokenomics.MaxSupply != blockchain.MaxTotalSupply {
+		t.Fatalf("Expected the supply to be capped at %s, got %s", blockchain.MaxTotalSupply, bc.Tokenomics.MaxSupply)
+	}
+	bc.Tokenomics.MaxSupply = blockchain.InitialSupply + 15*blockchain.OneBMT
+	bc.Emission = populationGrowth(100)
+
+	for i, expected := range []blockchain.Amount{10, 15, 15} {
+		bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.Amount(i+1)*blockchain.OneBMT)
+		if bc.Tokenomics.TotalSupply != blockchain.InitialSupply+expected*blockchain.OneBMT {
+			t.Errorf("Expected %d BMT emitted after block %d, got supply %s", expected, i+1, bc.Tokenomics.TotalSupply)
+		}
+	}
+	if err := bc.Tokenomics.MintCoins("Alice", blockchain.BaseUnit); err == nil {
+		t.Error("Expected minting beyond the cap to fail")
+	}
+}
@@ -0,0 +1,128 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"testing"
+	"time"
+)
+
+// nextEvent waits for the next event of a subscription.
+func nextEvent(t *testing.T, sub *blockchain.Subscription) blockchain.Event {
+	t.Helper()
+	select {
+	case event, ok := <-sub.Events:
+		if !ok {
+			t.Fatal("Subscription closed unexpectedly")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for an event")
+	}
+	return blockchain.Event{}
+}
+
+func TestSubscribeReplaysThenFollowsBlocks(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+
+	sub, err := bc.Subscribe(blockchain.SubscribeOptions{
+		Filter:     blockchain.EventFilter{Types: []blockchain.EventType{blockchain.EventNewBlock}},
+		Replay:     true,
+		FromHeight: 0,
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Bob", blockchain.OneBMT)
+
+	for height := 0; height <= 2; height++ {
+		if event := nextEvent(t, sub); event.Type != blockchain.EventNewBlock || event.Height != height {
+			t.Fatalf("Expected block %d, got %s at %d", height, event.Type, event.Height)
+		}
+	}
+	if _, err := bc.Subscribe(blockchain.SubscribeOptions{Replay: true, FromHeight: 5}); err == nil {
+		t.Error("Expected replay above the tip to be rejected")
+	}
+}
+
+func TestAddressFilterAndBridgeEvents(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	bridge := blockchain.NewCrossChainBridge()
+	bridge.Events = bc.Events
+
+	sub := bc.Events.Subscribe(blockchain.SubscribeOptions{Filter: blockchain.EventFilter{Address: "Alice"}})
+	defer sub.Unsubscribe()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Bob", blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", 2*blockchain.OneBMT)
+	bridge.LockTokens("Alice", blockchain.OneBMT)
+	bridge.MintTokens("Alice", blockchain.OneBMT)
+
+	included := nextEvent(t, sub)
+	if included.Type != blockchain.EventTxIncluded || included.Height != 2 {
+		t.Errorf("Expected Alice's transaction in block 2, got %s at %d", included.Type, included.Height)
+	}
+	transfer := nextEvent(t, sub)
+	if transfer.Type != blockchain.EventTransfer || transfer.To != "Alice" || transfer.Amount != 2*blockchain.OneBMT {
+		t.Errorf("Unexpected transfer event: %+v", transfer)
+	}
+	if lock := nextEvent(t, sub); lock.Type != blockchain.EventBridgeLock || lock.Height != -1 {
+		t.Errorf("Expected a bridge lock outside blocks, got %s at %d", lock.Type, lock.Height)
+	}
+	if mint := nextEvent(t, sub); mint.Type != blockchain.EventBridgeMint || mint.To != "Alice" {
+		t.Errorf("Expected a bridge mint on the destination chain, got %+v", mint)
+	}
+}
+
+func TestReorgEventPrecedesAttachedBlocks(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	peer := blockchain.NewBlockchain()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+	peer.AddTransactionWithTokenomics(blockchain.SystemAddress, "Carol", blockchain.OneBMT)
+	peer.AddTransactionWithTokenomics(blockchain.SystemAddress, "Carol", 2*blockchain.OneBMT)
+
+	sub := bc.Events.Subscribe(blockchain.SubscribeOptions{
+		Filter: blockchain.EventFilter{Types: []blockchain.EventType{blockchain.EventReorg, blockchain.EventNewBlock}},
+	})
+	defer sub.Unsubscribe()
+	bc.ProcessBlock(peer.Chain[1])
+	if _, err := bc.ProcessBlock(peer.Chain[2]); err != nil {
+		t.Fatalf("Failed to reorganise: %v", err)
+	}
+
+	reorg := nextEvent(t, sub)
+	if reorg.Type != blockchain.EventReorg || len(reorg.Reorg.Detached) != 1 || reorg.Height != 2 {
+		t.Fatalf("Expected a reorg detaching one block, got %+v", reorg)
+	}
+	for _, block := range peer.Chain[1:] {
+		if event := nextEvent(t, sub); event.Type != blockchain.EventNewBlock || event.BlockHash != block.Hash {
+			t.Errorf("Expected attached block %d, got %s %s", block.Index, event.Type, event.BlockHash)
+		}
+	}
+}
+
+func TestSlowSubscribersDoNotBlockPublishing(t *testing.T) {
+	bus := blockchain.NewEventBus()
+	dropping := bus.Subscribe(blockchain.SubscribeOptions{BufferSize: 1})
+	defer dropping.Unsubscribe()
+	closing := bus.Subscribe(blockchain.SubscribeOptions{BufferSize: 1, Policy: blockchain.CloseOnFull})
+
+	for i := 0; i < 5; i++ {
+		bus.Publish(blockchain.Event{Type: blockchain.EventMint, Height: -1, To: "Alice", Amount: blockchain.OneBMT})
+	}
+	if dropping.Dropped() == 0 {
+		t.Error("Expected events to be dropped for a full buffer")
+	}
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-closing.Events:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Expected the subscription to be closed when its buffer filled")
+		}
+	}
+}
@@ -2,6 +2,7 @@ package defi_test
 
 import (
 	"BMT-Blockchain/src/applications/defi"
+	"BMT-Blockchain/src/blockchain"
 	"testing"
 	"time"
 )
@@ -10,12 +11,12 @@ func TestFarmingPool(t *testing.T) {
 	pool := defi.NewFarmingPool(0.01) // Reward rate: 0.01 BMT/second
 
 	// Stake
-	err := pool.Stake("Alice", 1000)
+	err := pool.Stake("Alice", 1000*blockchain.OneBMT)
 	if err != nil {
 		t.Errorf("Failed to stake: %v", err)
 	}
-	if pool.GetUserStake("Alice") != 1000 {
-		t.Errorf("Expected Alice's stake to be 1000, got %d", pool.GetUserStake("Alice"))
+	if pool.GetUserStake("Alice") != 1000*blockchain.OneBMT {
+		t.Errorf("Expected Alice's stake to be 1000 BMT, got %s", pool.GetUserStake("Alice"))
 	}
 
 	// Wait for rewards
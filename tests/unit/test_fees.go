@@ -0,0 +1,67 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+)
+
+func TestFeesBurnBaseFeeAndTipProposer(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+	bc.Coinbase = "Proposer"
+
+	tx, _ := blockchain.NewSignedTransaction(alice, "Bob", blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)
+	burned, err := blockchain.RequiredFee(tx, bc.CurrentBaseFee())
+	if err != nil {
+		t.Fatalf("Failed to compute required fee: %v", err)
+	}
+	supply := bc.Tokenomics.TotalSupply
+
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{tx}); err != nil {
+		t.Fatalf("Expected transaction to be accepted: %v", err)
+	}
+	if bc.Tokenomics.TotalSupply != supply-burned {
+		t.Errorf("Expected supply to drop by %s, got %s", burned, supply-bc.Tokenomics.TotalSupply)
+	}
+	if bc.Tokenomics.GetBalance("Proposer") != cent-burned {
+		t.Errorf("Expected proposer tip of %s, got %s", cent-burned, bc.Tokenomics.GetBalance("Proposer"))
+	}
+	if bc.Tokenomics.GetBalance(alice.Address) != 99*blockchain.OneBMT-cent {
+		t.Errorf("Expected Alice to pay amount plus fee, balance is %s", bc.Tokenomics.GetBalance(alice.Address))
+	}
+}
+
+func TestFeeBelowBaseFeeRejected(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+	pool := blockchain.NewMempool(bc, blockchain.DefaultMempoolConfig())
+
+	tx, _ := blockchain.NewSignedTransaction(alice, "Bob", blockchain.OneBMT, blockchain.BaseUnit, 0, bc.ChainID, 1700000000)
+	if err := pool.Add(tx); !errors.Is(err, blockchain.ErrFeeTooLow) {
+		t.Errorf("Expected mempool to reject underpriced fee, got %v", err)
+	}
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{tx}); !errors.Is(err, blockchain.ErrFeeTooLow) {
+		t.Errorf("Expected block to reject underpriced fee, got %v", err)
+	}
+}
+
+func TestNextBaseFeeFollowsBlockFullness(t *testing.T) {
+	empty := blockchain.NewBlock(1, nil, "")
+	empty.BaseFee = 800
+	if next := blockchain.NextBaseFee(empty); next != 700 {
+		t.Errorf("Expected empty block to lower base fee to 700, got %d", next)
+	}
+
+	transactions := make([]blockchain.Transaction, blockchain.MaxTransactionsPerBlock)
+	full := blockchain.NewBlock(1, transactions, "")
+	full.BaseFee = 800
+	if next := blockchain.NextBaseFee(full); next != 900 {
+		t.Errorf("Expected full block to raise base fee to 900, got %d", next)
+	}
+
+	full.BaseFee = 0
+	if next := blockchain.NextBaseFee(full); next <= blockchain.MinBaseFee {
+		t.Errorf("Expected base fee to rise above the floor, got %d", next)
+	}
+}
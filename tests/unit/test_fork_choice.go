@@ -0,0 +1,102 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+)
+
+func TestReorgToHeavierBranch(t *testing.T) {
+	dir := t.TempDir()
+	store, err := blockchain.OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	bc, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	alice, _ := blockchain.NewWallet()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, alice.Address, 100*blockchain.OneBMT)
+
+	// A peer shares the funding block, then mines a longer branch without Alice's transfer
+	peer := blockchain.NewBlockchain()
+	if _, err := peer.ProcessBlock(bc.GetLatestBlock()); err != nil {
+		t.Fatalf("Failed to share funding block: %v", err)
+	}
+	peer.AddTransactionWithTokenomics(blockchain.SystemAddress, "Carol", blockchain.OneBMT)
+	peer.AddTransactionWithTokenomics(blockchain.SystemAddress, "Carol", blockchain.OneBMT)
+
+	tx, _ := blockchain.NewSignedTransaction(alice, "Bob", 40*blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{tx}); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+
+	if reorg, err := bc.ProcessBlock(peer.Chain[2]); err != nil || reorg != nil {
+		t.Fatalf("Expected equal-weight side branch to be stored without reorg, got %v, %v", reorg, err)
+	}
+	if bc.GetLatestBlock().Transactions()[0].Hash != tx.Hash {
+		t.Fatal("Expected the first-seen branch to remain canonical on a tie")
+	}
+
+	reorg, err := bc.ProcessBlock(peer.Chain[3])
+	if err != nil || reorg == nil {
+		t.Fatalf("Expected a reorg onto the heavier branch, got %v, %v", reorg, err)
+	}
+	if reorg.CommonAncestor.Index != 1 || len(reorg.Detached) != 1 || len(reorg.Attached) != 2 {
+		t.Errorf("Unexpected reorg: ancestor %d, %d detached, %d attached", reorg.CommonAncestor.Index, len(reorg.Detached), len(reorg.Attached))
+	}
+	if bc.GetLatestBlock().Hash != peer.GetLatestBlock().Hash {
+		t.Errorf("Expected tip %s, got %s", peer.GetLatestBlock().Hash, bc.GetLatestBlock().Hash)
+	}
+	if bc.Tokenomics.GetBalance(alice.Address) != 100*blockchain.OneBMT || bc.Tokenomics.GetBalance("Bob") != 0 {
+		t.Errorf("Expected the transfer to be rolled back, Alice has %s and Bob %s", bc.Tokenomics.GetBalance(alice.Address), bc.Tokenomics.GetBalance("Bob"))
+	}
+	if bc.Tokenomics.GetBalance("Carol") != 2*blockchain.OneBMT {
+		t.Errorf("Expected the new branch to be applied, Carol has %s", bc.Tokenomics.GetBalance("Carol"))
+	}
+	if bc.GetNonce(alice.Address) != 0 {
+		t.Errorf("Expected Alice's nonce to be rolled back to 0, got %d", bc.GetNonce(alice.Address))
+	}
+
+	pool := blockchain.NewMempool(bc, blockchain.DefaultMempoolConfig())
+	if returned := pool.ReturnTransactions(reorg.Detached); returned != 1 {
+		t.Errorf("Expected the orphaned transaction to return to the pool, %d returned", returned)
+	}
+
+	// The reorg must survive a restart
+	bc.Close()
+	store, _ = blockchain.OpenFileStore(dir)
+	reloaded, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to reload blockchain: %v", err)
+	}
+	defer reloaded.Close()
+	if reloaded.GetLatestBlock().Hash != peer.GetLatestBlock().Hash || reloaded.Tokenomics.GetBalance("Bob") != 0 {
+		t.Errorf("Expected reorganised chain after restart, tip %s", reloaded.GetLatestBlock().Hash)
+	}
+}
+
+func TestProcessBlockRejections(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	peer := blockchain.NewBlockchain()
+	peer.AddBlock(nil)
+	peer.AddBlock(nil)
+
+	if _, err := bc.ProcessBlock(peer.Chain[2]); !errors.Is(err, blockchain.ErrUnknownParent) {
+		t.Errorf("Expected unknown parent, got %v", err)
+	}
+	if _, err := bc.ProcessBlock(peer.Chain[1]); err != nil {
+		t.Fatalf("Expected block extending the tip to be accepted: %v", err)
+	}
+	if _, err := bc.ProcessBlock(peer.Chain[1]); !errors.Is(err, blockchain.ErrKnownBlock) {
+		t.Errorf("Expected known block, got %v", err)
+	}
+
+	forged := *peer.Chain[2]
+	forged.BaseFee++
+	forged.Hash = forged.CalculateHash()
+	if _, err := bc.ProcessBlock(&forged); err == nil {
+		t.Error("Expected block with wrong base fee to be rejected")
+	}
+}
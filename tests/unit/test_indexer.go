@@ -0,0 +1,92 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+)
+
+func TestAddressHistoryIsPaginatedNewestFirst(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	alice, _ := blockchain.NewWallet()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, alice.Address, 100*blockchain.OneBMT)
+
+	var sent []string
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, _ := blockchain.NewSignedTransaction(alice, "Bob", blockchain.OneBMT, cent, nonce, bc.ChainID, 1700000000)
+		if err := bc.AddTransactionBlock([]*blockchain.Transaction{tx}); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+		sent = append(sent, tx.Hash)
+	}
+
+	page, err := bc.GetAddressHistory(alice.Address, 0, 2)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if page.Total != 4 || len(page.Records) != 2 {
+		t.Fatalf("Expected 2 of 4 records, got %d of %d", len(page.Records), page.Total)
+	}
+	if page.Records[0].Transaction.Hash != sent[2] || page.Records[1].Transaction.Hash != sent[1] {
+		t.Error("Expected the newest transactions first")
+	}
+
+	page, _ = bc.GetAddressHistory(alice.Address, 2, 2)
+	if len(page.Records) != 2 || page.Records[1].Transaction.Receiver != blockchain.PayoutAddress {
+		t.Error("Expected the funding transfer last on the second page")
+	}
+	if page, _ := bc.GetAddressHistory("Bob", 0, 10); page.Total != 3 {
+		t.Errorf("Expected 3 transactions received by Bob, got %d", page.Total)
+	}
+	if _, err := bc.GetAddressHistory(alice.Address, -1, 10); err == nil {
+		t.Error("Expected a negative offset to be rejected")
+	}
+
+	record, err := bc.GetTransaction(sent[0])
+	if err != nil || record.Location.Height != 2 || record.Location.BlockHash != bc.Chain[2].Hash {
+		t.Errorf("Expected the first transfer in block 2, got %+v, %v", record, err)
+	}
+	if _, err := bc.GetTransaction("missing"); !errors.Is(err, blockchain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown transaction, got %v", err)
+	}
+}
+
+func TestAddressHistorySurvivesPruningAndRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := blockchain.OpenFileStore(dir)
+	bc, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", 2*blockchain.OneBMT)
+	for i := 0; i < blockchain.MaxReorgDepth+2; i++ {
+		bc.AddBlock(nil)
+	}
+	if err := bc.Prune(1, 1); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+	bc.Close()
+
+	store, _ = blockchain.OpenFileStore(dir)
+	reloaded, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to reload blockchain: %v", err)
+	}
+	defer reloaded.Close()
+
+	page, err := reloaded.GetAddressHistory("Alice", 0, 10)
+	if err != nil || page.Total != 2 {
+		t.Fatalf("Expected 2 transactions for Alice after restart, got %+v, %v", page, err)
+	}
+	if len(page.Records) != 1 || page.Records[0].Location.Height != 2 {
+		t.Errorf("Expected the page to end before the pruned transaction, got %+v", page.Records)
+	}
+	if _, err := reloaded.GetAddressHistory("Alice", 1, 10); !errors.Is(err, blockchain.ErrPruned) {
+		t.Errorf("Expected a page starting at the pruned transaction to fail with ErrPruned, got %v", err)
+	}
+	hashes, _, _ := reloaded.Store.GetAddressTxs("Alice", 1, 1)
+	if _, err := reloaded.GetTransaction(hashes[0]); !errors.Is(err, blockchain.ErrPruned) {
+		t.Errorf("Expected the pruned transaction to fail with ErrPruned, got %v", err)
+	}
+}
@@ -2,36 +2,69 @@ package defi_test
 
 import (
 	"BMT-Blockchain/src/applications/defi"
+	"BMT-Blockchain/src/blockchain"
 	"testing"
 )
 
 func TestLendingPool(t *testing.T) {
-	pool := defi.NewLendingPool(10000, 0.05)
+	pool := defi.NewLendingPool(10000*blockchain.OneBMT, 0.05)
 
 	// Deposit
-	err := pool.Deposit("Alice", 2000)
+	err := pool.Deposit("Alice", 2000*blockchain.OneBMT)
 	if err != nil {
 		t.Errorf("Failed to deposit: %v", err)
 	}
-	if pool.GetUserBalance("Alice") != 2000 {
-		t.Errorf("Expected Alice's balance to be 2000, got %d", pool.GetUserBalance("Alice"))
+	if pool.GetUserBalance("Alice") != 2000*blockchain.OneBMT {
+		t.Errorf("Expected Alice's balance to be 2000 BMT, got %s", pool.GetUserBalance("Alice"))
 	}
 
 	// Borrow
-	err = pool.Borrow("Alice", 800)
+	err = pool.Borrow("Alice", 800*blockchain.OneBMT)
 	if err != nil {
 		t.Errorf("Failed to borrow: %v", err)
 	}
-	if pool.GetUserBorrowed("Alice") != 800 {
-		t.Errorf("Expected Alice's borrowed amount to be 800, got %d", pool.GetUserBorrowed("Alice"))
+	if pool.GetUserBorrowed("Alice") != 800*blockchain.OneBMT {
+		t.Errorf("Expected Alice's borrowed amount to be 800 BMT, got %s", pool.GetUserBorrowed("Alice"))
 	}
 
 	// Repay
-	err = pool.Repay("Alice", 400)
+	err = pool.Repay("Alice", 400*blockchain.OneBMT)
 	if err != nil {
 		t.Errorf("Failed to repay: %v", err)
 	}
-	if pool.GetUserBorrowed("Alice") != 400 {
-		t.Errorf("Expected Alice's borrowed amount to be 400, got %d", pool.GetUserBorrowed("Alice"))
+	if pool.GetUserBorrowed("Alice") != 400*blockchain.OneBMT {
+		t.Errorf("Expected Alice's borrowed amount to be 400 BMT, got %s", pool.GetUserBorrowed("Alice"))
+	}
+}
+
+func TestDepositFromPullsCoinsOnChain(t *testing.T) {
+	user, _ := blockchain.NewWallet()
+	wallet, _ := blockchain.NewWallet()
+	chain := blockchain.NewBlockchain()
+	for _, address := range []string{user.Address, wallet.Address} {
+		if err := chain.AddTransactionWithTokenomics(blockchain.SystemAddress, address, 10*blockchain.OneBMT); err != nil {
+			t.Fatalf("Failed to fund %s: %v", address, err)
+		}
+	}
+	pool := defi.NewLendingPool(0, 0.05)
+
+	approve, err := blockchain.NewApproveTransaction(user, wallet.Address, 5*blockchain.OneBMT, blockchain.OneBMT/100, 0, chain.ChainID, 1700000000)
+	if err != nil {
+		t.Fatalf("Failed to sign approval: %v", err)
+	}
+	if err := chain.AddTransactionBlock([]*blockchain.Transaction{approve}); err != nil {
+		t.Fatalf("Failed to approve the pool: %v", err)
+	}
+	if err := pool.DepositFrom(chain, wallet, user.Address, 6*blockchain.OneBMT, blockchain.OneBMT/100); err == nil {
+		t.Error("Expected a deposit beyond the allowance to fail")
+	}
+	if err := pool.DepositFrom(chain, wallet, user.Address, 5*blockchain.OneBMT, blockchain.OneBMT/100); err != nil {
+		t.Fatalf("Failed to deposit: %v", err)
+	}
+	if balance := pool.GetUserBalance(user.Address); balance != 5*blockchain.OneBMT {
+		t.Errorf("Expected a deposit of 5 BMT, got %s", balance)
+	}
+	if allowance := chain.GetAllowance(user.Address, wallet.Address); allowance != 0 {
+		t.Errorf("Expected the allowance to be spent, got %s", allowance)
 	}
 }
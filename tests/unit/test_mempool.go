@@ -0,0 +1,154 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cent is a hundredth of a BMT, used for readable fees.
+const cent = blockchain.OneBMT / 100
+
+func TestMempoolOrdersByFeeAndNonce(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bob, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, bob.Address, 100*blockchain.OneBMT)
+	pool := blockchain.NewMempool(bc, blockchain.DefaultMempoolConfig())
+
+	// Alice's second transaction pays the most but must follow her first
+	a0, _ := blockchain.NewSignedTransaction(alice, "Carol", blockchain.OneBMT, 10*cent, 0, bc.ChainID, 1700000000)
+	a1, _ := blockchain.NewSignedTransaction(alice, "Carol", blockchain.OneBMT, 90*cent, 1, bc.ChainID, 1700000000)
+	b0, _ := blockchain.NewSignedTransaction(bob, "Carol", blockchain.OneBMT, 50*cent, 0, bc.ChainID, 1700000000)
+	for _, tx := range []*blockchain.Transaction{a1, b0, a0} {
+		if err := pool.Add(tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+
+	pending := pool.Pending(10)
+	if len(pending) != 3 || pending[0] != b0 || pending[1] != a0 || pending[2] != a1 {
+		t.Fatalf("Unexpected pending order: %+v", pending)
+	}
+
+	if err := pool.ProduceBlock(); err != nil {
+		t.Fatalf("Failed to produce block: %v", err)
+	}
+	if pool.Len() != 0 {
+		t.Errorf("Expected included transactions to leave the pool, %d remain", pool.Len())
+	}
+	if bc.Tokenomics.GetBalance("Carol") != 3*blockchain.OneBMT {
+		t.Errorf("Expected Carol's balance to be 3 BMT, got %s", bc.Tokenomics.GetBalance("Carol"))
+	}
+}
+
+func TestMempoolReplaceByFee(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+	pool := blockchain.NewMempool(bc, blockchain.DefaultMempoolConfig())
+
+	original, _ := blockchain.NewSignedTransaction(alice, "Bob", blockchain.OneBMT, 100*cent, 0, bc.ChainID, 1700000000)
+	cheap, _ := blockchain.NewSignedTransaction(alice, "Bob", blockchain.OneBMT, 105*cent, 0, bc.ChainID, 1700000001)
+	bumped, _ := blockchain.NewSignedTransaction(alice, "Bob", blockchain.OneBMT, 120*cent, 0, bc.ChainID, 1700000002)
+
+	pool.Add(original)
+	if err := pool.Add(cheap); !errors.Is(err, blockchain.ErrReplacementUnderpriced) {
+		t.Errorf("Expected underpriced replacement to be rejected, got %v", err)
+	}
+	if err := pool.Add(bumped); err != nil {
+		t.Fatalf("Expected replacement to be accepted: %v", err)
+	}
+	if _, ok := pool.Get(original.Hash); ok {
+		t.Error("Expected the original transaction to be replaced")
+	}
+}
+
+func TestMempoolEvictionAndExpiry(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bob, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, bob.Address, 100*blockchain.OneBMT)
+	pool := blockchain.NewMempool(bc, blockchain.MempoolConfig{MaxSize: 1, Expiry: 50 * time.Millisecond, PriceBump: 0.1})
+
+	low, _ := blockchain.NewSignedTransaction(alice, "Carol", blockchain.OneBMT, 10*cent, 0, bc.ChainID, 1700000000)
+	high, _ := blockchain.NewSignedTransaction(bob, "Carol", blockchain.OneBMT, 20*cent, 0, bc.ChainID, 1700000000)
+	pool.Add(low)
+	if err := pool.Add(high); err != nil {
+		t.Fatalf("Expected higher fee transaction to evict the lowest: %v", err)
+	}
+	if _, ok := pool.Get(low.Hash); ok {
+		t.Error("Expected the lowest fee transaction to be evicted")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	pool.Prune()
+	if pool.Len() != 0 {
+		t.Errorf("Expected expired transactions to be dropped, %d remain", pool.Len())
+	}
+}
+
+func TestMempoolRanksByFeePerGas(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bob, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, bob.Address, 100*blockchain.OneBMT)
+
+	// Bob offers the higher fee for a transaction several times the size
+	small, _ := blockchain.NewSignedTransaction(alice, "Carol", blockchain.OneBMT, 50*cent, 0, bc.ChainID, 1700000000)
+	large, _ := blockchain.NewSignedTransaction(bob, strings.Repeat("c", 2000), blockchain.OneBMT, 60*cent, 0, bc.ChainID, 1700000000)
+	pool := blockchain.NewMempool(bc, blockchain.DefaultMempoolConfig())
+	for _, tx := range []*blockchain.Transaction{large, small} {
+		if err := pool.Add(tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+	if pending := pool.Pending(10); len(pending) != 2 || pending[0] != small {
+		t.Errorf("Expected the higher fee per gas first, got %+v", pending)
+	}
+
+	full := blockchain.NewMempool(bc, blockchain.MempoolConfig{MaxSize: 1, Expiry: time.Hour, PriceBump: 0.1})
+	full.Add(large)
+	if err := full.Add(small); err != nil {
+		t.Fatalf("Expected the higher fee per gas to evict the lower: %v", err)
+	}
+	if _, ok := full.Get(large.Hash); ok {
+		t.Error("Expected the lower fee per gas to be evicted")
+	}
+}
+
+func TestMempoolEvictsTransactionsThatFailToExecute(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bob, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, bob.Address, 1000*blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Whale", 7000*blockchain.OneBMT)
+	// One millionth of the circulating supply, 7200 BMT
+	bc.Tokenomics.OwnershipCap = 0.000001
+	pool := blockchain.NewMempool(bc, blockchain.DefaultMempoolConfig())
+
+	capped, _ := blockchain.NewSignedTransaction(bob, "Whale", 500*blockchain.OneBMT, 90*cent, 0, bc.ChainID, 1700000000)
+	after, _ := blockchain.NewSignedTransaction(bob, "Carol", blockchain.OneBMT, 90*cent, 1, bc.ChainID, 1700000000)
+	valid, _ := blockchain.NewSignedTransaction(alice, "Carol", blockchain.OneBMT, 10*cent, 0, bc.ChainID, 1700000000)
+	for _, tx := range []*blockchain.Transaction{capped, after, valid} {
+		if err := pool.Add(tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+
+	if err := pool.ProduceBlock(); err != nil {
+		t.Fatalf("Expected a block despite the transaction above the cap: %v", err)
+	}
+	if block := bc.GetLatestBlock(); len(block.Transactions()) != 1 || block.Transactions()[0].Hash != valid.Hash {
+		t.Errorf("Expected only the valid transaction in the block, got %+v", block.Transactions())
+	}
+	if pool.Len() != 0 {
+		t.Errorf("Expected the failing transaction and its successor to be evicted, %d remain", pool.Len())
+	}
+
+	sealer := blockchain.NewSealer(bc, blockchain.DefaultSealerConfig())
+	if _, err := sealer.Add(*capped); !errors.Is(err, blockchain.ErrOwnershipCap) {
+		t.Errorf("Expected the sealer to refuse the transaction above the cap, got %v", err)
+	}
+}
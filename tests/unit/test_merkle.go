@@ -0,0 +1,58 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"strings"
+	"testing"
+)
+
+func TestMerkleProofsForEveryTransaction(t *testing.T) {
+	// Large receivers spread 25 transactions over several mini-blocks and sub-blocks,
+	// leaving odd leaves at each level
+	receiver := strings.Repeat("r", blockchain.MaxMiniBlockSize/3)
+	var transactions []blockchain.Transaction
+	for i := 0; i < 25; i++ {
+		tx, _ := blockchain.NewTransaction("Alice", receiver, blockchain.OneBMT, int64(1700000000+i))
+		transactions = append(transactions, *tx)
+	}
+	block := blockchain.NewBlock(1, transactions, "")
+	if len(block.SubBlocks) < 2 {
+		t.Fatalf("Expected several sub-blocks, got %d", len(block.SubBlocks))
+	}
+
+	for i := range transactions {
+		tx := &transactions[i]
+		proof, err := block.ProveTransaction(tx.Hash)
+		if err != nil {
+			t.Fatalf("Failed to prove transaction %d: %v", i, err)
+		}
+		if !blockchain.VerifyMerkleProof(tx, proof, block.MerkleRoot) {
+			t.Errorf("Proof for transaction %d does not verify", i)
+		}
+	}
+
+	tampered := transactions[3]
+	tampered.Amount++
+	proof, _ := block.ProveTransaction(tampered.Hash)
+	if blockchain.VerifyMerkleProof(&tampered, proof, block.MerkleRoot) {
+		t.Error("Expected proof for a tampered transaction to fail")
+	}
+}
+
+func TestMerkleProofFromChain(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+	tx := bc.GetLatestBlock().Transactions()[0]
+
+	proof, err := bc.GetTransactionProof(tx.Hash)
+	if err != nil {
+		t.Fatalf("Failed to get proof: %v", err)
+	}
+	header := bc.Chain[proof.Height]
+	if header.Hash != proof.BlockHash || !blockchain.VerifyMerkleProof(&tx, proof, header.MerkleRoot) {
+		t.Error("Expected the proof to verify against the block header")
+	}
+	if _, err := bc.GetTransactionProof("missing"); err == nil {
+		t.Error("Expected an error for an unknown transaction")
+	}
+}
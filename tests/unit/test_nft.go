@@ -2,6 +2,7 @@ package gamefi_test
 
 import (
 	"BMT-Blockchain/src/applications/gamefi"
+	"BMT-Blockchain/src/blockchain"
 	"testing"
 )
 
@@ -27,3 +28,41 @@ func TestMintAndTransferNFT(t *testing.T) {
 		t.Errorf("Expected NFT owner to be Bob, got %s", owner)
 	}
 }
+
+func TestSettleSalePullsThePriceFromTheBuyersAllowance(t *testing.T) {
+	buyer, _ := blockchain.NewWallet()
+	operator, _ := blockchain.NewWallet()
+	chain := blockchain.NewBlockchain()
+	for _, address := range []string{buyer.Address, operator.Address} {
+		if err := chain.AddTransactionWithTokenomics(blockchain.SystemAddress, address, 10*blockchain.OneBMT); err != nil {
+			t.Fatalf("Failed to fund %s: %v", address, err)
+		}
+	}
+	marketplace := gamefi.NewNFTMarketplace()
+	if _, err := marketplace.MintNFT("Alice", "NFT001", "Sword", "A powerful sword"); err != nil {
+		t.Fatalf("Failed to mint NFT: %v", err)
+	}
+	if err := marketplace.ListNFT("Alice", "NFT001", 4*blockchain.OneBMT); err != nil {
+		t.Fatalf("Failed to list NFT: %v", err)
+	}
+
+	if err := marketplace.SettleSale(chain, operator, buyer.Address, "NFT001", blockchain.OneBMT/100); err == nil {
+		t.Error("Expected a sale without the buyer's approval to fail")
+	}
+	approve, err := blockchain.NewApproveTransaction(buyer, operator.Address, 4*blockchain.OneBMT, blockchain.OneBMT/100, 0, chain.ChainID, 1700000000)
+	if err != nil {
+		t.Fatalf("Failed to sign approval: %v", err)
+	}
+	if err := chain.AddTransactionBlock([]*blockchain.Transaction{approve}); err != nil {
+		t.Fatalf("Failed to approve the marketplace: %v", err)
+	}
+	if err := marketplace.SettleSale(chain, operator, buyer.Address, "NFT001", blockchain.OneBMT/100); err != nil {
+		t.Fatalf("Failed to settle the sale: %v", err)
+	}
+	if owner, _ := marketplace.GetNFTOwner("NFT001"); owner != buyer.Address {
+		t.Errorf("Expected the buyer to own the NFT, got %s", owner)
+	}
+	if balance := chain.Tokenomics.GetBalance("Alice"); balance != 4*blockchain.OneBMT {
+		t.Errorf("Expected the seller to receive 4 BMT, got %s", balance)
+	}
+}
@@ -0,0 +1,109 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+)
+
+func TestTokenomicsEnforceTheOwnershipCap(t *testing.T) {
+	tokenomics := blockchain.NewTokenomics(10_000, 100_000)
+	tokenomics.MintCoins("Alice", 50)
+	if err := tokenomics.MintCoins("Alice", 60); !errors.Is(err, blockchain.ErrOwnershipCap) {
+		t.Errorf("Expected minting above 1%% of the circulating supply to be rejected, got %v", err)
+	}
+	tokenomics.MintCoins("Bob", 90)
+	if err := tokenomics.Transfer("Bob", "Alice", 60); !errors.Is(err, blockchain.ErrOwnershipCap) {
+		t.Errorf("Expected a transfer above the cap to be rejected, got %v", err)
+	}
+	if err := tokenomics.Transfer("Bob", "Alice", 50); err != nil {
+		t.Errorf("Failed to transfer up to the cap: %v", err)
+	}
+
+	tokenomics.CapExempt["Alice"] = true
+	if err := tokenomics.Transfer("Bob", "Alice", 40); err != nil {
+		t.Errorf("Expected an exempt address to exceed the cap, got %v", err)
+	}
+	if exemptions := tokenomics.CapExemptions(); len(exemptions) != 1 || exemptions[0] != "Alice" {
+		t.Errorf("Expected Alice to be the only exemption, got %v", exemptions)
+	}
+}
+
+func TestOwnershipCapIsEnforcedOnChain(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	council := bc.Treasurer
+	if err := bc.AddTransactionWithTokenomics(blockchain.SystemAddress, council.Address, blockchain.OneBMT); err != nil {
+		t.Fatalf("Failed to fund the council: %v", err)
+	}
+	// One millionth of the 7.2B circulating outside the locked founders' bucket
+	bc.Tokenomics.OwnershipCap = 0.000001
+	if circulating := bc.Tokenomics.CirculatingSupply(); circulating != 7_200_000_000*blockchain.OneBMT {
+		t.Fatalf("Expected the locked founders' bucket to be excluded from circulation, got %s", circulating)
+	}
+	if err := bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", 8000*blockchain.OneBMT); !errors.Is(err, blockchain.ErrOwnershipCap) {
+		t.Errorf("Expected a credit above the cap to be rejected, got %v", err)
+	}
+	if err := bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", 5000*blockchain.OneBMT); err != nil {
+		t.Fatalf("Failed to credit within the cap: %v", err)
+	}
+	if err := bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", 3000*blockchain.OneBMT); !errors.Is(err, blockchain.ErrOwnershipCap) {
+		t.Errorf("Expected a credit taking the balance above the cap to be rejected, got %v", err)
+	}
+
+	exempt, _ := blockchain.NewCapExemptionTransaction(council, "Alice", true, cent, bc.GetNonce(council.Address), bc.ChainID, 1700000000)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{exempt}); err != nil {
+		t.Fatalf("Failed to exempt Alice: %v", err)
+	}
+	if err := bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", 3001*blockchain.OneBMT); err != nil {
+		t.Errorf("Expected an exempt address to exceed the cap, got %v", err)
+	}
+	if balance := bc.Tokenomics.GetBalance("Alice"); balance != 8001*blockchain.OneBMT {
+		t.Errorf("Expected Alice to hold 8001 BMT, got %s", balance)
+	}
+}
+
+func TestCapExemptionsAreChangedOnlyByGovernance(t *testing.T) {
+	dir := t.TempDir()
+	council, _ := blockchain.NewWallet()
+	mallory, _ := blockchain.NewWallet()
+	genesis := blockchain.DefaultGenesis()
+	genesis.Governance = council.Address
+
+	store, _ := blockchain.OpenFileStore(dir)
+	bc, err := blockchain.NewBlockchainWithGenesis(store, genesis)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	bc.Treasurer = council
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, council.Address, blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, mallory.Address, blockchain.OneBMT)
+	if account, _ := store.GetAccount(blockchain.SystemAddress); !account.CapExempt {
+		t.Error("Expected the genesis buckets to start out exempt in the account state")
+	}
+
+	forged, _ := blockchain.NewCapExemptionTransaction(mallory, mallory.Address, true, cent, 0, bc.ChainID, 1700000000)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{forged}); !errors.Is(err, blockchain.ErrNotGovernance) {
+		t.Errorf("Expected an exemption from outside governance to be rejected, got %v", err)
+	}
+	nonce := bc.GetNonce(council.Address)
+	exempt, _ := blockchain.NewCapExemptionTransaction(council, "bridge", true, cent, nonce, bc.ChainID, 1700000000)
+	revoke, _ := blockchain.NewCapExemptionTransaction(council, blockchain.SystemAddress, false, cent, nonce+1, bc.ChainID, 1700000000)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{exempt, revoke}); err != nil {
+		t.Fatalf("Failed to change the exemptions: %v", err)
+	}
+	tip := bc.GetLatestBlock()
+	bc.Close()
+
+	store, _ = blockchain.OpenFileStore(dir)
+	reloaded, err := blockchain.NewBlockchainWithGenesis(store, genesis)
+	if err != nil {
+		t.Fatalf("Failed to reload blockchain: %v", err)
+	}
+	defer reloaded.Close()
+	if exemptions := reloaded.Tokenomics.CapExemptions(); len(exemptions) != 4 || exemptions[0] != "allocation/founders" || exemptions[3] != "bridge" {
+		t.Errorf("Expected the exemptions to survive a restart, got %v", exemptions)
+	}
+	if proof, err := reloaded.GetAccountProof("bridge"); err != nil || !proof.Account.CapExempt || !blockchain.VerifyAccountProof(proof, tip.StateRoot) {
+		t.Errorf("Expected the exemption to be committed to by the state root, got %+v (%v)", proof, err)
+	}
+}
@@ -0,0 +1,75 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPruneKeepsHeadersAndCompactsLog(t *testing.T) {
+	dir := t.TempDir()
+	store, err := blockchain.OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	bc, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+	}
+	prunedTx := bc.Chain[2].Transactions()[0].Hash
+	for i := 0; i < blockchain.MaxReorgDepth+5; i++ {
+		if err := bc.AddBlock(nil); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+	tip := bc.GetLatestBlock()
+	logPath := filepath.Join(dir, "chain.log")
+	before, _ := os.Stat(logPath)
+
+	// Pruning is capped below the finalized height
+	if err := bc.Prune(tip.Index, tip.Index); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+	if _, err := bc.GetBlock(2); !errors.Is(err, blockchain.ErrPruned) {
+		t.Errorf("Expected block 2 to be pruned, got %v", err)
+	}
+	if _, err := bc.GetTransactionProof(prunedTx); !errors.Is(err, blockchain.ErrPruned) {
+		t.Errorf("Expected the proof of a pruned transaction to fail with ErrPruned, got %v", err)
+	}
+	if _, err := bc.GetBlock(tip.Index - blockchain.MaxReorgDepth + 1); err != nil {
+		t.Errorf("Expected blocks within the reorg depth to keep their bodies, got %v", err)
+	}
+	if !bc.IsValid() {
+		t.Error("Expected the pruned chain to remain valid")
+	}
+
+	after, _ := os.Stat(logPath)
+	if after.Size() >= before.Size() {
+		t.Errorf("Expected the commit log to shrink, %d bytes before and %d after", before.Size(), after.Size())
+	}
+
+	// The compacted log must reload to the same chain and state
+	bc.AddBlock(nil)
+	tip = bc.GetLatestBlock()
+	bc.Close()
+	store, _ = blockchain.OpenFileStore(dir)
+	reloaded, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to reload blockchain: %v", err)
+	}
+	defer reloaded.Close()
+	if reloaded.GetLatestBlock().Hash != tip.Hash || reloaded.State.Root() != tip.StateRoot {
+		t.Errorf("Expected tip %s after restart, got %s", tip.Hash, reloaded.GetLatestBlock().Hash)
+	}
+	if reloaded.Tokenomics.GetBalance("Alice") != 5*blockchain.OneBMT {
+		t.Errorf("Expected Alice's balance to survive compaction, got %s", reloaded.Tokenomics.GetBalance("Alice"))
+	}
+	if _, err := reloaded.GetTransactionProof(prunedTx); !errors.Is(err, blockchain.ErrPruned) {
+		t.Errorf("Expected the pruned transaction to stay indexed after restart, got %v", err)
+	}
+}
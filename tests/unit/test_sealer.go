@@ -0,0 +1,103 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSealerSealsOnTimeout(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+	sealer := blockchain.NewSealer(bc, blockchain.DefaultSealerConfig())
+
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx, _ := blockchain.NewSignedTransaction(alice, "Bob", blockchain.OneBMT, cent, nonce, bc.ChainID, 1700000000)
+		if _, err := sealer.Add(*tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+	height := bc.GetLatestBlock().Index
+
+	start := time.Now()
+	if block, err := sealer.Tick(start.Add(sealer.Config.MiniBlockTimeout)); err != nil || block != nil {
+		t.Fatalf("Expected only the mini-block to be sealed, got %v, %v", block, err)
+	}
+	block, err := sealer.Tick(start.Add(sealer.Config.BlockTimeout))
+	if err != nil || block == nil {
+		t.Fatalf("Expected a block to be sealed on timeout, got %v", err)
+	}
+
+	if bc.GetLatestBlock() != block || block.Index != height+1 {
+		t.Errorf("Expected the sealed block to be appended at height %d", height+1)
+	}
+	if key := block.SubBlocks[0].MiniBlocks[0].Key; key != fmt.Sprintf("B%d/S0/M0", block.Index) {
+		t.Errorf("Unexpected mini-block key %s", key)
+	}
+	if bc.Tokenomics.GetBalance("Bob") != 2*blockchain.OneBMT {
+		t.Errorf("Expected Bob's balance to be 2 BMT, got %s", bc.Tokenomics.GetBalance("Bob"))
+	}
+}
+
+func TestBlockPackingRespectsSizeLimits(t *testing.T) {
+	// Each transaction takes a tenth of a mini-block
+	receiver := strings.Repeat("r", blockchain.MaxMiniBlockSize/10)
+	var transactions []blockchain.Transaction
+	for i := 0; i < 150; i++ {
+		tx, _ := blockchain.NewTransaction("Alice", receiver, blockchain.OneBMT, int64(1700000000+i))
+		transactions = append(transactions, *tx)
+	}
+
+	block := blockchain.NewBlock(1, transactions, "")
+	if len(block.SubBlocks) < 2 {
+		t.Fatalf("Expected transactions to spill into several sub-blocks, got %d", len(block.SubBlocks))
+	}
+	for _, sub := range block.SubBlocks {
+		if len(sub.MiniBlocks) > blockchain.MaxMiniBlocks || sub.Size() > blockchain.MaxSubBlockSize {
+			t.Errorf("Sub-block %s exceeds limits: %d mini-blocks, %d bytes", sub.Key, len(sub.MiniBlocks), sub.Size())
+		}
+		for _, mini := range sub.MiniBlocks {
+			if mini.CurrentSize > blockchain.MaxMiniBlockSize {
+				t.Errorf("Mini-block %s exceeds %d bytes", mini.Key, blockchain.MaxMiniBlockSize)
+			}
+		}
+	}
+	if len(block.Transactions()) != len(transactions) {
+		t.Errorf("Expected %d transactions, got %d", len(transactions), len(block.Transactions()))
+	}
+}
+
+func TestMineTransactionExtendsMainBlock(t *testing.T) {
+	block := blockchain.NewBlock(1, nil, "0000")
+	tx, _ := blockchain.NewTransaction("Alice", "Bob", blockchain.OneBMT, 1700000000)
+
+	mini, err := blockchain.MineTransaction([]blockchain.Transaction{*tx}, block)
+	if err != nil {
+		t.Fatalf("Failed to mine transaction: %v", err)
+	}
+	if mini.Key != "B1/S0/M0" || len(block.Transactions()) != 1 {
+		t.Errorf("Expected the mini-block to be added to the main block, got key %s", mini.Key)
+	}
+	if block.MerkleRoot != block.CalculateMerkleRoot() || block.Hash != block.CalculateHash() {
+		t.Error("Expected the main block header to commit to the new mini-block")
+	}
+}
+
+func TestMineTransactionRejectsDuplicatesInTheSameBlock(t *testing.T) {
+	tx, _ := blockchain.NewTransaction("Alice", "Bob", blockchain.OneBMT, 1700000000)
+
+	block := blockchain.NewBlock(1, nil, "0000")
+	if _, err := blockchain.MineTransaction([]blockchain.Transaction{*tx}, block); err != nil {
+		t.Fatalf("Failed to mine transaction: %v", err)
+	}
+	if _, err := blockchain.MineTransaction([]blockchain.Transaction{*tx}, block); err == nil {
+		t.Error("Expected a transaction already in the block to be rejected")
+	}
+
+	other := blockchain.NewBlock(2, nil, "0000")
+	if _, err := blockchain.MineTransaction([]blockchain.Transaction{*tx}, other); err != nil {
+		t.Errorf("Expected an unrelated block to accept the transaction, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+)
+
+func TestDoubleSigningIsSlashedAndJailed(t *testing.T) {
+	bc, wallets := newStakingChain(t, 100*blockchain.OneBMT, 101*blockchain.OneBMT, 102*blockchain.OneBMT)
+	bc.Staking.MaxValidators, bc.Staking.UnbondingPeriod = 3, 20
+	bc.Staking.DoubleSignSlash, bc.Staking.JailPeriod = 0.1, 10
+	alice := wallets[0]
+	var bonds []*blockchain.Transaction
+	for _, wallet := range wallets {
+		bonds = append(bonds, stakingTx(t, bc, wallet, blockchain.BondAddress, 30*blockchain.OneBMT))
+	}
+	if err := bc.AddTransactionBlock(bonds); err != nil {
+		t.Fatalf("Failed to bond: %v", err)
+	}
+
+	first, _ := blockchain.NewConsensusVote(alice, bc.ChainID, blockchain.Prevote, 5, 0, "block-a")
+	second, _ := blockchain.NewConsensusVote(alice, bc.ChainID, blockchain.Prevote, 5, 0, "block-b")
+	evidence := blockchain.NewDuplicateVoteEvidence(second, first)
+	if err := bc.SubmitEvidence(evidence); !errors.Is(err, blockchain.ErrInvalidEvidence) {
+		t.Errorf("Expected evidence for a height not yet reached to be rejected, got %v", err)
+	}
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Dave", blockchain.OneBMT)
+	if err := bc.SubmitEvidence(evidence); err != nil {
+		t.Fatalf("Failed to submit evidence: %v", err)
+	}
+
+	supply := bc.Tokenomics.TotalSupply
+	if err := bc.AddBlock(nil); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	block := bc.GetLatestBlock()
+	if len(block.Evidence) != 1 || block.Evidence[0].Validator != alice.Address {
+		t.Fatalf("Expected block %d to carry the evidence, got %+v", block.Index, block.Evidence)
+	}
+	stake := bc.GetStake(alice.Address)
+	if stake.Bonded != 27*blockchain.OneBMT || stake.SlashedAt != 6 || stake.JailedUntil != 16 {
+		t.Errorf("Expected a tenth of the bond slashed and a jailing until height 16, got %+v", stake)
+	}
+	if bc.Tokenomics.TotalSupply != supply-3*blockchain.OneBMT {
+		t.Errorf("Expected the slashed stake to be burned, supply went from %s to %s", supply, bc.Tokenomics.TotalSupply)
+	}
+	if !hasValidator(bc.ValidatorsAt(6), alice.Address) || hasValidator(bc.ValidatorsAt(7), alice.Address) {
+		t.Error("Expected the jailed validator to leave the set after the block punishing it")
+	}
+	if pending := bc.PendingEvidence(); len(pending) != 0 {
+		t.Errorf("Expected included evidence to leave the pool, got %d", len(pending))
+	}
+	if err := bc.SubmitEvidence(evidence); !errors.Is(err, blockchain.ErrInvalidEvidence) {
+		t.Errorf("Expected misbehaviour to be punished once, got %v", err)
+	}
+
+	// Jailed validators are left out of elections until the jail period ends
+	for bc.GetLatestBlock().Index < 8 {
+		bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Dave", blockchain.Amount(bc.GetLatestBlock().Index)*blockchain.OneBMT)
+	}
+	if set := bc.ValidatorsAt(9); set == nil || hasValidator(set, alice.Address) || len(set.Validators) != 2 {
+		t.Errorf("Expected the jailed validator to miss the election, got %+v", set)
+	}
+}
+
+func TestDuplicateVoteEvidenceMustConflict(t *testing.T) {
+	network := newBFTNetwork(t, 4)
+	chain := blockchain.NewBlockchain()
+	chain.Validators = network.validators
+	chain.AddBlock(nil)
+	chain.AddBlock(nil)
+
+	var wallets []*blockchain.Wallet
+	for _, wallet := range network.wallets {
+		wallets = append(wallets, wallet)
+	}
+	vote, _ := blockchain.NewConsensusVote(wallets[0], chain.ChainID, blockchain.Prevote, 1, 0, "block-a")
+	same, _ := blockchain.NewConsensusVote(wallets[0], chain.ChainID, blockchain.Prevote, 1, 0, "block-a")
+	otherRound, _ := blockchain.NewConsensusVote(wallets[0], chain.ChainID, blockchain.Prevote, 1, 1, "block-b")
+	otherValidator, _ := blockchain.NewConsensusVote(wallets[1], chain.ChainID, blockchain.Prevote, 1, 0, "block-b")
+	for name, evidence := range map[string]*blockchain.Evidence{
+		"same block":      blockchain.NewDuplicateVoteEvidence(vote, same),
+		"other round":     blockchain.NewDuplicateVoteEvidence(vote, otherRound),
+		"other validator": blockchain.NewDuplicateVoteEvidence(vote, otherValidator),
+	} {
+		if err := chain.SubmitEvidence(evidence); !errors.Is(err, blockchain.ErrInvalidEvidence) {
+			t.Errorf("Expected evidence of %s to be rejected, got %v", name, err)
+		}
+	}
+
+	forged := *otherValidator
+	forged.Validator = wallets[0].Address
+	if err := chain.SubmitEvidence(blockchain.NewDuplicateVoteEvidence(vote, &forged)); !errors.Is(err, blockchain.ErrInvalidEvidence) {
+		t.Errorf("Expected evidence with a forged vote to be rejected, got %v", err)
+	}
+}
+
+func TestOfflineValidatorIsJailedForDowntime(t *testing.T) {
+	network := newBFTNetwork(t, 4)
+	offline := network.validators.Validators[0].Address
+	var chains []*blockchain.Blockchain
+	var engines []*blockchain.BFTConsensus
+	for address, chain := range network.chains {
+		chain.Staking.DowntimeWindow, chain.Staking.MaxMissedBlocks = 3, 1
+		if address != offline {
+			chains = append(chains, chain)
+			engines = append(engines, network.engines[address])
+		}
+	}
+	for _, engine := range engines {
+		engine.Start()
+	}
+	commitUpTo(t, engines, 6)
+
+	// Block 5 is the first whose chain records the certificates of heights 1 to 3
+	block := chains[0].Chain[5]
+	if len(block.Evidence) != 1 || block.Evidence[0].Type != blockchain.Downtime || block.Evidence[0].Validator != offline {
+		t.Fatalf("Expected block 5 to punish the offline validator, got %+v", block.Evidence)
+	}
+	for _, chain := range chains {
+		if chain.Chain[6].LastCommit == nil || chain.Chain[6].LastCommit.BlockHash != block.Hash {
+			t.Error("Expected each block to record the certificate of its parent")
+		}
+		if stake := chain.GetStake(offline); stake.JailedUntil != 5+chain.Staking.JailPeriod {
+			t.Errorf("Expected the offline validator to be jailed, got %+v", stake)
+		}
+		if hasValidator(chain.ValidatorsAt(6), offline) {
+			t.Error("Expected the offline validator to leave the set")
+		}
+	}
+}
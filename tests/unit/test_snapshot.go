@@ -0,0 +1,199 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// snapshotSource builds a chain with snapshots every 40 blocks and returns it
+// with the manifest and chunks of its latest snapshot.
+func snapshotSource(t *testing.T, height int) (*blockchain.Blockchain, *blockchain.SnapshotManifest, []*blockchain.SnapshotChunk) {
+	source := blockchain.NewBlockchain()
+	source.SnapshotInterval = 40
+	for i := 1; i <= height; i++ {
+		if err := source.AddTransactionWithTokenomics(blockchain.SystemAddress, fmt.Sprintf("user-%d", i%7), blockchain.OneBMT); err != nil {
+			t.Fatalf("Failed to add block %d: %v", i, err)
+		}
+	}
+
+	manifest, err := source.LatestSnapshot()
+	if err != nil {
+		t.Fatalf("Expected a snapshot: %v", err)
+	}
+	var chunks []*blockchain.SnapshotChunk
+	for i := range manifest.ChunkHashes {
+		chunk, err := source.GetSnapshotChunk(manifest.Height, i)
+		if err != nil {
+			t.Fatalf("Failed to get chunk %d: %v", i, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return source, manifest, chunks
+}
+
+// snapshotBlocks returns the blocks after genesis up to the snapshot, with
+// bodies only on the last SnapshotBodyWindow of them.
+func snapshotBlocks(source *blockchain.Blockchain, height int) []*blockchain.Block {
+	blocks := source.GetBlocks(1, height)
+	for i := range blocks[:len(blocks)-blockchain.SnapshotBodyWindow] {
+		blocks[i] = blocks[i].WithoutBody()
+	}
+	return blocks
+}
+
+// trustingChain returns a chain holding only genesis that trusts the
+// checkpoint of a manifest.
+func trustingChain(manifest *blockchain.SnapshotManifest) *blockchain.Blockchain {
+	bc := blockchain.NewBlockchain()
+	bc.Checkpoints = map[int]string{manifest.Height: manifest.BlockHash}
+	return bc
+}
+
+func TestImportSnapshotAndCatchUp(t *testing.T) {
+	source, manifest, chunks := snapshotSource(t, 45)
+	if manifest.Height != 40 || manifest.StateRoot != source.Chain[40].StateRoot {
+		t.Fatalf("Expected the snapshot at block 40, got %d", manifest.Height)
+	}
+
+	dir := t.TempDir()
+	store, err := blockchain.OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	bc, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	bc.Checkpoints = map[int]string{40: source.Chain[40].Hash}
+	if err := bc.ImportSnapshot(manifest, chunks, snapshotBlocks(source, 40)); err != nil {
+		t.Fatalf("Failed to import snapshot: %v", err)
+	}
+	for _, block := range source.GetBlocks(41, 45) {
+		if _, err := bc.ProcessBlock(block); err != nil {
+			t.Fatalf("Failed to catch up on block %d: %v", block.Index, err)
+		}
+	}
+
+	if bc.GetLatestBlock().Hash != source.GetLatestBlock().Hash || !bc.IsValid() {
+		t.Fatalf("Expected to catch up to %s, got %s", source.GetLatestBlock().Hash, bc.GetLatestBlock().Hash)
+	}
+	if bc.Tokenomics.GetBalance("user-3") != source.Tokenomics.GetBalance("user-3") || bc.Tokenomics.TotalSupply != source.Tokenomics.TotalSupply {
+		t.Errorf("Expected imported balances to match the source")
+	}
+
+	// The imported snapshot must survive a restart
+	bc.Close()
+	store, _ = blockchain.OpenFileStore(dir)
+	reloaded, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to reload blockchain: %v", err)
+	}
+	defer reloaded.Close()
+	if reloaded.GetLatestBlock().Hash != source.GetLatestBlock().Hash || reloaded.State.Root() != source.State.Root() {
+		t.Errorf("Expected the caught up chain after restart, tip %s", reloaded.GetLatestBlock().Hash)
+	}
+}
+
+func TestImportSnapshotRejectsTampering(t *testing.T) {
+	source, manifest, chunks := snapshotSource(t, 40)
+	blocks := snapshotBlocks(source, 40)
+
+	forged := *chunks[0]
+	forged.Accounts = map[string]blockchain.Account{"user-1": {Balance: blockchain.InitialSupply}}
+	if err := manifest.VerifyChunk(&forged); err == nil {
+		t.Error("Expected a forged chunk to fail verification")
+	}
+
+	if err := trustingChain(manifest).ImportSnapshot(manifest, []*blockchain.SnapshotChunk{&forged}, blocks); err == nil {
+		t.Error("Expected chunks not matching the manifest to be rejected")
+	}
+
+	// A manifest with another state root does not match the checkpoint header
+	forgedManifest := *manifest
+	forgedManifest.StateRoot = source.Chain[10].StateRoot
+	if err := trustingChain(manifest).ImportSnapshot(&forgedManifest, chunks, blocks); err == nil {
+		t.Error("Expected a state root not in the checkpoint header to be rejected")
+	}
+
+	// Headers that do not link back to genesis are rejected
+	broken := append([]*blockchain.Block(nil), blocks...)
+	broken[0] = blockchain.NewBlock(1, nil, "unknown")
+	if err := trustingChain(manifest).ImportSnapshot(manifest, chunks, broken); err == nil {
+		t.Error("Expected a header chain not linked to genesis to be rejected")
+	}
+
+	bc := trustingChain(manifest)
+	bc.AddBlock(nil)
+	if err := bc.ImportSnapshot(manifest, chunks, blocks); err == nil {
+		t.Error("Expected importing into a non-empty chain to be rejected")
+	}
+}
+
+func TestImportSnapshotChecksDifficultyRetargeting(t *testing.T) {
+	source, manifest, chunks := snapshotSource(t, 40)
+	blocks := snapshotBlocks(source, 40)
+
+	// A header only block may move at most one bit from its parent
+	jump := append([]*blockchain.Block(nil), blocks...)
+	forged := *jump[0]
+	forged.Difficulty += 3
+	forged.Hash = forged.CalculateHash()
+	jump[0] = &forged
+	if err := trustingChain(manifest).ImportSnapshot(manifest, chunks, jump); !errors.Is(err, blockchain.ErrBlockDifficulty) {
+		t.Errorf("Expected a header jumping in difficulty to be rejected, got %v", err)
+	}
+
+	// A block whose retargeting window has bodies must have the recomputed difficulty
+	easier := append([]*blockchain.Block(nil), blocks...)
+	checkpoint := *easier[39]
+	checkpoint.Difficulty--
+	checkpoint.Hash = checkpoint.CalculateHash()
+	easier[39] = &checkpoint
+	if err := trustingChain(manifest).ImportSnapshot(manifest, chunks, easier); !errors.Is(err, blockchain.ErrBlockDifficulty) {
+		t.Errorf("Expected a block below the retargeted difficulty to be rejected, got %v", err)
+	}
+}
+
+func TestImportSnapshotNeedsATrustedOrCertifiedCheckpoint(t *testing.T) {
+	source, manifest, chunks := snapshotSource(t, 40)
+	blocks := snapshotBlocks(source, 40)
+
+	if err := blockchain.NewBlockchain().ImportSnapshot(manifest, chunks, blocks); !errors.Is(err, blockchain.ErrUntrustedSnapshot) {
+		t.Errorf("Expected a snapshot from a single peer to be rejected, got %v", err)
+	}
+	other := blockchain.NewBlockchain()
+	other.Checkpoints = map[int]string{40: source.Chain[39].Hash}
+	if err := other.ImportSnapshot(manifest, chunks, blocks); !errors.Is(err, blockchain.ErrUntrustedSnapshot) {
+		t.Errorf("Expected a snapshot off the trusted checkpoint to be rejected, got %v", err)
+	}
+
+	validator, _ := blockchain.NewWallet()
+	validators, err := blockchain.NewValidatorSet([]blockchain.Validator{{Address: validator.Address, PublicKey: validator.PublicKey, Stake: 1000 * blockchain.OneBMT}})
+	if err != nil {
+		t.Fatalf("Failed to create validator set: %v", err)
+	}
+	uncertified := blockchain.NewBlockchain()
+	uncertified.Validators = validators
+	if err := uncertified.ImportSnapshot(manifest, chunks, blocks); !errors.Is(err, blockchain.ErrUntrustedSnapshot) {
+		t.Errorf("Expected an uncertified checkpoint to be rejected, got %v", err)
+	}
+
+	bc := blockchain.NewBlockchain()
+	bc.Validators = validators
+	vote, err := blockchain.NewConsensusVote(validator, bc.ChainID, blockchain.Precommit, 40, 0, manifest.BlockHash)
+	if err != nil {
+		t.Fatalf("Failed to sign precommit: %v", err)
+	}
+	certified := append([]*blockchain.Block(nil), blocks...)
+	checkpoint := *certified[39]
+	checkpoint.Commit = &blockchain.CommitCertificate{Height: 40, BlockHash: manifest.BlockHash, Precommits: []blockchain.ConsensusVote{*vote}}
+	certified[39] = &checkpoint
+	if err := bc.ImportSnapshot(manifest, chunks, certified); err != nil {
+		t.Fatalf("Expected a certified checkpoint to be imported, got %v", err)
+	}
+	if bc.GetLatestBlock().Hash != manifest.BlockHash {
+		t.Errorf("Expected the chain to end at the checkpoint, got %s", bc.GetLatestBlock().Hash)
+	}
+}
@@ -0,0 +1,194 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+)
+
+// newStakingChain creates a chain with short epochs and funds a wallet for
+// each of the given balances, one block per wallet.
+func newStakingChain(t *testing.T, balances ...blockchain.Amount) (*blockchain.Blockchain, []*blockchain.Wallet) {
+	bc := blockchain.NewBlockchain()
+	bc.Staking = blockchain.StakingConfig{EpochLength: 4, UnbondingPeriod: 3, MaxValidators: 2, MinBond: 10 * blockchain.OneBMT}
+	var wallets []*blockchain.Wallet
+	for _, balance := range balances {
+		wallet, _ := blockchain.NewWallet()
+		if err := bc.AddTransactionWithTokenomics(blockchain.SystemAddress, wallet.Address, balance); err != nil {
+			t.Fatalf("Failed to fund wallet: %v", err)
+		}
+		wallets = append(wallets, wallet)
+	}
+	return bc, wallets
+}
+
+// stakingTx signs a transaction from a wallet to a staking address.
+func stakingTx(t *testing.T, bc *blockchain.Blockchain, wallet *blockchain.Wallet, receiver string, amount blockchain.Amount) *blockchain.Transaction {
+	tx, err := blockchain.NewSignedTransaction(wallet, receiver, amount, cent, bc.GetNonce(wallet.Address), bc.ChainID, 1700000000)
+	if err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+func TestBondedValidatorsAreElectedAtEpochBoundaries(t *testing.T) {
+	bc, wallets := newStakingChain(t, 100*blockchain.OneBMT, 101*blockchain.OneBMT, 102*blockchain.OneBMT)
+	alice, bob, carol := wallets[0], wallets[1], wallets[2]
+
+	// Block 4 ends the first epoch and elects the validators of the second
+	err := bc.AddTransactionBlock([]*blockchain.Transaction{
+		stakingTx(t, bc, alice, blockchain.BondAddress, 30*blockchain.OneBMT),
+		stakingTx(t, bc, bob, blockchain.BondAddress, 20*blockchain.OneBMT),
+		stakingTx(t, bc, carol, blockchain.BondAddress, 10*blockchain.OneBMT),
+	})
+	if err != nil {
+		t.Fatalf("Failed to bond: %v", err)
+	}
+	if bc.ValidatorsAt(4) != nil {
+		t.Error("Expected no validators before the first election")
+	}
+	set := bc.ValidatorsAt(5)
+	if set == nil || len(set.Validators) != 2 {
+		t.Fatalf("Expected the two largest bonds to be elected, got %+v", set)
+	}
+	if _, ok := set.Get(carol.Address); ok {
+		t.Error("Expected the smallest bond to miss out on the capped set")
+	}
+	if set.TotalStake() != 50*blockchain.OneBMT {
+		t.Errorf("Expected validators to be weighted by their bonds, got %s", set.TotalStake())
+	}
+	if balance := bc.Tokenomics.GetBalance(alice.Address); balance != 70*blockchain.OneBMT-cent {
+		t.Errorf("Expected the bond to leave the balance, got %s", balance)
+	}
+	if stake := bc.GetStake(carol.Address); stake.Bonded != 10*blockchain.OneBMT || stake.Active {
+		t.Errorf("Expected carol to be bonded but inactive, got %+v", stake)
+	}
+
+	// The validator set only changes at the next boundary
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{stakingTx(t, bc, bob, blockchain.UnbondAddress, 20*blockchain.OneBMT)}); err != nil {
+		t.Fatalf("Failed to unbond: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Dave", blockchain.Amount(i+1)*blockchain.OneBMT)
+	}
+	if set := bc.ValidatorsAt(8); set == nil || !hasValidator(set, bob.Address) {
+		t.Error("Expected bob to validate until the end of the epoch")
+	}
+	set = bc.ValidatorsAt(9)
+	if set == nil || hasValidator(set, bob.Address) || !hasValidator(set, carol.Address) {
+		t.Errorf("Expected carol to replace bob in the next epoch, got %+v", set)
+	}
+	if bc.Epoch(8) != 1 || bc.Epoch(9) != 2 {
+		t.Errorf("Expected heights 8 and 9 in epochs 1 and 2, got %d and %d", bc.Epoch(8), bc.Epoch(9))
+	}
+	if stakes := bc.Stakes(); len(stakes) != 3 || stakes[0].Address != alice.Address {
+		t.Errorf("Expected three stakes led by alice, got %+v", stakes)
+	}
+}
+
+func TestEmptyElectionFallsBackToTheGenesisValidators(t *testing.T) {
+	bc, wallets := newStakingChain(t, 100*blockchain.OneBMT)
+	alice := wallets[0]
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{stakingTx(t, bc, alice, blockchain.BondAddress, 50*blockchain.OneBMT)}); err != nil {
+		t.Fatalf("Failed to bond: %v", err)
+	}
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Bob", blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Bob", 2*blockchain.OneBMT)
+	if set := bc.ValidatorsAt(5); set == nil || !hasValidator(set, alice.Address) {
+		t.Fatalf("Expected alice to be elected at height 4, got %+v", set)
+	}
+
+	// Nobody is bonded when block 8 ends the second epoch
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{stakingTx(t, bc, alice, blockchain.UnbondAddress, 50*blockchain.OneBMT)}); err != nil {
+		t.Fatalf("Failed to unbond: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Bob", blockchain.Amount(i+3)*blockchain.OneBMT)
+	}
+	if set := bc.ValidatorsAt(8); set == nil || !hasValidator(set, alice.Address) {
+		t.Error("Expected alice to validate until the end of the epoch")
+	}
+	if set := bc.ValidatorsAt(9); set != bc.Validators {
+		t.Errorf("Expected the empty election to fall back to the genesis validators, got %+v", set)
+	}
+}
+
+func TestUnbondedStakeIsLockedForTheUnbondingPeriod(t *testing.T) {
+	bc, wallets := newStakingChain(t, 100*blockchain.OneBMT)
+	alice := wallets[0]
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{stakingTx(t, bc, alice, blockchain.BondAddress, 5*blockchain.OneBMT)}); err == nil {
+		t.Error("Expected a bond below the minimum to be rejected")
+	}
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{stakingTx(t, bc, alice, blockchain.BondAddress, 50*blockchain.OneBMT)}); err != nil {
+		t.Fatalf("Failed to bond: %v", err)
+	}
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{stakingTx(t, bc, alice, blockchain.UnbondAddress, 60*blockchain.OneBMT)}); !errors.Is(err, blockchain.ErrInsufficientStake) {
+		t.Errorf("Expected unbonding more than the bond to fail, got %v", err)
+	}
+
+	// Unbonding at height 3 unlocks the stake at height 6
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{stakingTx(t, bc, alice, blockchain.UnbondAddress, 50*blockchain.OneBMT)}); err != nil {
+		t.Fatalf("Failed to unbond: %v", err)
+	}
+	if stake := bc.GetStake(alice.Address); stake.Bonded != 0 || stake.Unbonding != 50*blockchain.OneBMT || stake.UnbondingUntil != 6 {
+		t.Fatalf("Expected the whole bond to be unbonding until height 6, got %+v", stake)
+	}
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{stakingTx(t, bc, alice, blockchain.WithdrawAddress, 50*blockchain.OneBMT)}); err == nil {
+		t.Error("Expected a withdrawal before the end of the unbonding period to be rejected")
+	}
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Bob", blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Bob", 2*blockchain.OneBMT)
+
+	before := bc.Tokenomics.GetBalance(alice.Address)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{stakingTx(t, bc, alice, blockchain.WithdrawAddress, 50*blockchain.OneBMT)}); err != nil {
+		t.Fatalf("Failed to withdraw: %v", err)
+	}
+	if balance := bc.Tokenomics.GetBalance(alice.Address); balance != before+50*blockchain.OneBMT-cent {
+		t.Errorf("Expected the withdrawal to return the stake, got %s from %s", balance, before)
+	}
+	if stake := bc.GetStake(alice.Address); stake != (blockchain.StakeInfo{Address: alice.Address}) {
+		t.Errorf("Expected no stake left, got %+v", stake)
+	}
+}
+
+func TestElectedValidatorsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := blockchain.OpenFileStore(dir)
+	bc, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	bc.Staking.EpochLength = 2
+	alice, _ := blockchain.NewWallet()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, alice.Address, 20_000*blockchain.OneBMT)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{stakingTx(t, bc, alice, blockchain.BondAddress, 10_000*blockchain.OneBMT)}); err != nil {
+		t.Fatalf("Failed to bond: %v", err)
+	}
+	root := bc.State.Root()
+	bc.Close()
+
+	store, _ = blockchain.OpenFileStore(dir)
+	reloaded, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to reload blockchain: %v", err)
+	}
+	defer reloaded.Close()
+	reloaded.Staking.EpochLength = 2
+
+	if reloaded.State.Root() != root {
+		t.Error("Expected the state root to cover the reloaded stake")
+	}
+	set := reloaded.ValidatorsAt(3)
+	if set == nil || !hasValidator(set, alice.Address) {
+		t.Fatalf("Expected the election to be reloaded, got %+v", set)
+	}
+	if stake := reloaded.GetStake(alice.Address); stake.Bonded != 10_000*blockchain.OneBMT || !stake.Active {
+		t.Errorf("Expected the reloaded bond to be active, got %+v", stake)
+	}
+}
+
+// hasValidator reports whether an address is in a validator set.
+func hasValidator(set *blockchain.ValidatorSet, address string) bool {
+	_, ok := set.Get(address)
+	return ok
+}
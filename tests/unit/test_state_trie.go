@@ -0,0 +1,63 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+)
+
+func TestAccountProofsVerifyAgainstStateRoot(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", 5*blockchain.OneBMT)
+	tip := bc.GetLatestBlock()
+
+	proof, err := bc.GetAccountProof("Alice")
+	if err != nil {
+		t.Fatalf("Failed to get account proof: %v", err)
+	}
+	if proof.BlockHash != tip.Hash || proof.Account.Balance != 5*blockchain.OneBMT {
+		t.Fatalf("Unexpected proof: %+v", proof)
+	}
+	if !blockchain.VerifyAccountProof(proof, tip.StateRoot) {
+		t.Error("Expected the account proof to verify against the header state root")
+	}
+
+	forged := *proof
+	forged.Account.Balance = 500 * blockchain.OneBMT
+	if blockchain.VerifyAccountProof(&forged, tip.StateRoot) {
+		t.Error("Expected a forged balance to fail verification")
+	}
+
+	absent, _ := bc.GetAccountProof("Nobody")
+	if absent.Account.Balance != 0 || !blockchain.VerifyAccountProof(absent, tip.StateRoot) {
+		t.Error("Expected an absent account to be provable as empty")
+	}
+}
+
+func TestStateRootIsCheckedOnImport(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+	block := bc.GetLatestBlock()
+
+	peer := blockchain.NewBlockchain()
+	if peer.GetLatestBlock().StateRoot != peer.State.Root() {
+		t.Fatal("Expected genesis to commit to the initial state")
+	}
+
+	forged := *block
+	forged.StateRoot = peer.State.Root()
+	forged.Hash = forged.CalculateHash()
+	if _, err := peer.ProcessBlock(&forged); !errors.Is(err, blockchain.ErrStateRootMismatch) {
+		t.Errorf("Expected state root mismatch, got %v", err)
+	}
+	if peer.Tokenomics.GetBalance("Alice") != 0 {
+		t.Error("Expected the rejected block's state changes to be rolled back")
+	}
+
+	if _, err := peer.ProcessBlock(block); err != nil {
+		t.Fatalf("Expected the genuine block to be accepted: %v", err)
+	}
+	if peer.State.Root() != bc.State.Root() {
+		t.Error("Expected both nodes to agree on the state root")
+	}
+}
@@ -0,0 +1,95 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStorePersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := blockchain.OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	bc, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	if err := bc.AddTransactionWithTokenomics("system", "Alice", 100*blockchain.OneBMT); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+	tipHash := bc.GetLatestBlock().Hash
+	bc.Close()
+
+	store, err = blockchain.OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	reloaded, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to reload blockchain: %v", err)
+	}
+	defer reloaded.Close()
+
+	if reloaded.GetLatestBlock().Hash != tipHash {
+		t.Errorf("Expected tip %s after restart, got %s", tipHash, reloaded.GetLatestBlock().Hash)
+	}
+	if reloaded.Tokenomics.GetBalance("Alice") != 100*blockchain.OneBMT {
+		t.Errorf("Expected Alice's balance to be 100 BMT after restart, got %s", reloaded.Tokenomics.GetBalance("Alice"))
+	}
+	tx := reloaded.GetLatestBlock().Transactions()[0]
+	if location, err := store.GetTxLocation(tx.Hash); err != nil || location.Height != 1 {
+		t.Errorf("Expected transaction indexed at height 1, got %+v (%v)", location, err)
+	}
+}
+
+func TestFileStoreDiscardsTornCommit(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _ := blockchain.OpenFileStore(dir)
+	bc, _ := blockchain.NewDevelopmentBlockchain(store)
+	bc.Close()
+
+	// Simulate a crash halfway through writing the next record
+	file, _ := os.OpenFile(filepath.Join(dir, "chain.log"), os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString(`deadbeef {"block":{"Index":1`)
+	file.Close()
+
+	store, err := blockchain.OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	if store.Height() != 0 {
+		t.Errorf("Expected the torn commit to be discarded, got height %d", store.Height())
+	}
+}
+
+func TestFileStoreRejectsCorruptRecordBeforeTheTail(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _ := blockchain.OpenFileStore(dir)
+	bc, _ := blockchain.NewDevelopmentBlockchain(store)
+	bc.AddTransactionWithTokenomics("system", "Alice", 100*blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics("system", "Bob", 100*blockchain.OneBMT)
+	bc.Close()
+
+	// Damage the first commit while later commits remain intact
+	path := filepath.Join(dir, "chain.log")
+	data, _ := os.ReadFile(path)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	lines[1] = bytes.Replace(lines[1], []byte(`"Alice"`), []byte(`"Mallo"`), 1)
+	os.WriteFile(path, bytes.Join(lines, nil), 0o644)
+
+	if _, err := blockchain.OpenFileStore(dir); !errors.Is(err, blockchain.ErrCorruptCommitLog) {
+		t.Errorf("Expected a corrupt record before the tail to be reported, got %v", err)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(after, bytes.Join(lines, nil)) {
+		t.Error("Expected the valid records after the corrupt one to be kept")
+	}
+}
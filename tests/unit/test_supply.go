@@ -0,0 +1,80 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+)
+
+func TestBurnReducesTheSupply(t *testing.T) {
+	tokenomics := blockchain.NewTokenomics(0, 100_000)
+	tokenomics.OwnershipCap = 0
+	tokenomics.MintCoins("Alice", 1000)
+	tokenomics.MintCoins("Bob", 500)
+	if err := tokenomics.Burn("Alice", 1001); err == nil {
+		t.Error("Expected burning more than the balance to be rejected")
+	}
+	if err := tokenomics.Burn("Alice", 300); err != nil {
+		t.Fatalf("Failed to burn: %v", err)
+	}
+	if err := tokenomics.CheckSupply(); err != nil {
+		t.Errorf("Expected the balances to add up to the supply: %v", err)
+	}
+
+	bridge := blockchain.NewCrossChainBridge()
+	bridge.LockTokens("Bob", 200)
+	report := tokenomics.SupplyReport(bridge)
+	expected := blockchain.SupplyReport{Total: 1200, Max: 100_000, Circulating: 1000, Minted: 1500, Burned: 300, BridgeLocked: 200}
+	if report != expected {
+		t.Errorf("Expected %+v, got %+v", expected, report)
+	}
+
+	tokenomics.Balances["Bob"] += 1
+	if err := tokenomics.CheckSupply(); !errors.Is(err, blockchain.ErrSupplyMismatch) {
+		t.Errorf("Expected coins created outside MintCoins to be caught, got %v", err)
+	}
+}
+
+func TestBurnTransactionsAreTrackedOnChain(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	dir := t.TempDir()
+	store, _ := blockchain.OpenFileStore(dir)
+	bc, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, alice.Address, 100*blockchain.OneBMT)
+	tx, _ := blockchain.NewSignedTransaction(alice, blockchain.BurnAddress, 40*blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{tx}); err != nil {
+		t.Fatalf("Failed to burn on chain: %v", err)
+	}
+
+	report := bc.SupplyReport(nil)
+	if report.Burned < 40*blockchain.OneBMT || report.Total != blockchain.InitialSupply-report.Burned {
+		t.Errorf("Expected the burn to leave the supply, got %+v", report)
+	}
+	if report.VestingLocked != 800_000_000*blockchain.OneBMT || report.Circulating != report.Total-report.VestingLocked {
+		t.Errorf("Expected the founders' bucket to be locked, got %+v", report)
+	}
+	if balance := bc.Tokenomics.GetBalance(blockchain.BurnAddress); balance != 0 {
+		t.Errorf("Expected the burn address to hold nothing, got %s", balance)
+	}
+	if err := bc.CheckSupply(); err != nil {
+		t.Errorf("Expected the chain supply to reconcile: %v", err)
+	}
+	bc.Close()
+
+	store, _ = blockchain.OpenFileStore(dir)
+	reloaded, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to reload blockchain: %v", err)
+	}
+	defer reloaded.Close()
+	if burned := reloaded.SupplyReport(nil).Burned; burned != report.Burned {
+		t.Errorf("Expected %s burned after a restart, got %s", report.Burned, burned)
+	}
+	reloaded.Tokenomics.Burn(alice.Address, blockchain.OneBMT)
+	if err := reloaded.CheckSupply(); !errors.Is(err, blockchain.ErrSupplyMismatch) {
+		t.Errorf("Expected a burn outside the chain to be caught, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+)
+
+func TestTokensAreIssuedMintedAndTransferredOnChain(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bob, _ := blockchain.NewWallet()
+	dir := t.TempDir()
+	store, _ := blockchain.OpenFileStore(dir)
+	bc, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, alice.Address, 100*blockchain.OneBMT)
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, bob.Address, 101*blockchain.OneBMT)
+	send := func(wallet *blockchain.Wallet, receiver string, amount blockchain.Amount) error {
+		tx, _ := blockchain.NewTokenTransaction(wallet, "USDX", receiver, amount, cent, bc.GetNonce(wallet.Address), bc.ChainID, 1700000000)
+		return bc.AddTransactionBlock([]*blockchain.Transaction{tx})
+	}
+
+	issue, _ := blockchain.NewIssueTokenTransaction(alice, "USDX", 6, 1_000_000, cent, 0, bc.ChainID, 1700000000)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{issue}); err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+	again, _ := blockchain.NewIssueTokenTransaction(bob, "USDX", 2, 5, cent, 0, bc.ChainID, 1700000000)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{again}); !errors.Is(err, blockchain.ErrTokenExists) {
+		t.Errorf("Expected a second registration of the symbol to be rejected, got %v", err)
+	}
+	if err := send(alice, blockchain.MintTokenAddress, 1_000_001); !errors.Is(err, blockchain.ErrTokenCap) {
+		t.Errorf("Expected minting above the cap to be rejected, got %v", err)
+	}
+	if err := send(bob, blockchain.MintTokenAddress, 10); !errors.Is(err, blockchain.ErrNotMintAuthority) {
+		t.Errorf("Expected only the mint authority to mint, got %v", err)
+	}
+	if err := send(alice, blockchain.MintTokenAddress, 600_000); err != nil {
+		t.Fatalf("Failed to mint: %v", err)
+	}
+	if err := send(alice, bob.Address, 250_000); err != nil {
+		t.Fatalf("Failed to transfer tokens: %v", err)
+	}
+	if err := send(bob, alice.Address, 250_001); !errors.Is(err, blockchain.ErrInsufficientTokens) {
+		t.Errorf("Expected a transfer above the token balance to be rejected, got %v", err)
+	}
+	if err := send(bob, blockchain.BurnAddress, 50_000); err != nil {
+		t.Fatalf("Failed to burn tokens: %v", err)
+	}
+
+	if balance := bc.Tokenomics.GetBalance(bob.Address); balance != 101*blockchain.OneBMT-cent {
+		t.Errorf("Expected token transactions to only cost their BMT fee, got %s", balance)
+	}
+	if err := bc.CheckSupply(); err != nil {
+		t.Errorf("Expected token balances to stay out of the BMT supply: %v", err)
+	}
+	bc.Close()
+
+	store, _ = blockchain.OpenFileStore(dir)
+	reloaded, err := blockchain.NewDevelopmentBlockchain(store)
+	if err != nil {
+		t.Fatalf("Failed to reload blockchain: %v", err)
+	}
+	defer reloaded.Close()
+	token, ok := reloaded.GetToken("USDX")
+	expected := blockchain.TokenInfo{Symbol: "USDX", Decimals: 6, Cap: 1_000_000, Supply: 550_000, Authority: alice.Address}
+	if !ok || token != expected {
+		t.Errorf("Expected %+v, got %+v", expected, token)
+	}
+	if reloaded.GetTokenBalance("USDX", alice.Address) != 350_000 || reloaded.GetTokenBalance("USDX", bob.Address) != 200_000 {
+		t.Errorf("Unexpected token balances: Alice %d, Bob %d", reloaded.GetTokenBalance("USDX", alice.Address), reloaded.GetTokenBalance("USDX", bob.Address))
+	}
+	if reloaded.State.Root() != reloaded.GetLatestBlock().StateRoot {
+		t.Error("Expected the state root to cover the token accounts")
+	}
+}
+
+func TestTokenRegistrationIsValidated(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bc := blockchain.NewBlockchain()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, alice.Address, 100*blockchain.OneBMT)
+
+	for _, tc := range []struct {
+		symbol   string
+		decimals int
+		err      error
+	}{
+		{"usd", 2, blockchain.ErrInvalidTokenSymbol},
+		{"BMT", 7, blockchain.ErrInvalidTokenSymbol},
+		{"GOLD", 19, blockchain.ErrInvalidTokenDetails},
+	} {
+		tx, _ := blockchain.NewIssueTokenTransaction(alice, tc.symbol, tc.decimals, 1000, cent, 0, bc.ChainID, 1700000000)
+		if err := bc.AddTransactionBlock([]*blockchain.Transaction{tx}); !errors.Is(err, tc.err) {
+			t.Errorf("Expected %s with %d decimals to be rejected with %v, got %v", tc.symbol, tc.decimals, tc.err, err)
+		}
+	}
+
+	unknown, _ := blockchain.NewTokenTransaction(alice, "GOLD", "Bob", 1, cent, 0, bc.ChainID, 1700000000)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{unknown}); !errors.Is(err, blockchain.ErrUnknownToken) {
+		t.Errorf("Expected a transfer of an unregistered token to be rejected, got %v", err)
+	}
+	tx, _ := blockchain.NewSignedTransaction(alice, "token/GOLD/Bob", blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{tx}); err == nil {
+		t.Error("Expected BMT sent to a token address to be rejected")
+	}
+	withDecimals, _ := blockchain.NewSignedTransaction(alice, "Bob", blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)
+	withDecimals.Decimals = 2
+	withDecimals.Sign(alice)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{withDecimals}); err == nil {
+		t.Error("Expected a transfer carrying decimals to be rejected")
+	}
+	if tokens := bc.Tokens(); len(tokens) != 0 {
+		t.Errorf("Expected no registered tokens, got %+v", tokens)
+	}
+}
@@ -0,0 +1,113 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+)
+
+func newFundedChain(t *testing.T, wallet *blockchain.Wallet, amount blockchain.Amount) *blockchain.Blockchain {
+	bc := blockchain.NewBlockchain()
+	if err := bc.AddTransactionWithTokenomics(blockchain.SystemAddress, wallet.Address, amount); err != nil {
+		t.Fatalf("Failed to fund wallet: %v", err)
+	}
+	return bc
+}
+
+func TestSignedTransactionAccepted(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+
+	tx, err := blockchain.NewSignedTransaction(alice, "Bob", 40*blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)
+	if err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{tx}); err != nil {
+		t.Fatalf("Expected signed transaction to be accepted: %v", err)
+	}
+	if bc.Tokenomics.GetBalance("Bob") != 40*blockchain.OneBMT {
+		t.Errorf("Expected Bob's balance to be 40 BMT, got %s", bc.Tokenomics.GetBalance("Bob"))
+	}
+	if bc.GetNonce(alice.Address) != 1 {
+		t.Errorf("Expected Alice's nonce to be 1, got %d", bc.GetNonce(alice.Address))
+	}
+}
+
+func TestTransactionRejections(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	mallory, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+
+	valid, _ := blockchain.NewSignedTransaction(alice, "Bob", 10*blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)
+
+	tampered := *valid
+	tampered.Amount = 90 * blockchain.OneBMT
+	tampered.Hash = tampered.CalculateHash()
+
+	stolen, _ := blockchain.NewTransaction(alice.Address, "Mallory", 10*blockchain.OneBMT, 1700000000)
+	stolen.ChainID = bc.ChainID
+	stolen.Hash = stolen.CalculateHash()
+	stolen.PublicKey = mallory.PublicKey
+	stolen.Signature, _ = mallory.SignTransaction(stolen.Hash)
+
+	skipped, _ := blockchain.NewSignedTransaction(alice, "Bob", 10*blockchain.OneBMT, cent, 5, bc.ChainID, 1700000000)
+	otherChain, _ := blockchain.NewSignedTransaction(alice, "Bob", 10*blockchain.OneBMT, cent, 0, "bmt-testnet", 1700000000)
+
+	cases := []struct {
+		name string
+		tx   *blockchain.Transaction
+		want error
+	}{
+		{"bad signature", &tampered, blockchain.ErrInvalidSignature},
+		{"pubkey mismatch", stolen, blockchain.ErrSenderMismatch},
+		{"out-of-order nonce", skipped, blockchain.ErrInvalidNonce},
+		{"wrong chain", otherChain, blockchain.ErrWrongChainID},
+	}
+	for _, c := range cases {
+		err := bc.AddTransactionBlock([]*blockchain.Transaction{c.tx})
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{valid}); err != nil {
+		t.Fatalf("Expected valid transaction to be accepted: %v", err)
+	}
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{valid}); !errors.Is(err, blockchain.ErrReplayedTransaction) {
+		t.Errorf("Expected replay to be rejected, got %v", err)
+	}
+}
+
+func TestReencodedTransactionFailsVerification(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	tx, _ := blockchain.NewSignedTransaction(alice, "Bob", 5*blockchain.OneBMT, cent, 0, blockchain.DefaultChainID, 1700000001)
+
+	// Moving a digit from the timestamp into the amount, or from the receiver
+	// into the timestamp, keeps the concatenated fields the same
+	shifted := *tx
+	shifted.Timestamp, shifted.Amount = 170000000, 15*blockchain.OneBMT
+	moved := *tx
+	moved.Receiver, moved.Timestamp = "Bob1", 700000001
+	for _, forged := range []blockchain.Transaction{shifted, moved} {
+		forged.Hash = forged.CalculateHash()
+		if forged.Hash == tx.Hash {
+			t.Errorf("Expected %+v to hash differently from the signed transaction", forged)
+		}
+		if err := forged.VerifySignature(); !errors.Is(err, blockchain.ErrInvalidSignature) {
+			t.Errorf("Expected the re-encoded transaction to fail verification, got %v", err)
+		}
+	}
+
+	// Optional fields are tagged, so a token cannot pose as a target and
+	// decimals are committed to even without a token
+	token, target, decimals := *tx, *tx, *tx
+	token.Token, token.Decimals = "target", 5
+	target.Target = "5"
+	decimals.Decimals = 5
+	if token.CalculateHash() == target.CalculateHash() {
+		t.Error("Expected a token and a target to hash differently")
+	}
+	if decimals.CalculateHash() == tx.Hash {
+		t.Error("Expected decimals to change the hash of a transaction without a token")
+	}
+}
@@ -0,0 +1,215 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateBlockReportsTheBrokenRule(t *testing.T) {
+	source := blockchain.NewBlockchain()
+	source.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+	source.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", 2*blockchain.OneBMT)
+	target := blockchain.NewBlockchain()
+	if _, err := target.ProcessBlock(source.Chain[1]); err != nil {
+		t.Fatalf("Failed to share block 1: %v", err)
+	}
+	block := source.Chain[2]
+	if err := target.ValidateBlock(block); err != nil {
+		t.Fatalf("Expected block 2 to be valid on its parent, got %v", err)
+	}
+
+	rehash := func(b *blockchain.Block) *blockchain.Block {
+		b.Hash = b.CalculateHash()
+		return b
+	}
+	badHash := *block
+	badHash.Proposer = "Mallory"
+	future := *block
+	future.Timestamp = time.Now().Unix() + 2*blockchain.MaxClockDrift
+	badRoot := *block
+	badRoot.MerkleRoot = block.StateRoot
+	badFee := *block
+	badFee.BaseFee++
+	badState := *block
+	badState.StateRoot = source.Chain[1].StateRoot
+	for name, test := range map[string]struct {
+		block *blockchain.Block
+		rule  error
+	}{
+		"stale block":       {source.Chain[1], blockchain.ErrBlockHeight},
+		"tampered header":   {&badHash, blockchain.ErrBlockHash},
+		"future timestamp":  {rehash(&future), blockchain.ErrBlockTimestamp},
+		"wrong Merkle root": {rehash(&badRoot), blockchain.ErrBlockRoots},
+		"wrong base fee":    {rehash(&badFee), blockchain.ErrBlockBaseFee},
+		"wrong state root":  {rehash(&badState), blockchain.ErrStateRootMismatch},
+	} {
+		err := target.ValidateBlock(test.block)
+		var validationErr *blockchain.BlockValidationError
+		if !errors.Is(err, test.rule) || !errors.As(err, &validationErr) || validationErr.Height != test.block.Index {
+			t.Errorf("Expected the %s to break %v, got %v", name, test.rule, err)
+		}
+	}
+	if target.GetLatestBlock().Index != 1 {
+		t.Error("Expected validation to leave the chain unchanged")
+	}
+}
+
+func TestInvalidTransactionsAreReportedWithTheirCause(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	alice, _ := blockchain.NewWallet()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, alice.Address, 10*blockchain.OneBMT)
+
+	replayed, _ := blockchain.NewSignedTransaction(alice, "Bob", blockchain.OneBMT, cent, 1, bc.ChainID, 1700000000)
+	err := bc.AddTransactionBlock([]*blockchain.Transaction{replayed})
+	var validationErr *blockchain.BlockValidationError
+	if !errors.As(err, &validationErr) || validationErr.Rule != blockchain.ErrBlockTransaction || !errors.Is(err, blockchain.ErrInvalidNonce) {
+		t.Errorf("Expected an out-of-order nonce to be reported as an invalid transaction, got %v", err)
+	}
+	overdrawn, _ := blockchain.NewSignedTransaction(alice, "Bob", 20*blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{overdrawn}); !errors.Is(err, blockchain.ErrBlockTransaction) {
+		t.Errorf("Expected an overdrawn transfer to be reported as an invalid transaction, got %v", err)
+	}
+
+	// Rejected blocks leave the proposer's chain valid
+	if err := bc.ValidateChain(); err != nil || !bc.IsValid() {
+		t.Fatalf("Expected the chain to be valid, got %v", err)
+	}
+	tip := bc.GetLatestBlock()
+	merkleRoot := tip.MerkleRoot
+	tip.MerkleRoot = ""
+	if err := bc.ValidateChain(); !errors.Is(err, blockchain.ErrBlockHash) || bc.IsValid() {
+		t.Errorf("Expected a tampered block to invalidate the chain, got %v", err)
+	}
+
+	// Rehashed headers must still follow the fee, difficulty and work rules
+	tip.MerkleRoot = merkleRoot
+	tip.BaseFee++
+	tip.Hash = tip.CalculateHash()
+	if err := bc.ValidateChain(); !errors.Is(err, blockchain.ErrBlockBaseFee) {
+		t.Errorf("Expected a rehashed base fee to invalidate the chain, got %v", err)
+	}
+	tip.BaseFee--
+	tip.Difficulty++
+	tip.Hash = tip.CalculateHash()
+	if err := bc.ValidateChain(); !errors.Is(err, blockchain.ErrBlockDifficulty) {
+		t.Errorf("Expected a rehashed difficulty to invalidate the chain, got %v", err)
+	}
+	tip.Difficulty--
+	tip.SolveTime++
+	tip.Hash = tip.CalculateHash()
+	if err := bc.ValidateChain(); !errors.Is(err, blockchain.ErrProofOfWork) {
+		t.Errorf("Expected a rehashed solve time to invalidate the chain, got %v", err)
+	}
+}
+
+func TestCoinsLeaveTheSystemAddressOnlyThroughGovernancePayouts(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	mallory, _ := blockchain.NewWallet()
+	system := bc.Tokenomics.GetBalance(blockchain.SystemAddress)
+
+	unsigned, _ := blockchain.NewTransaction(blockchain.SystemAddress, mallory.Address, 1000*blockchain.OneBMT, time.Now().Unix())
+	unsigned.ChainID = bc.ChainID
+	if err := bc.AddBlock([]blockchain.Transaction{*unsigned}); !errors.Is(err, blockchain.ErrUnsignedTransaction) {
+		t.Errorf("Expected an unsigned transfer from the system address to be rejected, got %v", err)
+	}
+	forged, _ := blockchain.NewPayoutTransaction(mallory, mallory.Address, 1000*blockchain.OneBMT, 0, 0, bc.ChainID, time.Now().Unix())
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{forged}); !errors.Is(err, blockchain.ErrNotGovernance) {
+		t.Errorf("Expected a payout signed outside governance to be rejected, got %v", err)
+	}
+	if balance := bc.Tokenomics.GetBalance(blockchain.SystemAddress); balance != system {
+		t.Errorf("Expected the community balance to stay at %s, got %s", system, balance)
+	}
+
+	if err := bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT); err != nil {
+		t.Fatalf("Failed to pay out with the governance key: %v", err)
+	}
+	// The genesis block commits to the governance address, so a chain under
+	// other governance does not even share the payout's ancestry
+	peer, _ := blockchain.NewBlockchainWithStore(blockchain.NewMemoryStore())
+	if peer.Chain[0].Hash == bc.Chain[0].Hash {
+		t.Error("Expected chains under different governance to have different genesis blocks")
+	}
+	if _, err := peer.ProcessBlock(bc.GetLatestBlock()); !errors.Is(err, blockchain.ErrUnknownParent) {
+		t.Errorf("Expected a chain under other governance to reject the payout, got %v", err)
+	}
+	if err := peer.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT); !errors.Is(err, blockchain.ErrNotGovernance) {
+		t.Errorf("Expected a node without the governance key to be unable to pay out, got %v", err)
+	}
+}
+
+func TestBlocksMustBeProposedByValidators(t *testing.T) {
+	bc, wallets := newStakingChain(t, 100*blockchain.OneBMT, 101*blockchain.OneBMT, 102*blockchain.OneBMT)
+	alice := wallets[0]
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{stakingTx(t, bc, alice, blockchain.BondAddress, 30*blockchain.OneBMT)}); err != nil {
+		t.Fatalf("Failed to bond: %v", err)
+	}
+	bc.Coinbase = alice.Address
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Dave", blockchain.OneBMT)
+	if err := bc.ValidateChain(); err != nil {
+		t.Fatalf("Expected a block proposed by a validator to be valid, got %v", err)
+	}
+
+	bc.Coinbase = "Mallory"
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Dave", 2*blockchain.OneBMT)
+	if err := bc.ValidateChain(); !errors.Is(err, blockchain.ErrIneligibleProposer) {
+		t.Errorf("Expected a block proposed by an outsider to be rejected, got %v", err)
+	}
+}
+
+func TestVotingConsensusRejectsInvalidBlocks(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT)
+	block := bc.GetLatestBlock()
+	voters := []string{"node-1", "node-2", "node-3"}
+
+	consensus := blockchain.NewVotingConsensus(0.66)
+	if accepted, err := consensus.ProposeBlock(block, voters); !accepted || err != nil {
+		t.Errorf("Expected a valid block to be accepted, got %v", err)
+	}
+	tampered := *block
+	tampered.Timestamp++
+	if accepted, err := consensus.ProposeBlock(&tampered, voters); accepted || !errors.Is(err, blockchain.ErrBlockHash) {
+		t.Errorf("Expected a tampered block to be rejected, got %v", err)
+	}
+
+	// With a chain, blocks must extend its tip
+	consensus.Chain = bc
+	if accepted, err := consensus.ProposeBlock(block, voters); accepted || !errors.Is(err, blockchain.ErrBlockHeight) {
+		t.Errorf("Expected a block already on the chain to be rejected, got %v", err)
+	}
+}
+
+func TestProposalsNeverReachTheLiveState(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	bc := newFundedChain(t, alice, 100*blockchain.OneBMT)
+	tx, _ := blockchain.NewSignedTransaction(alice, "Bob", 40*blockchain.OneBMT, cent, 0, bc.ChainID, 1700000000)
+	root := bc.State.Root()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			block, err := bc.BuildProposal(blockchain.SystemAddress, []*blockchain.Transaction{tx})
+			if err != nil {
+				t.Errorf("Failed to build proposal: %v", err)
+				return
+			}
+			bc.ValidateProposal(block)
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		if balance := bc.Tokenomics.GetBalance("Bob"); balance != 0 {
+			t.Fatalf("Expected proposals to leave balances untouched, read %s", balance)
+		}
+	}
+	if bc.State.Root() != root {
+		t.Error("Expected proposals to leave the state root untouched")
+	}
+}
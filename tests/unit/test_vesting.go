@@ -0,0 +1,174 @@
+package blockchain_test
+
+import (
+	"BMT-Blockchain/src/blockchain"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const day = 24 * 60 * 60
+
+func TestVestedCoinsUnlockOnRelease(t *testing.T) {
+	tokenomics := blockchain.NewTokenomics(8_000_000_000, 10_000_000_000)
+	schedule := blockchain.VestingSchedule{Beneficiary: "Alice", Amount: 1000, Start: 1000, Cliff: 100, Duration: 400}
+	if err := tokenomics.AddVestingSchedule(schedule); err != nil {
+		t.Fatalf("Failed to add vesting schedule: %v", err)
+	}
+	if err := tokenomics.AddVestingSchedule(schedule); err == nil {
+		t.Error("Expected a second schedule for the same beneficiary to be rejected")
+	}
+	tokenomics.MintCoins("Alice", 1100)
+
+	for at, vested := range map[int64]blockchain.Amount{999: 0, 1099: 0, 1100: 250, 1200: 500, 1400: 1000, 2000: 1000} {
+		if got := schedule.Vested(at); got != vested {
+			t.Errorf("Expected %d vested at %d, got %d", vested, at, got)
+		}
+	}
+	if _, err := tokenomics.Release("Alice", 1050); err == nil {
+		t.Error("Expected nothing to be releasable before the cliff")
+	}
+	if err := tokenomics.Transfer("Alice", "Bob", 101); !errors.Is(err, blockchain.ErrLockedFunds) {
+		t.Errorf("Expected locked coins to be unspendable, got %v", err)
+	}
+
+	released, err := tokenomics.Release("Alice", 1200)
+	if err != nil || released != 500 {
+		t.Fatalf("Expected 500 released, got %d, %v", released, err)
+	}
+	info, _ := tokenomics.VestingInfo("Alice", 1300)
+	if info.Vested != 750 || info.Released != 500 || info.Releasable != 250 || info.Locked != 500 {
+		t.Errorf("Unexpected vesting state: %+v", info)
+	}
+	if spendable := tokenomics.SpendableBalance("Alice"); spendable != 600 {
+		t.Errorf("Expected 600 spendable, got %d", spendable)
+	}
+	if err := tokenomics.Transfer("Alice", "Bob", 600); err != nil {
+		t.Errorf("Failed to transfer released coins: %v", err)
+	}
+}
+
+func TestGenesisAllocationFollowsTheBuckets(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	allocation := map[string]blockchain.Amount{
+		blockchain.SystemAddress: 5_600_000_000 * blockchain.OneBMT,
+		"allocation/presale":     800_000_000 * blockchain.OneBMT,
+		"allocation/founders":    800_000_000 * blockchain.OneBMT,
+		"allocation/partners":    800_000_000 * blockchain.OneBMT,
+	}
+	for address, expected := range allocation {
+		if balance := bc.Tokenomics.GetBalance(address); balance != expected {
+			t.Errorf("Expected %s to hold %s, got %s", address, expected, balance)
+		}
+	}
+
+	// The founders' bucket is locked for two years, then vests over the third
+	twoYears := blockchain.GenesisTimestamp + 2*365*day
+	if info, ok := bc.Tokenomics.VestingInfo("allocation/founders", twoYears-1); !ok || info.Vested != 0 || info.Locked != allocation["allocation/founders"] {
+		t.Errorf("Expected the founders' bucket to be locked, got %+v", info)
+	}
+	if info, _ := bc.Tokenomics.VestingInfo("allocation/founders", twoYears+365*day/2); info.Vested != 666_666_666*blockchain.OneBMT+6_666_666 {
+		t.Errorf("Expected five sixths vested half way through the third year, got %s", info.Vested)
+	}
+
+	genesis := blockchain.DefaultGenesis()
+	genesis.Buckets[1].Share = 0.2
+	if _, err := blockchain.NewBlockchainWithGenesis(blockchain.NewMemoryStore(), genesis); err == nil {
+		t.Error("Expected shares adding up to more than one to be rejected")
+	}
+}
+
+func TestVestingIsEnforcedOnChain(t *testing.T) {
+	alice, _ := blockchain.NewWallet()
+	now := time.Now().Unix()
+	genesis := blockchain.GenesisConfig{Buckets: []blockchain.AllocationBucket{
+		{Name: "community", Address: blockchain.SystemAddress, Share: 0.7},
+		{Name: "founders", Address: "allocation/founders", Share: 0.3, Grants: []blockchain.VestingSchedule{
+			{Beneficiary: alice.Address, Amount: 1000 * blockchain.OneBMT, Start: now - 50*day, Cliff: 10 * day, Duration: 100 * day},
+		}},
+	}}
+	dir := t.TempDir()
+	store, _ := blockchain.OpenFileStore(dir)
+	bc, err := blockchain.NewBlockchainWithGenesis(store, genesis)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	transfer := func(receiver string, amount blockchain.Amount) error {
+		tx, _ := blockchain.NewSignedTransaction(alice, receiver, amount, cent, bc.GetNonce(alice.Address), bc.ChainID, 1700000000)
+		return bc.AddTransactionBlock([]*blockchain.Transaction{tx})
+	}
+
+	if err := transfer("Bob", blockchain.OneBMT); !errors.Is(err, blockchain.ErrLockedFunds) {
+		t.Errorf("Expected the unreleased grant to be unspendable, got %v", err)
+	}
+	if err := transfer(blockchain.ReleaseAddress, 400*blockchain.OneBMT); err != nil {
+		t.Fatalf("Failed to release vested coins: %v", err)
+	}
+	if err := transfer(blockchain.ReleaseAddress, 200*blockchain.OneBMT); err == nil {
+		t.Error("Expected releasing more than has vested to be rejected")
+	}
+	if err := transfer("Bob", 450*blockchain.OneBMT); !errors.Is(err, blockchain.ErrLockedFunds) {
+		t.Errorf("Expected spending into the locked coins to be rejected, got %v", err)
+	}
+	if err := transfer("Bob", 300*blockchain.OneBMT); err != nil {
+		t.Fatalf("Failed to spend released coins: %v", err)
+	}
+	info, ok := bc.GetVesting(alice.Address)
+	if !ok || info.Released != 400*blockchain.OneBMT || info.Locked != 600*blockchain.OneBMT {
+		t.Errorf("Unexpected vesting state: %+v", info)
+	}
+	bc.Close()
+
+	store, _ = blockchain.OpenFileStore(dir)
+	if _, err := blockchain.NewBlockchainWithStore(store); err == nil {
+		t.Error("Expected a store of another genesis allocation to be rejected")
+	}
+	store.Close()
+	store, _ = blockchain.OpenFileStore(dir)
+	reloaded, err := blockchain.NewBlockchainWithGenesis(store, genesis)
+	if err != nil {
+		t.Fatalf("Failed to reload blockchain: %v", err)
+	}
+	defer reloaded.Close()
+	if info, _ := reloaded.GetVesting(alice.Address); info.Released != 400*blockchain.OneBMT {
+		t.Errorf("Expected the release to survive a restart, got %+v", info)
+	}
+	if reloaded.State.Root() != reloaded.GetLatestBlock().StateRoot {
+		t.Error("Expected the state root to cover the released amount")
+	}
+}
+
+func TestNodesShareTheGovernanceOfAGenesisFile(t *testing.T) {
+	council, _ := blockchain.NewWallet()
+	genesis := blockchain.DefaultGenesis()
+	genesis.Governance = council.Address
+	data, _ := json.Marshal(genesis)
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("Failed to write genesis file: %v", err)
+	}
+
+	loaded, err := blockchain.LoadGenesisFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load genesis file: %v", err)
+	}
+	treasury, _ := blockchain.NewBlockchainWithGenesis(blockchain.NewMemoryStore(), loaded)
+	treasury.Treasurer = council
+	if err := treasury.AddTransactionWithTokenomics(blockchain.SystemAddress, "Alice", blockchain.OneBMT); err != nil {
+		t.Fatalf("Failed to pay out of the community bucket: %v", err)
+	}
+	peer, _ := blockchain.NewBlockchainWithGenesis(blockchain.NewMemoryStore(), loaded)
+	if _, err := peer.ProcessBlock(treasury.Chain[1]); err != nil {
+		t.Errorf("Expected a node of the same genesis file to accept the payout, got %v", err)
+	}
+
+	genesis.Governance = ""
+	data, _ = json.Marshal(genesis)
+	os.WriteFile(path, data, 0o644)
+	if _, err := blockchain.LoadGenesisFile(path); err == nil {
+		t.Error("Expected a genesis file without a governance address to be rejected")
+	}
+}
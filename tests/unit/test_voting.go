@@ -1,6 +1,7 @@
 package governance_test
 
 import (
+	"BMT-Blockchain/src/blockchain"
 	"BMT-Blockchain/src/governance"
 	"testing"
 )
@@ -32,3 +33,33 @@ func TestVoting(t *testing.T) {
 		t.Error("Proposal should have passed with 66% threshold")
 	}
 }
+
+func TestCapExemptionProposal(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	council := bc.Treasurer
+	bc.AddTransactionWithTokenomics(blockchain.SystemAddress, council.Address, blockchain.OneBMT)
+	nonce := bc.GetNonce(council.Address)
+
+	proposal := governance.NewCapExemptionProposal("P2", "Exempt the bridge", "bridge", true, 0.5)
+	if _, err := proposal.ExemptionTransaction(council, blockchain.OneBMT/100, nonce, bc.ChainID, 1700000000); err == nil {
+		t.Error("Expected a proposal without votes not to be carried out")
+	}
+	proposal.Vote("Node1", true)
+	proposal.Vote("Node2", true)
+	tx, err := proposal.ExemptionTransaction(council, blockchain.OneBMT/100, nonce, bc.ChainID, 1700000000)
+	if err != nil {
+		t.Fatalf("Failed to carry out a passed proposal: %v", err)
+	}
+	if exemptions := bc.Tokenomics.CapExemptions(); len(exemptions) != 4 {
+		t.Fatalf("Expected only the genesis buckets to be exempt before the block, got %v", exemptions)
+	}
+	if err := bc.AddTransactionBlock([]*blockchain.Transaction{tx}); err != nil {
+		t.Fatalf("Failed to commit the exemption: %v", err)
+	}
+	if exemptions := bc.Tokenomics.CapExemptions(); len(exemptions) != 5 || exemptions[3] != "bridge" {
+		t.Errorf("Expected the bridge to be exempt, got %v", exemptions)
+	}
+	if _, err := governance.NewProposal("P3", "No exemption", 0.5).ExemptionTransaction(council, 0, nonce+1, bc.ChainID, 1700000000); err == nil {
+		t.Error("Expected a proposal without an exemption change to be rejected")
+	}
+}